@@ -2,17 +2,25 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stackup-wallet/stackup-bundler/internal/start"
 )
 
-var startCmd = &cobra.Command{
-	Use:   "start",
-	Short: "Starts an instance",
-	Run: func(cmd *cobra.Command, args []string) {
-		start.Rip7560Mode()
-	},
-}
+var (
+	mode     string
+	startCmd = &cobra.Command{
+		Use:   "start",
+		Short: "Starts an instance",
+		Run: func(cmd *cobra.Command, args []string) {
+			start.Rip7560Mode()
+		},
+	}
+)
 
 func init() {
 	rootCmd.AddCommand(startCmd)
+	startCmd.Flags().StringVarP(&mode, "mode", "m", "private", "Values: private, searcher")
+	if err := viper.BindPFlag("mode", startCmd.Flags().Lookup("mode")); err != nil {
+		panic(err)
+	}
 }
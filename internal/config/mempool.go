@@ -10,6 +10,7 @@ func NewReputationConstantsFromEnv() *entities.ReputationConstants {
 	viper.SetDefault("rip7560_bundler_min_stake_value", 2000000000000000)
 	viper.SetDefault("rip7560_bundler_same_sender_mempool_count", 4)
 	viper.SetDefault("rip7560_bundler_same_unstaked_entity_mempool_count", 11)
+	viper.SetDefault("rip7560_bundler_same_staked_entity_mempool_count", 128)
 	viper.SetDefault("rip7560_bundler_throttled_entity_mempool_count", 4)
 	viper.SetDefault("rip7560_bundler_throttled_entity_live_blocks", 10)
 	viper.SetDefault("rip7560_bundler_throttled_entity_bundle_count", 4)
@@ -21,6 +22,7 @@ func NewReputationConstantsFromEnv() *entities.ReputationConstants {
 	_ = viper.BindEnv("rip7560_bundler_min_stake_value")
 	_ = viper.BindEnv("rip7560_bundler_same_sender_mempool_count")
 	_ = viper.BindEnv("rip7560_bundler_same_unstaked_entity_mempool_count")
+	_ = viper.BindEnv("rip7560_bundler_same_staked_entity_mempool_count")
 	_ = viper.BindEnv("rip7560_bundler_throttled_entity_mempool_count")
 	_ = viper.BindEnv("rip7560_bundler_throttled_entity_live_blocks")
 	_ = viper.BindEnv("rip7560_bundler_throttled_entity_bundle_count")
@@ -33,6 +35,7 @@ func NewReputationConstantsFromEnv() *entities.ReputationConstants {
 		MinStakeValue:                  viper.GetInt64("rip7560_bundler_min_stake_value"),
 		SameSenderMempoolCount:         viper.GetInt("rip7560_bundler_same_sender_mempool_count"),
 		SameUnstakedEntityMempoolCount: viper.GetInt("rip7560_bundler_same_unstaked_entity_mempool_count"),
+		SameStakedEntityMempoolCount:   viper.GetInt("rip7560_bundler_same_staked_entity_mempool_count"),
 		ThrottledEntityMempoolCount:    viper.GetInt("rip7560_bundler_throttled_entity_mempool_count"),
 		ThrottledEntityLiveBlocks:      viper.GetInt("rip7560_bundler_throttled_entity_live_blocks"),
 		ThrottledEntityBundleCount:     viper.GetInt("rip7560_bundler_throttled_entity_bundle_count"),
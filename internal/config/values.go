@@ -14,16 +14,30 @@ import (
 
 type Values struct {
 	// Documented variables.
-	PrivateKey          string
-	EthClientUrl        string
-	Port                int
-	DataDirectory       string
-	MaxVerificationGas  *big.Int
-	MaxBatchGasLimit    *big.Int
-	MaxTxTTL            time.Duration
-	ReputationConstants *entities.ReputationConstants
+	PrivateKey              string
+	EthClientUrl            string
+	Port                    int
+	DataDirectory           string
+	MaxVerificationGas      *big.Int
+	MaxBatchGasLimit        *big.Int
+	MaxTxTTL                time.Duration
+	MaxMempoolTxs           int
+	MaxMempoolGas           uint64
+	ReplacementPriceBump    int64
+	RevalidationConcurrency int
+	InFlightMaxBlocks       uint64
+	MempoolStore            string
+	BundleStrategy          string
+	MinBuilderFee           *big.Int
+	BundleHistoryTTL        time.Duration
+	StakeManagerAddress     common.Address
+	AltMempoolsFile         string
+	TrustedProxies          []string
+	ReportSecret            string
+	ReputationConstants     *entities.ReputationConstants
 
 	// Searcher mode variables.
+	Mode           string
 	EthBuilderUrls []string
 
 	// Undocumented variables.
@@ -75,6 +89,15 @@ func GetValues() *Values {
 	// TODO : adjust args from geth request, deprecate this!
 	viper.SetDefault("rip7560_bundler_max_batch_gas_limit", 18000000)
 	viper.SetDefault("rip7560_bundler_max_tx_ttl_seconds", 180)
+	viper.SetDefault("rip7560_bundler_max_mempool_txs", 4096)
+	viper.SetDefault("rip7560_bundler_max_mempool_gas", 0)
+	viper.SetDefault("rip7560_bundler_replacement_price_bump", 10)
+	viper.SetDefault("rip7560_bundler_revalidation_concurrency", 8)
+	viper.SetDefault("rip7560_bundler_inflight_max_blocks", 10)
+	viper.SetDefault("rip7560_bundler_mempool_store", "badger")
+	viper.SetDefault("rip7560_bundler_bundle_strategy", "max-revenue")
+	viper.SetDefault("rip7560_bundler_min_builder_fee", 0)
+	viper.SetDefault("rip7560_bundler_bundle_history_ttl_seconds", 604800)
 	viper.SetDefault("rip7560_bundler_debug_mode", false)
 	viper.SetDefault("rip7560_bundler_gin_mode", gin.ReleaseMode)
 
@@ -100,6 +123,19 @@ func GetValues() *Values {
 	_ = viper.BindEnv("rip7560_bundler_max_verification_gas")
 	_ = viper.BindEnv("rip7560_bundler_max_batch_gas_limit")
 	_ = viper.BindEnv("rip7560_bundler_max_tx_ttl_seconds")
+	_ = viper.BindEnv("rip7560_bundler_max_mempool_txs")
+	_ = viper.BindEnv("rip7560_bundler_max_mempool_gas")
+	_ = viper.BindEnv("rip7560_bundler_replacement_price_bump")
+	_ = viper.BindEnv("rip7560_bundler_revalidation_concurrency")
+	_ = viper.BindEnv("rip7560_bundler_inflight_max_blocks")
+	_ = viper.BindEnv("rip7560_bundler_mempool_store")
+	_ = viper.BindEnv("rip7560_bundler_bundle_strategy")
+	_ = viper.BindEnv("rip7560_bundler_min_builder_fee")
+	_ = viper.BindEnv("rip7560_bundler_bundle_history_ttl_seconds")
+	_ = viper.BindEnv("rip7560_bundler_stake_manager_address")
+	_ = viper.BindEnv("rip7560_bundler_alt_mempools_file")
+	_ = viper.BindEnv("rip7560_bundler_trusted_proxies")
+	_ = viper.BindEnv("rip7560_bundler_report_secret")
 	_ = viper.BindEnv("rip7560_bundler_eth_builder_urls")
 	_ = viper.BindEnv("rip7560_bundler_debug_mode")
 	_ = viper.BindEnv("rip7560_bundler_gin_mode")
@@ -114,10 +150,22 @@ func GetValues() *Values {
 	}
 
 	switch viper.GetString("mode") {
+	case "", "private":
 	case "searcher":
 		if variableNotSetOrIsNil("rip7560_bundler_eth_builder_urls") {
 			panic("Fatal config error: rip7560_bundler_eth_builder_urls not set")
 		}
+	default:
+		panic(fmt.Sprintf("Fatal config error: unknown mode %q", viper.GetString("mode")))
+	}
+
+	switch viper.GetString("rip7560_bundler_mempool_store") {
+	case "badger", "memory":
+	default:
+		panic(fmt.Sprintf(
+			"Fatal config error: unknown rip7560_bundler_mempool_store %q",
+			viper.GetString("rip7560_bundler_mempool_store"),
+		))
 	}
 
 	// Return Values
@@ -128,20 +176,48 @@ func GetValues() *Values {
 	maxVerificationGas := big.NewInt(int64(viper.GetInt("rip7560_bundler_max_verification_gas")))
 	maxBatchGasLimit := big.NewInt(int64(viper.GetInt("rip7560_bundler_max_batch_gas_limit")))
 	maxTxTTL := time.Second * viper.GetDuration("rip7560_bundler_max_tx_ttl_seconds")
+	maxMempoolTxs := viper.GetInt("rip7560_bundler_max_mempool_txs")
+	maxMempoolGas := viper.GetUint64("rip7560_bundler_max_mempool_gas")
+	replacementPriceBump := viper.GetInt64("rip7560_bundler_replacement_price_bump")
+	revalidationConcurrency := viper.GetInt("rip7560_bundler_revalidation_concurrency")
+	inFlightMaxBlocks := viper.GetUint64("rip7560_bundler_inflight_max_blocks")
+	mempoolStore := viper.GetString("rip7560_bundler_mempool_store")
+	bundleStrategy := viper.GetString("rip7560_bundler_bundle_strategy")
+	minBuilderFee := big.NewInt(viper.GetInt64("rip7560_bundler_min_builder_fee"))
+	bundleHistoryTTL := time.Second * viper.GetDuration("rip7560_bundler_bundle_history_ttl_seconds")
+	stakeManagerAddress := common.HexToAddress(viper.GetString("rip7560_bundler_stake_manager_address"))
+	altMempoolsFile := viper.GetString("rip7560_bundler_alt_mempools_file")
+	trustedProxies := envArrayToStringSlice(viper.GetString("rip7560_bundler_trusted_proxies"))
+	reportSecret := viper.GetString("rip7560_bundler_report_secret")
+	mode := viper.GetString("mode")
 	ethBuilderUrls := envArrayToStringSlice(viper.GetString("rip7560_bundler_eth_builder_urls"))
 	debugMode := viper.GetBool("rip7560_bundler_debug_mode")
 	ginMode := viper.GetString("rip7560_bundler_gin_mode")
 	return &Values{
-		PrivateKey:          privateKey,
-		EthClientUrl:        ethClientUrl,
-		Port:                port,
-		DataDirectory:       dataDirectory,
-		MaxVerificationGas:  maxVerificationGas,
-		MaxBatchGasLimit:    maxBatchGasLimit,
-		MaxTxTTL:            maxTxTTL,
-		ReputationConstants: NewReputationConstantsFromEnv(),
-		EthBuilderUrls:      ethBuilderUrls,
-		DebugMode:           debugMode,
-		GinMode:             ginMode,
+		PrivateKey:              privateKey,
+		EthClientUrl:            ethClientUrl,
+		Port:                    port,
+		DataDirectory:           dataDirectory,
+		MaxVerificationGas:      maxVerificationGas,
+		MaxBatchGasLimit:        maxBatchGasLimit,
+		MaxTxTTL:                maxTxTTL,
+		MaxMempoolTxs:           maxMempoolTxs,
+		MaxMempoolGas:           maxMempoolGas,
+		ReplacementPriceBump:    replacementPriceBump,
+		RevalidationConcurrency: revalidationConcurrency,
+		InFlightMaxBlocks:       inFlightMaxBlocks,
+		MempoolStore:            mempoolStore,
+		BundleStrategy:          bundleStrategy,
+		MinBuilderFee:           minBuilderFee,
+		BundleHistoryTTL:        bundleHistoryTTL,
+		StakeManagerAddress:     stakeManagerAddress,
+		AltMempoolsFile:         altMempoolsFile,
+		TrustedProxies:          trustedProxies,
+		ReportSecret:            reportSecret,
+		ReputationConstants:     NewReputationConstantsFromEnv(),
+		Mode:                    mode,
+		EthBuilderUrls:          ethBuilderUrls,
+		DebugMode:               debugMode,
+		GinMode:                 ginMode,
 	}
 }
@@ -1,7 +1,9 @@
 package ginutils
 
 import (
+	"crypto/subtle"
 	"net"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -18,3 +20,17 @@ func GetClientIPFromXFF(c *gin.Context) string {
 
 	return c.ClientIP()
 }
+
+// RequireBearerToken returns a gin middleware that aborts with 401 unless the request has an
+// "Authorization: Bearer <token>" header matching the given token.
+func RequireBearerToken(token string) gin.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		got := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
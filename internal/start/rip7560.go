@@ -4,28 +4,45 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/big"
 	"net/http"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/internal/ginutils"
 	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+	"github.com/stackup-wallet/stackup-bundler/pkg/blocks"
 	"github.com/stackup-wallet/stackup-bundler/pkg/bundler"
 	"github.com/stackup-wallet/stackup-bundler/pkg/client"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
 	"github.com/stackup-wallet/stackup-bundler/pkg/jsonrpc"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/batch"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/bundlesim"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/expire"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/inflight"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/revalidate"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/nonce"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/stake"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/searcher"
 	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
 	"go.opentelemetry.io/otel"
 )
 
+// eventsBuffer is the number of mempool events held for each /events subscriber before further events are
+// dropped for it.
+const eventsBuffer = 256
+
 func Rip7560Mode() {
 	conf := config.GetValues()
 
@@ -57,10 +74,46 @@ func Rip7560Mode() {
 		log.Fatal(err)
 	}
 
-	mem, err := mempool.New(db)
+	var store mempool.Store = mempool.NewBadgerStore(db)
+	if conf.MempoolStore == "memory" {
+		store = mempool.NewMemoryStore()
+	}
+	mem, err := mempool.NewWithStore(store)
 	if err != nil {
 		log.Fatal(err)
 	}
+	mem.SetLimits(conf.MaxMempoolTxs, conf.MaxMempoolGas)
+	mem.SetGetBaseFeeFunc(gasprice.GetBaseFeeWithEthClient(eth))
+	mem.SetGetNonceFunc(nonce.GetNonceWithEthClient(eth))
+	mem.SetReplacementPriceBump(conf.ReplacementPriceBump)
+
+	// Init the new head notifier. Every module that acts on new blocks subscribes to it and it is started once
+	// they are all wired up.
+	heads := blocks.NewNotifier(blocks.GetBlockNumberWithEthClient(eth))
+	heads.UseLogger(logr)
+
+	// Init stake lookups for staked entity exceptions. Without a stake manager every entity is unstaked.
+	gsi := stake.GetStakeInfoNoop()
+	if conf.StakeManagerAddress != (common.Address{}) {
+		gsi = stake.GetStakeInfoWithEthClient(eth, conf.StakeManagerAddress)
+	}
+	sm := stake.New(
+		gsi,
+		big.NewInt(conf.ReputationConstants.MinStakeValue),
+		uint64(conf.ReputationConstants.MinUnstakeDelay),
+	)
+	sm.UseLogger(logr)
+	heads.Subscribe("stake", sm.OnNewHead)
+
+	// Init alternative mempools. Without a definitions file only the canonical rules apply.
+	var alt *altmempools.Directory
+	if conf.AltMempoolsFile != "" {
+		alt, err = altmempools.NewFromFile(chain, conf.AltMempoolsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logr.Info("loaded alt mempools", "alt_mempool_ids", alt.GetIds())
+	}
 
 	check := checks.New(
 		db,
@@ -69,10 +122,47 @@ func Rip7560Mode() {
 		conf.MaxBatchGasLimit,
 		conf.ReputationConstants,
 	)
+	check.SetReplacementPriceBump(conf.ReplacementPriceBump)
+	check.SetMinBuilderFee(conf.MinBuilderFee)
+	check.SetIsStakedFunc(sm.IsStaked)
+	check.SetAltMempools(alt)
 
-	exp := expire.New(conf.MaxTxTTL)
+	exp := expire.New(mem, conf.MaxTxTTL)
 
 	rep := entities.New(db, eth, conf.ReputationConstants)
+	rep.SetIsStakedFunc(sm.IsStaked)
+
+	// Init ERC-7562 validation tracing of resident txs. Each tx is limited to the alt mempools it was admitted to.
+	trace := bundlesim.TraceWithRpcClient(rpc, chain, sm.IsStaked, bundlesim.GetAltMempoolsWithMetadata(mem, alt))
+
+	// Init background revalidation of the mempool on new blocks. Revalidation promotes queued txs before it
+	// starts, so they are only promoted on their own if it is disabled.
+	if conf.RevalidationConcurrency > 0 {
+		rev := revalidate.New(
+			mem,
+			revalidate.ValidateWithRpcClient(rpc, trace),
+			conf.RevalidationConcurrency,
+		)
+		rev.UseLogger(logr)
+		rev.UseReputation(rep)
+		heads.Subscribe("revalidate", rev.OnNewHead)
+	} else {
+		heads.Subscribe("promote", func(_ uint64) error { return mem.PromoteQueued() })
+	}
+
+	// Init history of served bundles
+	hist := history.New(db, conf.BundleHistoryTTL)
+
+	// Init tracking of bundled txs until they are final
+	tr := inflight.New(
+		mem,
+		inflight.GetReceiptWithEthClient(eth),
+		conf.InFlightMaxBlocks,
+	)
+	tr.UseLogger(logr)
+	tr.UseHistory(hist)
+	tr.UseReputation(rep)
+	heads.Subscribe("inflight", tr.Check)
 
 	// Init Client
 	c := client.New(mem, chain)
@@ -85,6 +175,7 @@ func Rip7560Mode() {
 			conf.MaxBatchGasLimit,
 		),
 	)
+	c.SetMinBuilderFee(conf.MinBuilderFee)
 	c.UseLogger(logr)
 	c.UseModules(
 		rep.CheckStatus(),
@@ -94,25 +185,59 @@ func Rip7560Mode() {
 		rep.IncTxsSeen(),
 	)
 
+	// Init simulation of each batch in order on chained state
+	sim := bundlesim.New(
+		bundlesim.GetBalanceWithEthClient(eth),
+		bundlesim.ExecuteWithRpcClient(rpc),
+		trace,
+	)
+	sim.UseReputation(rep)
+
 	// Init Bundler
 	b := bundler.New(mem, chain)
 	b.SetGetBaseFeeFunc(gasprice.GetBaseFeeWithEthClient(eth))
 	b.SetGetGasTipFunc(gasprice.GetGasTipWithEthClient(eth))
 	b.SetGetLegacyGasPriceFunc(gasprice.GetLegacyGasPriceWithEthClient(eth))
+	b.SetGetHeaderFunc(bundler.GetHeaderWithEthClient(eth))
+	strategy, err := bundler.GetStrategy(conf.BundleStrategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b.SetBundleStrategy(strategy)
+	b.UseHistory(hist)
 	b.UseLogger(logr)
 	if err := b.UserMeter(otel.GetMeterProvider().Meter("bundler")); err != nil {
 		log.Fatal(err)
 	}
-	b.UseModules(
+	b.UsePackFilters(
 		exp.DropExpired(),
-		gasprice.SortByGasPrice(),
 		gasprice.FilterUnderpriced(),
+		gasprice.FilterBuilderFee(conf.MinBuilderFee),
+	)
+	b.UseModules(
 		batch.SortByNonce(),
 		check.CodeHashes(),
-		rep.IncTxsIncluded(),
+		sim.SimulateBatch(),
 		check.Clean(),
 	)
 
+	// Init pushing of bundles to block builders in searcher mode
+	if conf.Mode == "searcher" {
+		s, err := searcher.New(
+			eoa,
+			mem,
+			conf.EthBuilderUrls,
+			b.UsePushMode(),
+			transaction.GetRip7560BundleArgs{MaxBundleGas: conf.MaxBatchGasLimit.Uint64()},
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.UseLogger(logr)
+		heads.Subscribe("searcher", s.Submit)
+	}
+	go heads.Run(context.Background())
+
 	// init Debug
 	var d *client.Debug
 	if conf.DebugMode {
@@ -122,7 +247,7 @@ func Rip7560Mode() {
 	// Init HTTP server
 	gin.SetMode(conf.GinMode)
 	r := gin.New()
-	if err := r.SetTrustedProxies(nil); err != nil {
+	if err := r.SetTrustedProxies(conf.TrustedProxies); err != nil {
 		log.Fatal(err)
 	}
 	r.Use(
@@ -133,13 +258,30 @@ func Rip7560Mode() {
 	r.GET("/ping", func(g *gin.Context) {
 		g.Status(http.StatusOK)
 	})
+	rpcAdapter := client.NewRpcAdapter(c, b, d)
 	handlers := []gin.HandlerFunc{
-		jsonrpc.Controller(client.NewRpcAdapter(c, b, d)),
+		jsonrpc.ControllerWithFactory(func(g *gin.Context) interface{} {
+			return client.WithClientIP(rpcAdapter, g.ClientIP())
+		}),
 		//jsonrpc.WithOTELTracerAttributes(),
 	}
 	r.POST("/", handlers...)
 	r.POST("/rpc", handlers...)
 
+	// Mempool lifecycle events are streamed as server-sent events. Each subscriber gets its own buffer and is
+	// told how many events it missed if it falls behind.
+	r.GET("/events", c.StreamEvents(eventsBuffer))
+
+	// Bundle outcomes reported by the sequencer can drop txs and penalize entities. These are only served on a
+	// separate route that requires the shared secret.
+	if conf.ReportSecret != "" {
+		r.POST(
+			"/report",
+			ginutils.RequireBearerToken(conf.ReportSecret),
+			jsonrpc.Controller(client.NewReportRpcAdapter(tr)),
+		)
+	}
+
 	if err := r.Run(fmt.Sprintf(":%d", conf.Port)); err != nil {
 		log.Fatal(err)
 	}
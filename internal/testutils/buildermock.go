@@ -19,3 +19,18 @@ func BadBuilderRpcMock() *httptest.Server {
 		}
 	}))
 }
+
+// BuilderRpcMock returns a builder that accepts every bundle with the given bundle hash.
+func BuilderRpcMock(bundleHash string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result":  &flashbotsrpc.FlashbotsSendBundleResponse{BundleHash: bundleHash},
+		}
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(err)
+		}
+	}))
+}
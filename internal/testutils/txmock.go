@@ -16,12 +16,15 @@ var (
 	MockRip7560TxData = `{
 		"from":                          "0x0000000000000000000000000000000000007560",
 		"to":                            null,
-		"value":						 "0x0",
-		"gasPrice":                      "0x1",
-		"maxFeePerGas":                  "0x2",
-		"maxPriorityFeePerGas":          "0x1",
+		"value":                         "0x0",
+		"gasPrice":                      "0x3B9ACA00",
+		"maxFeePerGas":                  "0x3B9ACA00",
+		"maxPriorityFeePerGas":          "0x5F5E100",
 		"data":                          "0xb61d27f6000000000000000000000000e7f1725e7734ce288f8367e1bb143e90bb3f05120000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000006000000000000000000000000000000000000000000000000000000000000000246057361d000000000000000000000000000000000000000000000000000000000000004d00000000000000000000000000000000000000000000000000000000",
+		"executionData":                 "0xb61d27f6000000000000000000000000e7f1725e7734ce288f8367e1bb143e90bb3f05120000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000006000000000000000000000000000000000000000000000000000000000000000246057361d000000000000000000000000000000000000000000000000000000000000004d00000000000000000000000000000000000000000000000000000000",
 		"sender":                        "0xAEd18Be2bc078345cd0c2ae5f4DA16230E5ac0d4",
+		"deployer":                      "0x9406Cc6185a346906296840746125a0E44976454",
+		"deployerData":                  "0x1234abcd",
 		"paymaster":                     "0x7560000000000000000000000000000000000000",
 		"paymasterData":                 "0x",
 		"builderFee":                    "0x186A0",
@@ -29,9 +32,9 @@ var (
 		"verificationGasLimit":          "0xAAE60",
 		"paymasterVerificationGasLimit": "0xAAE60",
 		"paymasterPostOpGasLimit":       "0xAAE60",
-		"subType":                       "0x1",
-		"signature":                     "0x00",
-		"bigNonce":  					 "0x10000000000000000"
+		"authorizationData":             "0x00",
+		"nonce":                         "0x0",
+		"nonceKey":                      "0x0"
 	}`
 	MockByteCode = common.Hex2Bytes("6080604052")
 )
@@ -0,0 +1,215 @@
+// Package altmempools loads alternative mempool definitions. An alternative mempool allows transactions that
+// break specific validation rules for named entities, contracts, and storage slots.
+package altmempools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	RuleForbiddenOpcode      = "forbiddenOpcode"
+	RuleForbiddenPrecompile  = "forbiddenPrecompile"
+	RuleInvalidStorageAccess = "invalidStorageAccess"
+	RuleNotStaked            = "notStaked"
+)
+
+// Exception is a single allowlist entry of an alternative mempool.
+type Exception struct {
+	Description string `json:"description,omitempty"`
+	Rule        string `json:"rule"`
+	Entity      string `json:"entity"`
+	Contract    string `json:"contract,omitempty"`
+	Opcode      string `json:"opcode,omitempty"`
+	Precompile  string `json:"precompile,omitempty"`
+	Slot        string `json:"slot,omitempty"`
+}
+
+// AltMempool is an alternative mempool definition identified by Id.
+type AltMempool struct {
+	Id          string       `json:"id"`
+	Description string       `json:"description"`
+	ChainIds    []string     `json:"chainIds"`
+	Allowlist   []*Exception `json:"allowlist"`
+}
+
+func (m *AltMempool) hasChain(chainID *big.Int) bool {
+	for _, id := range m.ChainIds {
+		if v, err := hexutil.DecodeBig(id); err == nil && v.Cmp(chainID) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Directory holds the alternative mempools for a chain. A nil Directory has no mempools and grants no
+// exceptions.
+type Directory struct {
+	mempools []*AltMempool
+}
+
+// New returns a Directory from a map of alternative mempool definitions keyed by id. Each definition is
+// validated against the alternative mempool schema. Definitions that do not list chainID are ignored.
+func New(chainID *big.Int, defs map[string]any) (*Directory, error) {
+	ids := []string{}
+	for id := range defs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	mempools := []*AltMempool{}
+	for _, id := range ids {
+		if err := schema.Validate(defs[id]); err != nil {
+			return nil, fmt.Errorf("altmempools: invalid definition %s: %w", id, err)
+		}
+
+		data, err := json.Marshal(defs[id])
+		if err != nil {
+			return nil, err
+		}
+		m := &AltMempool{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+		m.Id = id
+		if m.hasChain(chainID) {
+			mempools = append(mempools, m)
+		}
+	}
+
+	return &Directory{mempools: mempools}, nil
+}
+
+// NewFromFile returns a Directory from a JSON file holding an object of alternative mempool definitions keyed
+// by id.
+func NewFromFile(chainID *big.Int, path string) (*Directory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs map[string]any
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("altmempools: %s: %w", path, err)
+	}
+	return New(chainID, defs)
+}
+
+// GetIds returns the ids of all alternative mempools in the Directory.
+func (d *Directory) GetIds() []string {
+	ids := []string{}
+	if d == nil {
+		return ids
+	}
+	for _, m := range d.mempools {
+		ids = append(ids, m.Id)
+	}
+	return ids
+}
+
+// Only returns a Directory with just the alternative mempools in ids. This is used to validate a transaction
+// under the rules of the mempools it was admitted to.
+func (d *Directory) Only(ids []string) *Directory {
+	only := &Directory{mempools: []*AltMempool{}}
+	if d == nil {
+		return only
+	}
+	for _, m := range d.mempools {
+		for _, id := range ids {
+			if m.Id == id {
+				only.mempools = append(only.mempools, m)
+				break
+			}
+		}
+	}
+	return only
+}
+
+func matchesEntity(e *Exception, name string, addr common.Address) bool {
+	return e.Entity == name || strings.EqualFold(e.Entity, addr.Hex())
+}
+
+func matchesAddress(value string, addr common.Address) bool {
+	return strings.EqualFold(value, addr.Hex())
+}
+
+func matchesSlot(value string, slot string) bool {
+	a, ok := new(big.Int).SetString(value, 0)
+	if !ok {
+		return strings.EqualFold(value, slot)
+	}
+	b, ok := new(big.Int).SetString(slot, 0)
+	return ok && a.Cmp(b) == 0
+}
+
+func (d *Directory) find(rule string, match func(e *Exception) bool) []string {
+	ids := []string{}
+	if d == nil {
+		return ids
+	}
+	for _, m := range d.mempools {
+		for _, e := range m.Allowlist {
+			if e.Rule == rule && match(e) {
+				ids = append(ids, m.Id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// HasForbiddenOpcodeException returns the ids of alternative mempools that allow the entity to use a
+// forbidden opcode in contract.
+func (d *Directory) HasForbiddenOpcodeException(
+	name string,
+	entity common.Address,
+	contract common.Address,
+	opcode string,
+) []string {
+	return d.find(RuleForbiddenOpcode, func(e *Exception) bool {
+		return matchesEntity(e, name, entity) && matchesAddress(e.Contract, contract) && e.Opcode == opcode
+	})
+}
+
+// HasForbiddenPrecompileException returns the ids of alternative mempools that allow the entity to call a
+// forbidden precompile from contract.
+func (d *Directory) HasForbiddenPrecompileException(
+	name string,
+	entity common.Address,
+	contract common.Address,
+	precompile common.Address,
+) []string {
+	return d.find(RuleForbiddenPrecompile, func(e *Exception) bool {
+		return matchesEntity(e, name, entity) &&
+			matchesAddress(e.Contract, contract) &&
+			matchesAddress(e.Precompile, precompile)
+	})
+}
+
+// HasInvalidStorageAccessException returns the ids of alternative mempools that allow the entity to access a
+// storage slot in contract that is otherwise forbidden.
+func (d *Directory) HasInvalidStorageAccessException(
+	name string,
+	entity common.Address,
+	contract common.Address,
+	slot string,
+) []string {
+	return d.find(RuleInvalidStorageAccess, func(e *Exception) bool {
+		return matchesEntity(e, name, entity) && matchesAddress(e.Contract, contract) && matchesSlot(e.Slot, slot)
+	})
+}
+
+// HasNotStakedException returns the ids of alternative mempools that apply the staked entity rules to the
+// entity even though it is not staked.
+func (d *Directory) HasNotStakedException(name string, entity common.Address) []string {
+	return d.find(RuleNotStaked, func(e *Exception) bool {
+		return matchesEntity(e, name, entity)
+	})
+}
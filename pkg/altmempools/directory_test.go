@@ -0,0 +1,85 @@
+package altmempools
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestNewWithValidDefinition verifies that a valid definition is loaded and its exceptions can be looked up.
+func TestNewWithValidDefinition(t *testing.T) {
+	d, err := New(testutils.ChainID, map[string]any{"mock": testutils.AltMempoolMock()})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if ids := d.GetIds(); len(ids) != 1 || ids[0] != "mock" {
+		t.Fatalf("got %v, want [mock]", ids)
+	}
+
+	zero := common.Address{}
+	if ids := d.HasForbiddenOpcodeException("account", testutils.ValidAddress1, zero, "GAS"); len(ids) != 1 {
+		t.Fatalf("got %v, want [mock]", ids)
+	}
+	if ids := d.HasForbiddenOpcodeException("paymaster", testutils.ValidAddress1, zero, "GAS"); len(ids) != 0 {
+		t.Fatalf("got %v, want []", ids)
+	}
+	if ids := d.HasForbiddenPrecompileException("account", testutils.ValidAddress1, zero, zero); len(ids) != 1 {
+		t.Fatalf("got %v, want [mock]", ids)
+	}
+	if ids := d.HasInvalidStorageAccessException("account", testutils.ValidAddress1, zero, "0x00"); len(ids) != 1 {
+		t.Fatalf("got %v, want [mock]", ids)
+	}
+	if ids := d.HasNotStakedException("paymaster", zero); len(ids) != 1 {
+		t.Fatalf("got %v, want [mock]", ids)
+	}
+	if ids := d.HasNotStakedException("paymaster", testutils.ValidAddress1); len(ids) != 0 {
+		t.Fatalf("got %v, want []", ids)
+	}
+}
+
+// TestNewWithInvalidDefinition verifies that a definition not matching the schema is rejected.
+func TestNewWithInvalidDefinition(t *testing.T) {
+	def := testutils.AltMempoolMock()
+	def["allowlist"] = []any{map[string]any{"rule": "notStaked", "entity": "bundler"}}
+
+	if _, err := New(testutils.ChainID, map[string]any{"mock": def}); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestNewIgnoresOtherChains verifies that definitions for other chains are not loaded.
+func TestNewIgnoresOtherChains(t *testing.T) {
+	d, err := New(big.NewInt(10), map[string]any{"mock": testutils.AltMempoolMock()})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if ids := d.GetIds(); len(ids) != 0 {
+		t.Fatalf("got %v, want []", ids)
+	}
+}
+
+// TestOnly verifies that a Directory can be limited to a subset of ids and that a nil Directory grants no
+// exceptions.
+func TestOnly(t *testing.T) {
+	d, err := New(testutils.ChainID, map[string]any{
+		"a": testutils.AltMempoolMock(),
+		"b": testutils.AltMempoolMock(),
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if ids := d.Only([]string{"b", "c"}).GetIds(); len(ids) != 1 || ids[0] != "b" {
+		t.Fatalf("got %v, want [b]", ids)
+	}
+	if ids := d.Only(nil).HasNotStakedException("account", common.Address{}); len(ids) != 0 {
+		t.Fatalf("got %v, want []", ids)
+	}
+
+	var nilDir *Directory
+	if ids := nilDir.HasNotStakedException("account", common.Address{}); len(ids) != 0 {
+		t.Fatalf("got %v, want []", ids)
+	}
+}
@@ -0,0 +1,84 @@
+package altmempools
+
+import "github.com/santhosh-tekuri/jsonschema/v5"
+
+// schemaJSON describes an alternative mempool definition. Each allowlist entry names a validation rule and
+// the entity and contract it may be broken by. An entity is either a role (i.e. account, deployer, or
+// paymaster) or an address.
+const schemaJSON = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["description", "chainIds", "allowlist"],
+  "properties": {
+    "description": { "type": "string" },
+    "chainIds": {
+      "type": "array",
+      "minItems": 1,
+      "items": { "$ref": "#/$defs/hex" }
+    },
+    "allowlist": {
+      "type": "array",
+      "items": {
+        "oneOf": [
+          { "$ref": "#/$defs/forbiddenOpcode" },
+          { "$ref": "#/$defs/forbiddenPrecompile" },
+          { "$ref": "#/$defs/invalidStorageAccess" },
+          { "$ref": "#/$defs/notStaked" }
+        ]
+      }
+    }
+  },
+  "$defs": {
+    "hex": { "type": "string", "pattern": "^0x[0-9a-fA-F]+$" },
+    "address": { "type": "string", "pattern": "^0x[0-9a-fA-F]{40}$" },
+    "entity": { "type": "string", "pattern": "^(account|deployer|paymaster|0x[0-9a-fA-F]{40})$" },
+    "forbiddenOpcode": {
+      "type": "object",
+      "required": ["rule", "entity", "contract", "opcode"],
+      "additionalProperties": false,
+      "properties": {
+        "description": { "type": "string" },
+        "rule": { "const": "forbiddenOpcode" },
+        "entity": { "$ref": "#/$defs/entity" },
+        "contract": { "$ref": "#/$defs/address" },
+        "opcode": { "type": "string", "pattern": "^[A-Z0-9]+$" }
+      }
+    },
+    "forbiddenPrecompile": {
+      "type": "object",
+      "required": ["rule", "entity", "contract", "precompile"],
+      "additionalProperties": false,
+      "properties": {
+        "description": { "type": "string" },
+        "rule": { "const": "forbiddenPrecompile" },
+        "entity": { "$ref": "#/$defs/entity" },
+        "contract": { "$ref": "#/$defs/address" },
+        "precompile": { "$ref": "#/$defs/address" }
+      }
+    },
+    "invalidStorageAccess": {
+      "type": "object",
+      "required": ["rule", "entity", "contract", "slot"],
+      "additionalProperties": false,
+      "properties": {
+        "description": { "type": "string" },
+        "rule": { "const": "invalidStorageAccess" },
+        "entity": { "$ref": "#/$defs/entity" },
+        "contract": { "$ref": "#/$defs/address" },
+        "slot": { "$ref": "#/$defs/hex" }
+      }
+    },
+    "notStaked": {
+      "type": "object",
+      "required": ["rule", "entity"],
+      "additionalProperties": false,
+      "properties": {
+        "description": { "type": "string" },
+        "rule": { "const": "notStaked" },
+        "entity": { "$ref": "#/$defs/entity" }
+      }
+    }
+  }
+}`
+
+var schema = jsonschema.MustCompileString("altmempool.schema.json", schemaJSON)
@@ -0,0 +1,18 @@
+// Package blocks provides functions for reading the latest block that are shared by the bundler modules.
+package blocks
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// GetBlockNumberFunc returns the number of the latest block.
+type GetBlockNumberFunc = func() (uint64, error)
+
+// GetBlockNumberWithEthClient returns a GetBlockNumberFunc that relies on an eth client.
+func GetBlockNumberWithEthClient(eth *ethclient.Client) GetBlockNumberFunc {
+	return func() (uint64, error) {
+		return eth.BlockNumber(context.Background())
+	}
+}
@@ -0,0 +1,118 @@
+package blocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+)
+
+// NewHeadFunc handles a new block. If it returns an error, the same block is passed to it again on the next
+// poll.
+type NewHeadFunc = func(bn uint64) error
+
+type subscriber struct {
+	name string
+	fn   NewHeadFunc
+	head chan uint64
+}
+
+// notify replaces any block number that the subscriber has not handled yet with bn. Only the Notifier sends to
+// head, so the send cannot block once it is drained.
+func (s *subscriber) notify(bn uint64) {
+	select {
+	case <-s.head:
+	default:
+	}
+	s.head <- bn
+}
+
+// Notifier polls for the latest block number and passes each new one to its subscribers. Every subscriber runs
+// on its own goroutine so that a slow one does not hold up the others. A subscriber that falls behind is only
+// given the latest block.
+type Notifier struct {
+	gbn          GetBlockNumberFunc
+	pollInterval time.Duration
+	subs         []*subscriber
+	logger       logr.Logger
+}
+
+// NewNotifier returns a Notifier that reads the latest block number with gbn.
+func NewNotifier(gbn GetBlockNumberFunc) *Notifier {
+	return &Notifier{
+		gbn:          gbn,
+		pollInterval: time.Second,
+		logger:       logger.NewZeroLogr().WithName("blocks"),
+	}
+}
+
+// UseLogger defines the logger object used by the Notifier instance based on the go-logr/logr interface.
+func (n *Notifier) UseLogger(logger logr.Logger) {
+	n.logger = logger.WithName("blocks")
+}
+
+// SetPollInterval defines how often the Notifier checks for a new block.
+func (n *Notifier) SetPollInterval(d time.Duration) {
+	n.pollInterval = d
+}
+
+// Subscribe registers fn to be called with the number of each new block. Errors returned by fn are logged
+// under the given name. It must be called before Run.
+func (n *Notifier) Subscribe(name string, fn NewHeadFunc) {
+	n.subs = append(n.subs, &subscriber{name: name, fn: fn, head: make(chan uint64, 1)})
+}
+
+// handle calls the subscriber with every block number it is notified of until the context is cancelled. A
+// block is only marked as done once fn succeeds so that it is retried on the next poll.
+func (n *Notifier) handle(ctx context.Context, sub *subscriber) {
+	var last uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case bn := <-sub.head:
+			if bn == last {
+				continue
+			}
+			if err := sub.fn(bn); err != nil {
+				n.logger.Error(err, "new head error", "subscriber", sub.name, "block_number", bn)
+				continue
+			}
+			last = bn
+		}
+	}
+}
+
+// Run polls for the latest block number until the context is cancelled and notifies every subscriber each
+// time.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, sub := range n.subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			n.handle(ctx, sub)
+		}(sub)
+	}
+
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bn, err := n.gbn()
+			if err != nil {
+				n.logger.Error(err, "new head error")
+				continue
+			}
+			for _, sub := range n.subs {
+				sub.notify(bn)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package blocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestNotifierRetriesFailedBlock verifies that a subscriber is given a block again after it fails and is not
+// called again for the same block once it succeeds.
+func TestNotifierRetriesFailedBlock(t *testing.T) {
+	n := NewNotifier(func() (uint64, error) { return 1, nil })
+	n.SetPollInterval(time.Millisecond)
+
+	calls := make(chan uint64, 10)
+	fail := true
+	n.Subscribe("test", func(bn uint64) error {
+		calls <- bn
+		if fail {
+			fail = false
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case bn := <-calls:
+			if bn != 1 {
+				t.Fatalf("got block %d, want 1", bn)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got %d calls, want 2", i)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	if len(calls) != 0 {
+		t.Fatalf("got %d extra calls, want 0", len(calls))
+	}
+}
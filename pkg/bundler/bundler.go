@@ -3,14 +3,17 @@ package bundler
 
 import (
 	"context"
+	"errors"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"math"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
@@ -19,16 +22,26 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
+// ErrPullDisabled is returned when a bundle is requested from a Bundler that pushes its bundles to block
+// builders instead.
+var ErrPullDisabled = errors.New("bundles are pushed to block builders and cannot be requested")
+
 // Bundler controls the end to end process of creating a batch of RIP-7560 transactions from the mempool and serveing
 // it to the sequencer.
 type Bundler struct {
 	mempool      *mempool.Mempool
 	chainID      *big.Int
+	packFilter   modules.BatchHandlerFunc
 	batchHandler modules.BatchHandlerFunc
+	strategy     BundleStrategy
+	pushMode     bool
+	history      *history.History
+	buildMu      sync.Mutex
 	logger       logr.Logger
 	meter        metric.Meter
 	gbf          gasprice.GetBaseFeeFunc
 	ggt          gasprice.GetGasTipFunc
+	gh           GetHeaderFunc
 	// TODO : is this needed?
 	ggp gasprice.GetLegacyGasPriceFunc
 }
@@ -39,11 +52,14 @@ func New(mempool *mempool.Mempool, chainID *big.Int) *Bundler {
 	return &Bundler{
 		mempool:      mempool,
 		chainID:      chainID,
+		packFilter:   notx.BatchHandler,
 		batchHandler: notx.BatchHandler,
+		strategy:     MaxRevenue(),
 		logger:       logger.NewZeroLogr().WithName("bundler"),
 		meter:        otel.GetMeterProvider().Meter("bundler"),
 		gbf:          gasprice.NotxGetBaseFeeFunc(),
 		ggt:          gasprice.NotxGetGasTipFunc(),
+		gh:           getHeaderNotx(),
 		ggp:          gasprice.NotxGetLegacyGasPriceFunc(),
 	}
 }
@@ -58,12 +74,24 @@ func (i *Bundler) SetGetGasTipFunc(ggt gasprice.GetGasTipFunc) {
 	i.ggt = ggt
 }
 
+// SetGetHeaderFunc defines the function used to retrieve block headers when estimating the last block a bundle
+// is valid for.
+func (i *Bundler) SetGetHeaderFunc(gh GetHeaderFunc) {
+	i.gh = gh
+}
+
 // SetGetLegacyGasPriceFunc defines the function used to retrieve an estimate for gas price during each
 // bundler run.
 func (i *Bundler) SetGetLegacyGasPriceFunc(ggp gasprice.GetLegacyGasPriceFunc) {
 	i.ggp = ggp
 }
 
+// SetBundleStrategy defines the BundleStrategy used to select RIP-7560 transactions from the mempool during
+// each bundler run.
+func (i *Bundler) SetBundleStrategy(s BundleStrategy) {
+	i.strategy = s
+}
+
 // UseLogger defines the logger object used by the Bundler instance based on the go-logr/logr interface.
 func (i *Bundler) UseLogger(logger logr.Logger) {
 	i.logger = logger.WithName("bundler")
@@ -76,13 +104,7 @@ func (i *Bundler) UserMeter(meter metric.Meter) error {
 	_, err := i.meter.Int64ObservableGauge(
 		"bundler_mempool_size",
 		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
-			size := 0
-			batch, err := i.mempool.Dump()
-			if err != nil {
-				return err
-			}
-			size += len(batch)
-			io.Observe(int64(size))
+			io.Observe(int64(i.mempool.PendingCount()))
 			return nil
 		}),
 	)
@@ -94,7 +116,74 @@ func (i *Bundler) UseModules(handlers ...modules.BatchHandlerFunc) {
 	i.batchHandler = modules.ComposeBatchHandlerFunc(handlers...)
 }
 
+// UsePackFilters defines cheap BatchHandlers that check each transaction on its own, such as expiry and gas
+// price filters. They run as soon as a batch is packed and before any of the modules. If they remove a
+// transaction, the rest of its (sender, nonce key) sequence is excluded and the batch is packed again so that
+// the space is filled by other transactions.
+func (i *Bundler) UsePackFilters(handlers ...modules.BatchHandlerFunc) {
+	i.packFilter = modules.ComposeBatchHandlerFunc(handlers...)
+}
+
+// pack selects a batch with the bundle strategy and applies the pack filters until they no longer remove any
+// transactions. The returned context holds the batch along with the transactions removed by the filters.
+func (i *Bundler) pack(in *StrategyInput, tip *big.Int, gasPrice *big.Int) (*modules.BatchHandlerCtx, error) {
+	in.excluded = make(map[string]bool)
+	removed := []*modules.PendingRemovalItem{}
+	deferred := []*modules.PendingRemovalItem{}
+	for {
+		batch, err := i.strategy.Select(in)
+		if err != nil {
+			return nil, err
+		}
+		ctx := modules.NewBatchHandlerContext(batch, i.chainID, in.BaseFee, tip, gasPrice)
+		if err := i.packFilter(ctx); err != nil {
+			return nil, err
+		}
+		removed = append(removed, ctx.PendingRemoval...)
+		deferred = append(deferred, ctx.Deferred...)
+
+		kept := make(map[*transaction.TransactionArgs]bool)
+		for _, tx := range ctx.Batch {
+			kept[tx] = true
+		}
+		missed := false
+		for _, tx := range batch {
+			if !kept[tx] {
+				in.excluded[getLaneKey(tx)] = true
+				missed = true
+			}
+		}
+		if !missed {
+			ctx.PendingRemoval = removed
+			ctx.Deferred = deferred
+			return ctx, nil
+		}
+	}
+}
+
+// UsePushMode stops the Bundler from serving bundles through GetRip7560Bundle and returns the function that
+// builds them instead. This is used when bundles are pushed to block builders so that a sequencer requesting
+// bundles at the same time cannot be served the same transactions.
+func (i *Bundler) UsePushMode() func(args transaction.GetRip7560BundleArgs) (*transaction.GetRip7560BundleResult, error) {
+	i.pushMode = true
+	return i.buildRip7560Bundle
+}
+
+// GetRip7560Bundle builds a bundle from the mempool within the limits requested by the sequencer. The
+// transactions in it are moved to in-flight. ErrPullDisabled is returned if the Bundler is in push mode.
 func (i *Bundler) GetRip7560Bundle(args transaction.GetRip7560BundleArgs) (*transaction.GetRip7560BundleResult, error) {
+	if i.pushMode {
+		return nil, ErrPullDisabled
+	}
+	return i.buildRip7560Bundle(args)
+}
+
+// buildRip7560Bundle runs the bundler from selection to moving the bundled transactions to in-flight. Runs are
+// serialized so that concurrent requests cannot select and serve the same pending transactions.
+func (i *Bundler) buildRip7560Bundle(args transaction.GetRip7560BundleArgs) (*transaction.GetRip7560BundleResult, error) {
+	i.buildMu.Lock()
+	defer i.buildMu.Unlock()
+
 	// Init logger
 	start := time.Now()
 	l := i.logger.
@@ -102,22 +191,13 @@ func (i *Bundler) GetRip7560Bundle(args transaction.GetRip7560BundleArgs) (*tran
 		WithValues("chain_id", i.chainID.String())
 
 	result := &transaction.GetRip7560BundleResult{
-		Bundle: make([]transaction.TransactionArgs, 0),
-		// TODO : is this needed?
+		Bundle:        make([]transaction.TransactionArgs, 0),
 		ValidForBlock: (*hexutil.Big)(big.NewInt(math.MaxInt64)),
 	}
 
-	// Get all pending RIP-7560 transactions from the mempool. This will be in FIFO order. Downstream modules should sort it
-	// based on more specific strategies.
-	batch, err := i.mempool.Dump()
-	if err != nil {
-		l.Error(err, "bundler run error")
-		return result, err
-	}
-	if len(batch) == 0 {
+	if i.mempool.PendingCount() == 0 {
 		return result, nil
 	}
-	batch = adjustBatchSize(int(args.MaxBundleSize), batch)
 
 	// Get current block basefee
 	bf, err := i.gbf()
@@ -143,26 +223,60 @@ func (i *Bundler) GetRip7560Bundle(args transaction.GetRip7560BundleArgs) (*tran
 		return nil, err
 	}
 
-	// Create context and execute modules.
-	ctx := modules.NewBatchHandlerContext(batch, i.chainID, bf, gt, gp)
+	// Select the executable RIP-7560 transactions from the mempool that fit within the sequencer's limits
+	// using the bundle strategy and pass the pack filters. Each sender's transactions will be in nonce order.
+	pbf := getBundleBaseFee(bf, args.MinBaseFee)
+	ctx, err := i.pack(&StrategyInput{
+		Mempool: i.mempool,
+		BaseFee: pbf,
+		MaxGas:  args.MaxBundleGas,
+		MaxSize: int(args.MaxBundleSize),
+	}, gt, gp)
+	if err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	}
+	if len(ctx.Batch) == 0 && len(ctx.PendingRemoval) == 0 {
+		return result, nil
+	}
+	gas := uint64(0)
+	for _, tx := range ctx.Batch {
+		gas += tx.GetTotalGasLimit()
+	}
+	l = l.WithValues("packed_gas", gas)
+
+	// Execute modules.
 	if err := i.batchHandler(ctx); err != nil {
 		l.Error(err, "bundler run error")
 		return nil, err
 	}
 
+	// Compute the range the bundle is valid for before its transactions and their metadata leave the mempool.
+	va, vu := getValidityWindow(i.mempool, ctx.Batch)
+	vfb, err := getValidForBlock(i.gh, vu)
+	if err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	}
+	result.ValidForBlock = (*hexutil.Big)(vfb)
+	result.ValidAfter = hexutil.Uint64(va)
+	result.ValidUntil = hexutil.Uint64(vu)
+
 	// Remove RIP-7560 transactions that remain in the context from mempool.
-	rmTxs := append([]*transaction.TransactionArgs{}, ctx.Batch...)
+	if err := i.mempool.BundleTxs(ctx.Batch...); err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	}
 	dh := []string{}
 	dr := []string{}
 	for _, item := range ctx.PendingRemoval {
-		rmTxs = append(rmTxs, item.Tx)
+		if err := i.mempool.DropTxs(item.Reason, item.Tx); err != nil {
+			l.Error(err, "bundler run error")
+			return nil, err
+		}
 		dh = append(dh, item.Tx.ToTransaction().Hash().String())
 		dr = append(dr, item.Reason)
 	}
-	if err := i.mempool.RemoveTxs(rmTxs...); err != nil {
-		l.Error(err, "bundler run error")
-		return nil, err
-	}
 
 	// Add tx to result && Update logs for the current run.
 	bat := []string{}
@@ -173,10 +287,29 @@ func (i *Bundler) GetRip7560Bundle(args transaction.GetRip7560BundleArgs) (*tran
 	l = l.WithValues("batch_aatx_hashes", bat)
 	l = l.WithValues("dropped_aatx_hashes", dh)
 	l = l.WithValues("dropped_aatx_reasons", dr)
+	fh := []string{}
+	fr := []string{}
+	for _, item := range ctx.Deferred {
+		fh = append(fh, item.Tx.ToTransaction().Hash().String())
+		fr = append(fr, item.Reason)
+	}
+	l = l.WithValues("deferred_aatx_hashes", fh)
+	l = l.WithValues("deferred_aatx_reasons", fr)
 
 	for k, v := range ctx.Data {
 		l = l.WithValues(k, v)
 	}
+
+	// Save a record of the served bundle. The transactions have already left the mempool so a failure here
+	// is logged without failing the run.
+	if i.history != nil {
+		hb := newHistoryBundle(args, ctx, result, time.Now())
+		if err := i.history.Add(hb); err != nil {
+			l.Error(err, "bundle history error")
+		} else {
+			l = l.WithValues("bundle_id", hb.ID)
+		}
+	}
 	l = l.WithValues("duration", time.Since(start))
 	l.Info("bundler run ok")
 	return result, nil
@@ -0,0 +1,71 @@
+package bundler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestUsePushModeDisablesPull verifies that bundles can no longer be requested once the Bundler pushes them to
+// block builders and that the returned function still builds them.
+func TestUsePushModeDisablesPull(t *testing.T) {
+	tx := mockTx(testutils.ValidAddress1, 0, 100, 100, 10)
+	b := New(newMempoolWithTxs(t, tx), testutils.ChainID)
+	build := b.UsePushMode()
+
+	args := transaction.GetRip7560BundleArgs{}
+	if _, err := b.GetRip7560Bundle(args); !errors.Is(err, ErrPullDisabled) {
+		t.Fatalf("got %v, want ErrPullDisabled", err)
+	}
+	res, err := build(args)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(res.Bundle) != 1 || !testutils.IsTxsEqual(&res.Bundle[0], tx) {
+		t.Fatalf("got %v, want tx in bundle", res.Bundle)
+	}
+}
+
+// TestConcurrentBundlesAreDisjoint verifies that concurrent bundle requests never serve the same transaction
+// twice.
+func TestConcurrentBundlesAreDisjoint(t *testing.T) {
+	txs := []*transaction.TransactionArgs{}
+	for i := byte(1); i <= 20; i++ {
+		txs = append(txs, mockTx(common.Address{i}, 0, 100, 100, 10))
+	}
+	b := New(newMempoolWithTxs(t, txs...), testutils.ChainID)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[common.Hash]int)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := b.GetRip7560Bundle(transaction.GetRip7560BundleArgs{MaxBundleSize: 3})
+			if err != nil {
+				t.Errorf("got %v, want nil", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, tx := range res.Bundle {
+				seen[tx.ToTransaction().Hash()]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != len(txs) {
+		t.Fatalf("got %d bundled txs, want %d", len(seen), len(txs))
+	}
+	for h, n := range seen {
+		if n != 1 {
+			t.Fatalf("got tx %s in %d bundles, want 1", h, n)
+		}
+	}
+}
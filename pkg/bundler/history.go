@@ -0,0 +1,69 @@
+package bundler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// ErrHistoryDisabled is returned when querying the bundle history of a Bundler without a History instance.
+var ErrHistoryDisabled = errors.New("bundle history is not enabled")
+
+// UseHistory defines the History instance used to persist every bundle served to the sequencer.
+func (i *Bundler) UseHistory(h *history.History) {
+	i.history = h
+}
+
+// GetBundleHistory returns up to limit served bundles starting from the most recent one before the given
+// bundle ID. A before value of 0 starts from the most recent bundle.
+func (i *Bundler) GetBundleHistory(limit int, before uint64) ([]*history.Bundle, error) {
+	if i.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return i.history.List(limit, before)
+}
+
+// GetBundleByTxHash returns the latest served bundle that included the given RIP-7560 transaction hash or nil
+// if it is unknown.
+func (i *Bundler) GetBundleByTxHash(hash common.Hash) (*history.Bundle, error) {
+	if i.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return i.history.GetByTxHash(hash)
+}
+
+func newRemovedTxs(items []*modules.PendingRemovalItem) []*history.RemovedTx {
+	txs := []*history.RemovedTx{}
+	for _, item := range items {
+		txs = append(txs, &history.RemovedTx{Hash: item.Tx.ToTransaction().Hash(), Reason: item.Reason})
+	}
+	return txs
+}
+
+func newHistoryBundle(
+	args transaction.GetRip7560BundleArgs,
+	ctx *modules.BatchHandlerCtx,
+	result *transaction.GetRip7560BundleResult,
+	servedAt time.Time,
+) *history.Bundle {
+	txs := []*history.BundleTx{}
+	for _, tx := range ctx.Batch {
+		txs = append(txs, &history.BundleTx{Hash: tx.ToTransaction().Hash(), Status: history.TxStatusServed})
+	}
+
+	return &history.Bundle{
+		Args:          args,
+		Txs:           txs,
+		Dropped:       newRemovedTxs(ctx.PendingRemoval),
+		Deferred:      newRemovedTxs(ctx.Deferred),
+		BaseFee:       (*hexutil.Big)(ctx.BaseFee),
+		Tip:           (*hexutil.Big)(ctx.Tip),
+		ValidForBlock: result.ValidForBlock,
+		ServedAt:      servedAt,
+	}
+}
@@ -0,0 +1,68 @@
+package bundler
+
+import (
+	"math/big"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func getLaneKey(tx *transaction.TransactionArgs) string {
+	return dbutils.JoinValues(tx.GetSender().String(), tx.GetNonceKey().String())
+}
+
+// getBundleBaseFee returns the base fee that transactions in the bundle must be able to pay. This is the
+// larger of the current base fee and the minimum requested by the sequencer. Nil is returned if neither is
+// known.
+func getBundleBaseFee(bf *big.Int, minBaseFee uint64) *big.Int {
+	min := new(big.Int).SetUint64(minBaseFee)
+	if bf == nil {
+		if minBaseFee == 0 {
+			return nil
+		}
+		return min
+	}
+	if bf.Cmp(min) < 0 {
+		return min
+	}
+	return bf
+}
+
+// packBatch selects executable transactions from the mempool that fit within the gas and size limits of the
+// input. Transactions are picked greedily from the highest tip above the base fee plus builder fee per gas,
+// which is the value per unit of gas to the bundler. A transaction that does not fit is skipped along with the
+// rest of its (sender, nonce key) sequence and smaller transactions are tried in its place. Transactions that
+// cannot pay the base fee or belong to an excluded sequence are never included.
+func packBatch(in *StrategyInput) ([]*transaction.TransactionArgs, uint64) {
+	baseFee, maxGas, maxSize := in.BaseFee, in.MaxGas, in.MaxSize
+	batch := []*transaction.TransactionArgs{}
+	skipped := make(map[string]bool)
+	gas := uint64(0)
+	in.Mempool.IterateByPrice(baseFee, func(tx *transaction.TransactionArgs) bool {
+		if baseFee != nil && tx.GetEffectiveBid(baseFee).Cmp(baseFee) < 0 {
+			// Transactions are ordered by bid so none of the remaining ones can pay the base fee either.
+			return false
+		}
+
+		lk := getLaneKey(tx)
+		if skipped[lk] || in.excluded[lk] {
+			return true
+		}
+		if baseFee != nil && tx.GetDynamicGasPrice(baseFee).Cmp(baseFee) < 0 {
+			// A builder fee does not make up for a max fee per gas below the base fee.
+			skipped[lk] = true
+			return true
+		}
+		txGas := tx.GetTotalGasLimit()
+		if maxGas > 0 && gas+txGas > maxGas {
+			skipped[lk] = true
+			return true
+		}
+
+		batch = append(batch, tx)
+		gas += txGas
+		return (maxSize <= 0 || len(batch) < maxSize) && (maxGas == 0 || gas < maxGas)
+	})
+
+	return batch, gas
+}
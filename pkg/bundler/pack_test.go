@@ -0,0 +1,137 @@
+package bundler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func mockTx(sender common.Address, nonce uint64, gas uint64, maxFee int64, tip int64) *transaction.TransactionArgs {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Sender = &sender
+	tx.Nonce = (*hexutil.Uint64)(&nonce)
+	tx.Gas = (*hexutil.Uint64)(&gas)
+	zero := hexutil.Uint64(0)
+	tx.ValidationGas, tx.PaymasterGas, tx.PostOpGas = &zero, &zero, &zero
+	tx.MaxFeePerGas = (*hexutil.Big)(big.NewInt(maxFee))
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(tip))
+	tx.BuilderFee = nil
+	return tx
+}
+
+func newMempoolWithTxs(t *testing.T, txs ...*transaction.TransactionArgs) *mempool.Mempool {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	mem.SetGetNonceFunc(func(sender common.Address, key *big.Int) (uint64, error) {
+		return 0, nil
+	})
+	for _, tx := range txs {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	return mem
+}
+
+// TestPackBatchMaxGas verifies that packBatch skips RIP-7560 transactions that do not fit within the gas limit
+// and fills the remaining space with smaller ones.
+func TestPackBatchMaxGas(t *testing.T) {
+	big1 := mockTx(common.Address{0x01}, 0, 600, 100, 10)
+	big2 := mockTx(common.Address{0x02}, 0, 600, 100, 9)
+	next := mockTx(common.Address{0x02}, 1, 100, 100, 100)
+	small := mockTx(common.Address{0x03}, 0, 300, 100, 1)
+	mem := newMempoolWithTxs(t, big1, big2, next, small)
+
+	batch, gas := packBatch(&StrategyInput{Mempool: mem, BaseFee: big.NewInt(0), MaxGas: 1000, MaxSize: 0})
+	if len(batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(batch))
+	} else if !testutils.IsTxsEqual(batch[0], big1) || !testutils.IsTxsEqual(batch[1], small) {
+		t.Fatal("incorrect batch: want highest tip tx followed by tx that fits")
+	} else if gas != 900 {
+		t.Fatalf("got gas %d, want 900", gas)
+	}
+}
+
+// TestPackBatchMinBaseFee verifies that packBatch excludes RIP-7560 transactions that cannot pay the base fee
+// and ranks the rest by tip above it.
+func TestPackBatchMinBaseFee(t *testing.T) {
+	low := mockTx(common.Address{0x01}, 0, 100, 9, 9)
+	capped := mockTx(common.Address{0x02}, 0, 100, 12, 10)
+	high := mockTx(common.Address{0x03}, 0, 100, 100, 5)
+	mem := newMempoolWithTxs(t, low, capped, high)
+
+	bf := getBundleBaseFee(big.NewInt(1), 10)
+	batch, _ := packBatch(&StrategyInput{Mempool: mem, BaseFee: bf, MaxGas: 0, MaxSize: 0})
+	if len(batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(batch))
+	} else if !testutils.IsTxsEqual(batch[0], high) || !testutils.IsTxsEqual(batch[1], capped) {
+		t.Fatal("incorrect batch: want txs ordered by tip above min base fee")
+	}
+}
+
+// TestPackBatchMaxSize verifies that packBatch returns at most maxSize RIP-7560 transactions.
+func TestPackBatchMaxSize(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0, 100, 100, 1)
+	tx2 := mockTx(common.Address{0x02}, 0, 100, 100, 2)
+	tx3 := mockTx(common.Address{0x03}, 0, 100, 100, 3)
+	mem := newMempoolWithTxs(t, tx1, tx2, tx3)
+
+	batch, _ := packBatch(&StrategyInput{Mempool: mem, BaseFee: nil, MaxGas: 0, MaxSize: 2})
+	if len(batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(batch))
+	} else if !testutils.IsTxsEqual(batch[0], tx3) || !testutils.IsTxsEqual(batch[1], tx2) {
+		t.Fatal("incorrect batch: want two highest tip txs")
+	}
+}
+
+// TestPackBatchBuilderFee verifies that packBatch ranks RIP-7560 transactions by tip plus builder fee per gas
+// but never includes one that cannot pay the base fee.
+func TestPackBatchBuilderFee(t *testing.T) {
+	tip := mockTx(common.Address{0x01}, 0, 100, 100, 5)
+	fee := withBuilderFee(mockTx(common.Address{0x02}, 0, 100, 100, 1), 1000)
+	below := withBuilderFee(mockTx(common.Address{0x03}, 0, 100, 9, 9), 10000)
+	mem := newMempoolWithTxs(t, tip, fee, below)
+
+	batch, _ := packBatch(&StrategyInput{Mempool: mem, BaseFee: big.NewInt(10), MaxGas: 0, MaxSize: 0})
+	if len(batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(batch))
+	} else if !testutils.IsTxsEqual(batch[0], fee) || !testutils.IsTxsEqual(batch[1], tip) {
+		t.Fatal("incorrect batch: want tx with builder fee first")
+	}
+}
+
+// TestPackRefillsAfterFilters verifies that a RIP-7560 transaction removed by a pack filter excludes the rest
+// of its sequence and that the space is filled by other transactions.
+func TestPackRefillsAfterFilters(t *testing.T) {
+	a0 := mockTx(common.Address{0x01}, 0, 100, 100, 30)
+	a1 := mockTx(common.Address{0x01}, 1, 100, 100, 30)
+	b0 := mockTx(common.Address{0x02}, 0, 100, 100, 20)
+	c0 := mockTx(common.Address{0x03}, 0, 100, 100, 10)
+	mem := newMempoolWithTxs(t, a0, a1, b0, c0)
+
+	b := New(mem, testutils.ChainID)
+	b.SetBundleStrategy(MaxTip())
+	b.UsePackFilters(func(ctx *modules.BatchHandlerCtx) error {
+		for i, tx := range ctx.Batch {
+			if testutils.IsTxsEqual(tx, a0) {
+				ctx.MarkTxIndexForRemoval(i, "test")
+				break
+			}
+		}
+		return nil
+	})
+
+	ctx, err := b.pack(&StrategyInput{Mempool: mem, BaseFee: big.NewInt(0), MaxSize: 2}, nil, nil)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	assertBatch(t, ctx.Batch, b0, c0)
+	if len(ctx.PendingRemoval) != 1 || !testutils.IsTxsEqual(ctx.PendingRemoval[0].Tx, a0) {
+		t.Fatalf("got %v, want a0 pending removal", ctx.PendingRemoval)
+	}
+}
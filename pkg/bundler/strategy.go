@@ -0,0 +1,263 @@
+package bundler
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+const (
+	// StrategyMaxRevenue picks transactions with the highest tip and builder fee per unit of gas first.
+	StrategyMaxRevenue = "max-revenue"
+
+	// StrategyMaxTip picks transactions with the highest bid per gas above the base fee first using the
+	// mempool's price index.
+	StrategyMaxTip = "max-tip"
+
+	// StrategyFIFO picks transactions in the order they arrived.
+	StrategyFIFO = "fifo"
+
+	// StrategyRoundRobin picks one transaction per sender in turn, starting with the senders that arrived
+	// first.
+	StrategyRoundRobin = "round-robin"
+
+	// DefaultStrategy is the BundleStrategy used if none is set.
+	DefaultStrategy = StrategyMaxRevenue
+)
+
+// StrategyInput is passed to a BundleStrategy when selecting transactions for a bundle.
+type StrategyInput struct {
+	Mempool *mempool.Mempool
+
+	// BaseFee is the fee per gas every transaction in the bundle must be able to pay. It is nil if unknown.
+	BaseFee *big.Int
+
+	// MaxGas and MaxSize limit the total gas and number of transactions in the bundle. A value of 0 disables
+	// the respective limit.
+	MaxGas  uint64
+	MaxSize int
+
+	excluded map[string]bool
+}
+
+// IsExcluded returns true if the (sender, nonce key) sequence of tx must not be picked from. This is set when a
+// batch is packed again after some of its transactions were filtered out.
+func (in *StrategyInput) IsExcluded(tx *transaction.TransactionArgs) bool {
+	return in.excluded[getLaneKey(tx)]
+}
+
+// BundleStrategy selects executable transactions from the mempool for a bundle. Implementations must respect
+// the limits in the input, skip excluded sequences, and keep transactions with the same sender and nonce key in
+// ascending nonce order.
+type BundleStrategy interface {
+	Select(in *StrategyInput) ([]*transaction.TransactionArgs, error)
+}
+
+// BundleStrategyFunc is an adapter to allow the use of an ordinary function as a BundleStrategy.
+type BundleStrategyFunc func(in *StrategyInput) ([]*transaction.TransactionArgs, error)
+
+// Select calls f(in).
+func (f BundleStrategyFunc) Select(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+	return f(in)
+}
+
+var strategies = struct {
+	sync.RWMutex
+	m map[string]BundleStrategy
+}{
+	m: map[string]BundleStrategy{
+		StrategyMaxRevenue: MaxRevenue(),
+		StrategyMaxTip:     MaxTip(),
+		StrategyFIFO:       FIFO(),
+		StrategyRoundRobin: RoundRobin(),
+	},
+}
+
+// RegisterStrategy makes a BundleStrategy available by name so that it can be selected with GetStrategy. An
+// error is returned if the name is empty or already taken.
+func RegisterStrategy(name string, s BundleStrategy) error {
+	strategies.Lock()
+	defer strategies.Unlock()
+	if name == "" {
+		return fmt.Errorf("bundle strategy name cannot be empty")
+	}
+	if _, ok := strategies.m[name]; ok {
+		return fmt.Errorf("bundle strategy already registered: %s", name)
+	}
+
+	strategies.m[name] = s
+	return nil
+}
+
+// GetStrategy returns the BundleStrategy registered with the given name.
+func GetStrategy(name string) (BundleStrategy, error) {
+	strategies.RLock()
+	defer strategies.RUnlock()
+	if s, ok := strategies.m[name]; ok {
+		return s, nil
+	}
+
+	names := make([]string, 0, len(strategies.m))
+	for n := range strategies.m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown bundle strategy %q, must be one of: %s", name, strings.Join(names, ", "))
+}
+
+// MaxTip returns a BundleStrategy that picks transactions greedily from the highest tip above the base fee plus
+// builder fee per gas. Unlike MaxRevenue, the builder fee is rounded down to a whole amount per gas.
+func MaxTip() BundleStrategy {
+	return BundleStrategyFunc(func(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+		batch, _ := packBatch(in)
+		return batch, nil
+	})
+}
+
+// MaxRevenue returns a BundleStrategy that picks transactions greedily from the highest revenue per unit of
+// gas. Revenue is the tip above the base fee for the total gas limit plus the builder fee.
+func MaxRevenue() BundleStrategy {
+	return BundleStrategyFunc(func(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+		rev := make(map[*transaction.TransactionArgs]*big.Int)
+		getRev := func(tx *transaction.TransactionArgs) *big.Int {
+			if r, ok := rev[tx]; ok {
+				return r
+			}
+			r := getRevenue(tx, in.BaseFee)
+			rev[tx] = r
+			return r
+		}
+
+		return PackByPriority(in, func(a, b *Candidate) bool {
+			// Compare revenue per gas without division: ra / ga > rb / gb <=> ra * gb > rb * ga.
+			ra := new(big.Int).Mul(getRev(a.Tx), new(big.Int).SetUint64(b.Tx.GetTotalGasLimit()))
+			rb := new(big.Int).Mul(getRev(b.Tx), new(big.Int).SetUint64(a.Tx.GetTotalGasLimit()))
+			if c := ra.Cmp(rb); c != 0 {
+				return c > 0
+			}
+			return a.Arrival < b.Arrival
+		})
+	})
+}
+
+// FIFO returns a BundleStrategy that picks transactions in the order they arrived.
+func FIFO() BundleStrategy {
+	return BundleStrategyFunc(func(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+		return PackByPriority(in, func(a, b *Candidate) bool {
+			return a.Arrival < b.Arrival
+		})
+	})
+}
+
+// RoundRobin returns a BundleStrategy that picks one transaction per sender in turn so that a single sender
+// cannot fill the bundle while others are waiting. Within a round, senders are picked in arrival order.
+func RoundRobin() BundleStrategy {
+	return BundleStrategyFunc(func(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+		return PackByPriority(in, func(a, b *Candidate) bool {
+			if a.Round != b.Round {
+				return a.Round < b.Round
+			}
+			return a.Arrival < b.Arrival
+		})
+	})
+}
+
+func getRevenue(tx *transaction.TransactionArgs, baseFee *big.Int) *big.Int {
+	tip := new(big.Int).Set(tx.GetDynamicGasPrice(baseFee))
+	if baseFee != nil {
+		tip.Sub(tip, baseFee)
+	}
+	if tip.Sign() < 0 {
+		tip.SetUint64(0)
+	}
+
+	rev := tip.Mul(tip, new(big.Int).SetUint64(tx.GetTotalGasLimit()))
+	return rev.Add(rev, tx.GetBuilderFee())
+}
+
+// Candidate is a transaction that can be picked next by PackByPriority.
+type Candidate struct {
+	Tx *transaction.TransactionArgs
+
+	// Arrival is the position of the transaction in the mempool by arrival time.
+	Arrival int
+
+	// Round is the number of transactions from the same sender already picked for the bundle.
+	Round int
+}
+
+type candidateHeap struct {
+	items []*Candidate
+	less  func(a, b *Candidate) bool
+}
+
+func (h *candidateHeap) Len() int           { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)         { h.items = append(h.items, x.(*Candidate)) }
+func (h *candidateHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
+
+// PackByPriority selects executable transactions from the mempool using less to pick the next transaction.
+// Only the lowest nonce not yet picked from each (sender, nonce key) sequence is a candidate, so nonce order
+// is always kept and the cost of each pick is logarithmic in the number of sequences. A transaction that
+// cannot pay the base fee or does not fit within the gas limit is skipped along with the rest of its
+// sequence, and excluded sequences are left out. Custom strategies can use this to only define an ordering.
+func PackByPriority(
+	in *StrategyInput,
+	less func(a, b *Candidate) bool,
+) ([]*transaction.TransactionArgs, error) {
+	h := &candidateHeap{less: less}
+	for _, head := range in.Mempool.GetLaneHeads() {
+		if !in.IsExcluded(head.Tx) {
+			h.items = append(h.items, &Candidate{Tx: head.Tx, Arrival: head.Arrival})
+		}
+	}
+	heap.Init(h)
+
+	batch := []*transaction.TransactionArgs{}
+	rounds := make(map[string]int)
+	gas := uint64(0)
+	for h.Len() > 0 {
+		if (in.MaxSize > 0 && len(batch) >= in.MaxSize) || (in.MaxGas > 0 && gas >= in.MaxGas) {
+			break
+		}
+
+		c := heap.Pop(h).(*Candidate)
+		sender := c.Tx.GetSender().String()
+		if c.Round != rounds[sender] {
+			// Another sequence from the same sender was picked since this one was queued.
+			c.Round = rounds[sender]
+			heap.Push(h, c)
+			continue
+		}
+		if in.BaseFee != nil && c.Tx.GetDynamicGasPrice(in.BaseFee).Cmp(in.BaseFee) < 0 {
+			continue
+		}
+		txGas := c.Tx.GetTotalGasLimit()
+		if in.MaxGas > 0 && gas+txGas > in.MaxGas {
+			continue
+		}
+
+		batch = append(batch, c.Tx)
+		gas += txGas
+		rounds[sender]++
+
+		if next := in.Mempool.GetNextInLane(c.Tx); next != nil {
+			heap.Push(h, &Candidate{Tx: next.Tx, Arrival: next.Arrival, Round: rounds[sender]})
+		}
+	}
+
+	return batch, nil
+}
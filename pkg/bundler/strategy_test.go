@@ -0,0 +1,100 @@
+package bundler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func withBuilderFee(tx *transaction.TransactionArgs, fee int64) *transaction.TransactionArgs {
+	tx.BuilderFee = (*hexutil.Big)(big.NewInt(fee))
+	return tx
+}
+
+func assertBatch(t *testing.T, batch []*transaction.TransactionArgs, want ...*transaction.TransactionArgs) {
+	t.Helper()
+	if len(batch) != len(want) {
+		t.Fatalf("got batch length %d, want %d", len(batch), len(want))
+	}
+	for i, tx := range batch {
+		if !testutils.IsTxsEqual(tx, want[i]) {
+			t.Fatalf("incorrect order: tx at index %d out of place", i)
+		}
+	}
+}
+
+// TestMaxRevenueCountsBuilderFee verifies that the max-revenue strategy ranks RIP-7560 transactions by tip and
+// builder fee per unit of gas.
+func TestMaxRevenueCountsBuilderFee(t *testing.T) {
+	tip := withBuilderFee(mockTx(common.Address{0x01}, 0, 100, 100, 5), 0)
+	fee := withBuilderFee(mockTx(common.Address{0x02}, 0, 100, 100, 1), 1000)
+	none := withBuilderFee(mockTx(common.Address{0x03}, 0, 100, 100, 2), 0)
+	mem := newMempoolWithTxs(t, tip, fee, none)
+
+	batch, err := MaxRevenue().Select(&StrategyInput{Mempool: mem, BaseFee: big.NewInt(0)})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	assertBatch(t, batch, fee, tip, none)
+}
+
+// TestFIFOKeepsNonceOrder verifies that the fifo strategy picks RIP-7560 transactions by arrival while keeping
+// each sender's transactions in nonce order.
+func TestFIFOKeepsNonceOrder(t *testing.T) {
+	a1 := mockTx(common.Address{0x01}, 1, 100, 100, 1)
+	b0 := mockTx(common.Address{0x02}, 0, 100, 100, 1)
+	a0 := mockTx(common.Address{0x01}, 0, 100, 100, 1)
+	mem := newMempoolWithTxs(t, a1, b0, a0)
+
+	batch, err := FIFO().Select(&StrategyInput{Mempool: mem})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	assertBatch(t, batch, b0, a0, a1)
+}
+
+// TestRoundRobinAcrossSenders verifies that the round-robin strategy picks one RIP-7560 transaction per sender
+// in turn, including senders that use many nonce keys.
+func TestRoundRobinAcrossSenders(t *testing.T) {
+	a0 := mockTx(common.Address{0x01}, 0, 100, 100, 1)
+	a1 := mockTx(common.Address{0x01}, 1, 100, 100, 1)
+	ak := mockTx(common.Address{0x01}, 0, 100, 100, 1)
+	ak.NonceKey = (*hexutil.Big)(big.NewInt(1))
+	b0 := mockTx(common.Address{0x02}, 0, 100, 100, 1)
+	mem := newMempoolWithTxs(t, a0, a1, ak, b0)
+
+	batch, err := RoundRobin().Select(&StrategyInput{Mempool: mem, MaxSize: 3})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	assertBatch(t, batch, a0, b0, a1)
+}
+
+// TestRegisterStrategy verifies that custom strategies can be registered and selected by name.
+func TestRegisterStrategy(t *testing.T) {
+	name := "test-empty"
+	empty := BundleStrategyFunc(func(in *StrategyInput) ([]*transaction.TransactionArgs, error) {
+		return []*transaction.TransactionArgs{}, nil
+	})
+	if err := RegisterStrategy(name, empty); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := RegisterStrategy(name, empty); err == nil {
+		t.Fatal("got nil, want error for duplicate name")
+	}
+	if _, err := GetStrategy(name); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, err := GetStrategy("unknown"); err == nil {
+		t.Fatal("got nil, want error for unknown name")
+	}
+	for _, n := range []string{StrategyMaxRevenue, StrategyMaxTip, StrategyFIFO, StrategyRoundRobin} {
+		if _, err := GetStrategy(n); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+}
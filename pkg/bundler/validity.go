@@ -0,0 +1,83 @@
+package bundler
+
+import (
+	"context"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// GetHeaderFunc returns the header for a block number or the latest block if number is nil.
+type GetHeaderFunc = func(number *big.Int) (*types.Header, error)
+
+// getHeaderNotx returns nil header and nil error.
+func getHeaderNotx() GetHeaderFunc {
+	return func(number *big.Int) (*types.Header, error) {
+		return nil, nil
+	}
+}
+
+// GetHeaderWithEthClient returns a GetHeaderFunc using an eth client.
+func GetHeaderWithEthClient(eth *ethclient.Client) GetHeaderFunc {
+	return func(number *big.Int) (*types.Header, error) {
+		return eth.HeaderByNumber(context.Background(), number)
+	}
+}
+
+// getValidityWindow returns the range of timestamps in which every transaction in the batch is valid based on
+// the validation results saved in the mempool. A validUntil of 0 means there is no upper bound.
+func getValidityWindow(
+	mem *mempool.Mempool,
+	batch []*transaction.TransactionArgs,
+) (validAfter uint64, validUntil uint64) {
+	for _, tx := range batch {
+		meta := mem.GetMetadata(tx)
+		if meta == nil {
+			continue
+		}
+		if meta.ValidAfter > validAfter {
+			validAfter = meta.ValidAfter
+		}
+		if meta.ValidUntil != 0 && (validUntil == 0 || meta.ValidUntil < validUntil) {
+			validUntil = meta.ValidUntil
+		}
+	}
+	return validAfter, validUntil
+}
+
+// getValidForBlock returns the last block number that is expected to have a timestamp before validUntil. The
+// block time is estimated from the latest two blocks. If validUntil is 0 or the latest block is unknown,
+// math.MaxInt64 is returned.
+func getValidForBlock(gh GetHeaderFunc, validUntil uint64) (*big.Int, error) {
+	noLimit := big.NewInt(math.MaxInt64)
+	if validUntil == 0 {
+		return noLimit, nil
+	}
+
+	head, err := gh(nil)
+	if err != nil || head == nil {
+		return noLimit, err
+	}
+	if validUntil <= head.Time {
+		return new(big.Int).Set(head.Number), nil
+	}
+
+	blockTime := uint64(1)
+	if head.Number.Sign() > 0 {
+		parent, err := gh(new(big.Int).Sub(head.Number, big.NewInt(1)))
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil && head.Time > parent.Time {
+			blockTime = head.Time - parent.Time
+		}
+	}
+
+	// The bundle must be included in a block with a timestamp strictly before validUntil.
+	blocks := (validUntil - head.Time - 1) / blockTime
+	return new(big.Int).Add(head.Number, new(big.Int).SetUint64(blocks)), nil
+}
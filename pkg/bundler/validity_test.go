@@ -0,0 +1,76 @@
+package bundler
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func mockGetHeader(number int64, time uint64, blockTime uint64) GetHeaderFunc {
+	return func(n *big.Int) (*types.Header, error) {
+		if n == nil || n.Int64() == number {
+			return &types.Header{Number: big.NewInt(number), Time: time}, nil
+		}
+		return &types.Header{Number: n, Time: time - uint64(number-n.Int64())*blockTime}, nil
+	}
+}
+
+// TestGetValidityWindow verifies that the validity window of a batch is the intersection of the windows saved
+// for each RIP-7560 transaction.
+func TestGetValidityWindow(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0, 100, 100, 1)
+	tx2 := mockTx(common.Address{0x02}, 0, 100, 100, 1)
+	tx3 := mockTx(common.Address{0x03}, 0, 100, 100, 1)
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	for _, item := range []struct {
+		tx   *transaction.TransactionArgs
+		meta *mempool.TxMetadata
+	}{
+		{tx1, &mempool.TxMetadata{ValidAfter: 10, ValidUntil: 0}},
+		{tx2, &mempool.TxMetadata{ValidAfter: 20, ValidUntil: 500}},
+		{tx3, &mempool.TxMetadata{ValidAfter: 5, ValidUntil: 300}},
+	} {
+		if err := mem.AddTxWithMetadata(item.tx, item.meta); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	va, vu := getValidityWindow(mem, []*transaction.TransactionArgs{tx1, tx2, tx3})
+	if va != 20 || vu != 300 {
+		t.Fatalf("got window [%d, %d], want [20, 300]", va, vu)
+	}
+	va, vu = getValidityWindow(mem, []*transaction.TransactionArgs{tx1})
+	if va != 10 || vu != 0 {
+		t.Fatalf("got window [%d, %d], want [10, 0]", va, vu)
+	}
+}
+
+// TestGetValidForBlock verifies that the last valid block is estimated from the block time of the latest
+// blocks.
+func TestGetValidForBlock(t *testing.T) {
+	gh := mockGetHeader(100, 1000, 2)
+	tests := []struct {
+		name       string
+		validUntil uint64
+		want       *big.Int
+	}{
+		{"no expiry", 0, big.NewInt(math.MaxInt64)},
+		{"already expired", 1000, big.NewInt(100)},
+		{"expires in future", 1011, big.NewInt(105)},
+		{"expires at block boundary", 1010, big.NewInt(104)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := getValidForBlock(gh, tc.validUntil); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if got.Cmp(tc.want) != 0 {
+				t.Fatalf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -2,18 +2,22 @@
 package client
 
 import (
+	stderrors "errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/go-logr/logr"
 	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/notx"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"github.com/stackup-wallet/stackup-bundler/pkg/state"
-	"math/big"
 )
 
 // Client controls the end to end process of adding incoming AA transactions to the mempool. It also
@@ -26,6 +30,7 @@ type Client struct {
 	getRip7560TxReceipt GetRip7560TxReceiptFunc
 	getGasPrices        GetGasPricesFunc
 	getGasEstimate      GetGasEstimateFunc
+	minBuilderFee       *big.Int
 }
 
 // New initializes a new RIP-7560 client which can be extended with modules for validating Transactions
@@ -42,6 +47,7 @@ func New(
 		getRip7560TxReceipt: getRip7560TxReceiptNotx(),
 		getGasPrices:        getGasPricesNotx(),
 		getGasEstimate:      getGasEstimateNoop(),
+		minBuilderFee:       big.NewInt(0),
 	}
 }
 
@@ -74,9 +80,19 @@ func (i *Client) SetGetGasEstimateFunc(fn GetGasEstimateFunc) {
 	i.getGasEstimate = fn
 }
 
+// SetMinBuilderFee defines the minimum builderFee accepted by the bundler. This value is suggested by
+// *Client.EstimateRip7560TransactionGas.
+func (i *Client) SetMinBuilderFee(fee *big.Int) {
+	if fee == nil {
+		fee = big.NewInt(0)
+	}
+	i.minBuilderFee = fee
+}
+
 // SendRip7560Transaction implements the method call for eth_sendRip7560Transaction.
-// It returns true if Rip7560Transaction was accepted otherwise returns an error.
-func (i *Client) SendRip7560Transaction(txArgs *transaction.TransactionArgs) (string, error) {
+// It returns true if Rip7560Transaction was accepted otherwise returns an error. The submitterIP is saved with
+// the transaction metadata and can be empty if unknown.
+func (i *Client) SendRip7560Transaction(txArgs *transaction.TransactionArgs, submitterIP string) (string, error) {
 	// Init logger
 	l := i.logger.WithName("eth_sendRip7560Transaction")
 	l = l.WithValues("chain_id", i.chainID.String())
@@ -94,14 +110,23 @@ func (i *Client) SendRip7560Transaction(txArgs *transaction.TransactionArgs) (st
 		l.Error(err, "eth_sendRip7560Transaction error")
 		return "", err
 	}
+	ctx.Metadata.SubmitterIP = submitterIP
 	if err := i.rip7560TxHandler(ctx); err != nil {
 		l.Error(err, "eth_sendRip7560Transaction error")
 		return "", err
 	}
 
 	// Add Rip-7560 transaction to mempool.
-	if err := i.mempool.AddTx(ctx.Tx); err != nil {
+	if err := i.mempool.AddTxWithMetadata(ctx.Tx, ctx.Metadata); err != nil {
 		l.Error(err, "eth_sendRip7560Transaction error")
+		if stderrors.Is(err, mempool.ErrMempoolFull) ||
+			stderrors.Is(err, mempool.ErrTxGasTooLarge) ||
+			stderrors.Is(err, mempool.ErrNonceTooLow) {
+			return "", errors.NewRPCError(errors.INVALID_FIELDS, err.Error(), err.Error())
+		}
+		if stderrors.Is(err, mempool.ErrReplacementTxUnderpriced) {
+			return "", errors.NewRPCError(errors.REPLACEMENT_UNDERPRICED, err.Error(), err.Error())
+		}
 		return "", err
 	}
 
@@ -109,8 +134,8 @@ func (i *Client) SendRip7560Transaction(txArgs *transaction.TransactionArgs) (st
 	return tx.Hash().String(), nil
 }
 
-// EstimateRip7560TransactionGas returns estimates for PreVerificationGas, VerificationGasLimit, and CallGasLimit
-// given a Rip-7560 transaction, and state OverrideSet. The signature field and current gas
+// EstimateRip7560TransactionGas returns estimates for VerificationGasLimit and CallGasLimit along with a
+// suggested BuilderFee given a Rip-7560 transaction, and state OverrideSet. The signature field and current gas
 // values will not be validated although there should be dummy values in place for the most reliable results
 // (e.g. a signature with the correct length).
 func (i *Client) EstimateRip7560TransactionGas(
@@ -156,6 +181,7 @@ func (i *Client) EstimateRip7560TransactionGas(
 	return &gas.GasEstimates{
 		VerificationGasLimit: big.NewInt(int64(vg)),
 		CallGasLimit:         big.NewInt(int64(cg)),
+		BuilderFee:           new(big.Int).Set(i.minBuilderFee),
 	}, nil
 }
 
@@ -188,6 +214,67 @@ func (i *Client) GetRip7560TransactionReceipt(
 	return receipt, nil
 }
 
+// TxStatusMined is the status of a RIP-7560 transaction that has been included on-chain.
+const TxStatusMined = "mined"
+
+// Rip7560TransactionByHashResult is a RIP-7560 transaction along with its current state in the bundler. Block
+// information is only set once the transaction has been mined.
+type Rip7560TransactionByHashResult struct {
+	Transaction      *transaction.TransactionArgs `json:"transaction"`
+	Status           string                       `json:"status"`
+	Reason           string                       `json:"reason,omitempty"`
+	BlockHash        *common.Hash                 `json:"blockHash,omitempty"`
+	BlockNumber      *hexutil.Big                 `json:"blockNumber,omitempty"`
+	TransactionIndex *hexutil.Uint                `json:"transactionIndex,omitempty"`
+}
+
+// GetRip7560TransactionByHash returns a RIP-7560 transaction and whether it is pending in the mempool, has
+// been handed to the sequencer, has been mined, or was dropped. A nil result is returned if the hash is not
+// known to the bundler.
+func (i *Client) GetRip7560TransactionByHash(hash string) (*Rip7560TransactionByHashResult, error) {
+	// Init logger
+	l := i.logger.WithName("eth_getRip7560TransactionByHash").WithValues("txHash", hash)
+
+	lookup, err := i.mempool.LookupTx(common.HexToHash(hash))
+	if err != nil {
+		l.Error(err, "eth_getRip7560TransactionByHash error")
+		return nil, err
+	}
+	if lookup != nil && (lookup.Status == mempool.TxStatusPending || lookup.Status == mempool.TxStatusQueued) {
+		l.Info("eth_getRip7560TransactionByHash ok")
+		return &Rip7560TransactionByHashResult{Transaction: lookup.Tx, Status: string(lookup.Status)}, nil
+	}
+
+	receipt, err := i.getRip7560TxReceipt(hash)
+	if err != nil && !stderrors.Is(err, ethereum.NotFound) {
+		l.Error(err, "eth_getRip7560TransactionByHash error")
+		return nil, err
+	}
+
+	var res *Rip7560TransactionByHashResult
+	if lookup != nil {
+		res = &Rip7560TransactionByHashResult{
+			Transaction: lookup.Tx,
+			Status:      string(lookup.Status),
+			Reason:      lookup.Reason,
+		}
+	}
+	if receipt != nil {
+		if res == nil {
+			res = &Rip7560TransactionByHashResult{}
+		}
+		idx := hexutil.Uint(receipt.TransactionIndex)
+		res.Status = TxStatusMined
+		res.Reason = ""
+		res.BlockHash = &receipt.BlockHash
+		res.BlockNumber = (*hexutil.Big)(receipt.BlockNumber)
+		res.TransactionIndex = &idx
+	}
+
+	l.Info("eth_getRip7560TransactionByHash ok")
+	return res, nil
+}
+
 // ChainID implements the method call for eth_chainId. It returns the current chainID used by the client.
 // This method is used to validate that the client's chainID is in sync with the caller.
 func (i *Client) ChainID() (string, error) {
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamEvents returns a gin handler that subscribes to the mempool and streams every mempool.Event to the
+// caller as a server-sent event named after its type until the request is closed. Each subscription has its
+// own buffer of the given size. If the caller falls behind, events are dropped and the number missed is set
+// on the next event it receives.
+func (i *Client) StreamEvents(buffer int) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		events, unsubscribe := i.mempool.Subscribe(buffer)
+		defer unsubscribe()
+
+		g.Stream(func(w io.Writer) bool {
+			select {
+			case <-g.Request.Context().Done():
+				return false
+			case ev, ok := <-events:
+				if !ok {
+					return false
+				}
+				g.SSEvent(string(ev.Type), ev)
+				return true
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/inflight"
+)
+
+// ReportRpcAdapter is an adapter for routing the JSON-RPC methods used by the sequencer to report bundle
+// outcomes. Reports can drop transactions and penalize entities, so it must only be served behind
+// authentication and never on the public RpcAdapter routes.
+type ReportRpcAdapter struct {
+	tracker *inflight.Tracker
+}
+
+// NewReportRpcAdapter initializes a new ReportRpcAdapter which can be used with a JSON-RPC server.
+func NewReportRpcAdapter(tracker *inflight.Tracker) *ReportRpcAdapter {
+	return &ReportRpcAdapter{tracker}
+}
+
+// Aa_reportBundleResult routes method calls to *inflight.Tracker.Report. The input must contain a "results"
+// array with the "hash", "outcome", and optional "reason" and "entity" of each transaction in a served bundle.
+func (r *ReportRpcAdapter) Aa_reportBundleResult(input map[string]interface{}) (*inflight.ReportSummary, error) {
+	jsonData, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var args struct {
+		Results []*inflight.TxResult `json:"results"`
+	}
+	if err := json.Unmarshal(jsonData, &args); err != nil {
+		return nil, err
+	}
+
+	return r.tracker.Report(args.Results)
+}
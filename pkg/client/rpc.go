@@ -4,25 +4,48 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stackup-wallet/stackup-bundler/pkg/bundler"
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
 // Named StateOverride type for jsonrpc package.
 type optional_stateOverride map[string]any
 
+// Named bundle history query type for jsonrpc package.
+type optional_bundleHistoryQuery map[string]any
+
+// bundleHistoryQuery is the decoded input of aa_getBundleHistory.
+type bundleHistoryQuery struct {
+	Limit  int            `json:"limit"`
+	Before hexutil.Uint64 `json:"before"`
+}
+
 // RpcAdapter is an adapter for routing JSON-RPC method calls to the correct client functions.
 type RpcAdapter struct {
 	client  *Client
 	bundler *bundler.Bundler
 	debug   *Debug
+
+	// clientIP is the IP address of the client that sent the request. It is empty unless set with
+	// WithClientIP.
+	clientIP string
 }
 
 // NewRpcAdapter initializes a new RpcAdapter which can be used with a JSON-RPC server.
 func NewRpcAdapter(client *Client, bundler *bundler.Bundler, debug *Debug) *RpcAdapter {
-	return &RpcAdapter{client, bundler, debug}
+	return &RpcAdapter{client: client, bundler: bundler, debug: debug}
+}
+
+// WithClientIP returns a copy of the RpcAdapter that attributes submitted transactions to the given client
+// IP address. It is a function rather than a method so that it is not exposed as a JSON-RPC method.
+func WithClientIP(r *RpcAdapter, ip string) *RpcAdapter {
+	cp := *r
+	cp.clientIP = ip
+	return &cp
 }
 
 // Eth_sendTransaction routes method calls to *Client.SendRip7560Transaction.
@@ -31,7 +54,7 @@ func (r *RpcAdapter) Eth_sendTransaction(input map[string]interface{}) (string,
 	if err != nil {
 		return "", err
 	}
-	return r.client.SendRip7560Transaction(txArgs)
+	return r.client.SendRip7560Transaction(txArgs, r.clientIP)
 }
 
 // Eth_estimateGas routes method calls to *Client.EstimateRip7560TransactionGas.
@@ -67,6 +90,11 @@ func (r *RpcAdapter) Eth_getTransactionReceipt(hash string) (*types.Receipt, err
 	return r.client.GetTransactionReceipt(hash)
 }
 
+// Eth_getRip7560TransactionByHash routes method calls to *Client.GetRip7560TransactionByHash.
+func (r *RpcAdapter) Eth_getRip7560TransactionByHash(hash string) (*Rip7560TransactionByHashResult, error) {
+	return r.client.GetRip7560TransactionByHash(hash)
+}
+
 // Eth_chainId routes method calls to *Client.ChainID.
 func (r *RpcAdapter) Eth_chainId() (string, error) {
 	return r.client.ChainID()
@@ -88,6 +116,28 @@ func (r *RpcAdapter) Aa_getRip7560Bundle(input map[string]interface{}) (*transac
 	return ret, err
 }
 
+// Aa_getBundleHistory routes method calls to *bundler.Bundler.GetBundleHistory. The optional input can set a
+// "limit" on the number of bundles and a "before" bundle ID to page through older bundles.
+func (r *RpcAdapter) Aa_getBundleHistory(input optional_bundleHistoryQuery) ([]*history.Bundle, error) {
+	var q bundleHistoryQuery
+	if input != nil {
+		jsonData, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(jsonData, &q); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.bundler.GetBundleHistory(q.Limit, uint64(q.Before))
+}
+
+// Aa_getBundleByTxHash routes method calls to *bundler.Bundler.GetBundleByTxHash.
+func (r *RpcAdapter) Aa_getBundleByTxHash(hash string) (*history.Bundle, error) {
+	return r.bundler.GetBundleByTxHash(common.HexToHash(hash))
+}
+
 // Debug_bundler_clearState routes method calls to *Debug.ClearState.
 func (r *RpcAdapter) Debug_bundler_clearState() (string, error) {
 	if r.debug == nil {
@@ -10,6 +10,8 @@ var (
 	INVALID_ENTITY_STAKE       = -32505
 	INVALID_AGGREGATOR         = -32506
 	INVALID_SIGNATURE          = -32507
+	REPLACEMENT_UNDERPRICED    = -32508
+	INVALID_DEPLOYMENT         = -32509
 	INVALID_FIELDS             = -32602
 
 	EXECUTION_REVERTED = -32521
@@ -0,0 +1,41 @@
+package errors
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TransportError is an error from a call to the node that did not return a JSON-RPC error response (e.g. a
+// timeout or a closed connection). It says nothing about the validity of the request and the call can be
+// retried.
+type TransportError struct {
+	err error
+}
+
+func (e *TransportError) Error() string {
+	return "transport error: " + e.err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.err
+}
+
+// WrapTransportError returns err as a TransportError unless it is a JSON-RPC error response from the node.
+// It returns nil if err is nil.
+func WrapTransportError(err error) error {
+	if err == nil || IsTransportError(err) {
+		return err
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return err
+	}
+	return &TransportError{err}
+}
+
+// IsTransportError returns true if err was wrapped by WrapTransportError.
+func IsTransportError(err error) bool {
+	var te *TransportError
+	return errors.As(err, &te)
+}
@@ -6,4 +6,5 @@ import "math/big"
 type GasEstimates struct {
 	VerificationGasLimit *big.Int `json:"verificationGasLimit"`
 	CallGasLimit         *big.Int `json:"callGasLimit"`
+	BuilderFee           *big.Int `json:"builderFee"`
 }
@@ -0,0 +1,318 @@
+// Package history persists a record of every bundle served to the sequencer along with the later outcome of
+// each transaction in it.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TxStatus is the outcome of a transaction after its bundle was served.
+type TxStatus string
+
+const (
+	// TxStatusServed is set for transactions that have been handed to the sequencer but not yet seen on-chain.
+	TxStatusServed TxStatus = "served"
+
+	// TxStatusIncluded is set for transactions with a receipt that may still be removed by a reorg.
+	TxStatusIncluded TxStatus = "included"
+
+	// TxStatusReverted is set for transactions that were included but reverted during execution.
+	TxStatusReverted TxStatus = "reverted"
+
+	// TxStatusFinal is set for transactions that have been included deep enough to no longer be tracked.
+	TxStatusFinal TxStatus = "final"
+
+	// TxStatusDropped is set for transactions that failed validation in the sequencer and were removed.
+	TxStatusDropped TxStatus = "dropped"
+
+	// TxStatusReinjected is set for transactions added back to the mempool because they were not included or
+	// were removed by a reorg.
+	TxStatusReinjected TxStatus = "reinjected"
+)
+
+// DefaultLimit is the number of bundles returned by List if no limit is given.
+const DefaultLimit = 20
+
+var (
+	bundleKeyPrefix = dbutils.JoinValues("history", "bundle")
+	txKeyPrefix     = dbutils.JoinValues("history", "tx")
+)
+
+// BundleTx is a transaction that was served in a bundle.
+type BundleTx struct {
+	Hash   common.Hash `json:"hash"`
+	Status TxStatus    `json:"status"`
+	Reason string      `json:"reason,omitempty"`
+
+	// IncludedBlock and IncludedBlockHash are set once a receipt for the transaction has been seen.
+	IncludedBlock     uint64       `json:"includedBlock,omitempty"`
+	IncludedBlockHash *common.Hash `json:"includedBlockHash,omitempty"`
+}
+
+// RemovedTx is a transaction that was a candidate for a bundle but was dropped or deferred.
+type RemovedTx struct {
+	Hash   common.Hash `json:"hash"`
+	Reason string      `json:"reason"`
+}
+
+// Bundle is the record of a single bundle served to the sequencer.
+type Bundle struct {
+	ID            hexutil.Uint64                   `json:"id"`
+	Args          transaction.GetRip7560BundleArgs `json:"args"`
+	Txs           []*BundleTx                      `json:"txs"`
+	Dropped       []*RemovedTx                     `json:"dropped"`
+	Deferred      []*RemovedTx                     `json:"deferred"`
+	BaseFee       *hexutil.Big                     `json:"baseFee"`
+	Tip           *hexutil.Big                     `json:"tip"`
+	ValidForBlock *hexutil.Big                     `json:"validForBlock"`
+	ServedAt      time.Time                        `json:"servedAt"`
+}
+
+func getBundleKey(id uint64) []byte {
+	// IDs are zero padded so that keys sort in the same order as the IDs.
+	return []byte(dbutils.JoinValues(bundleKeyPrefix, fmt.Sprintf("%020d", id)))
+}
+
+func getTxKey(hash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(txKeyPrefix, hash.String()))
+}
+
+// History saves served bundles to an embedded badger DB. It is safe for concurrent use.
+type History struct {
+	mu     sync.Mutex
+	db     *badger.DB
+	ttl    time.Duration
+	lastID uint64
+}
+
+// New returns a History that keeps bundles for the given ttl. A ttl of 0 keeps bundles forever.
+func New(db *badger.DB, ttl time.Duration) *History {
+	return &History{db: db, ttl: ttl}
+}
+
+// setEntry saves a bundle with the time it has left to live. Bundles past their ttl are not saved.
+func (h *History) setEntry(txn *badger.Txn, key []byte, b *Bundle, value []byte) error {
+	e := badger.NewEntry(key, value)
+	if h.ttl > 0 {
+		ttl := h.ttl - time.Since(b.ServedAt)
+		if ttl <= 0 {
+			return nil
+		}
+		e = e.WithTTL(ttl)
+	}
+	return txn.SetEntry(e)
+}
+
+func (h *History) put(txn *badger.Txn, b *Bundle) error {
+	value, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return h.setEntry(txn, getBundleKey(uint64(b.ID)), b, value)
+}
+
+func getBundle(txn *badger.Txn, id uint64) (*Bundle, error) {
+	item, err := txn.Get(getBundleKey(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var b Bundle
+	if err := item.Value(func(v []byte) error { return json.Unmarshal(v, &b) }); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func getBundleIDByTxHash(txn *badger.Txn, hash common.Hash) (uint64, bool, error) {
+	item, err := txn.Get(getTxKey(hash))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	var id uint64
+	if err := item.Value(func(v []byte) error { return json.Unmarshal(v, &id) }); err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// Add saves a new bundle and assigns it an ID. IDs increase with the time the bundle was served. Each
+// transaction in the bundle is indexed by hash so that it can be found with GetByTxHash.
+func (h *History) Add(b *Bundle) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := uint64(b.ServedAt.UnixNano())
+	if id <= h.lastID {
+		id = h.lastID + 1
+	}
+	b.ID = hexutil.Uint64(id)
+
+	err := h.db.Update(func(txn *badger.Txn) error {
+		if err := h.put(txn, b); err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(id)
+		if err != nil {
+			return err
+		}
+		for _, tx := range b.Txs {
+			if err := h.setEntry(txn, getTxKey(tx.Hash), b, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.lastID = id
+	return nil
+}
+
+// GetByTxHash returns the latest bundle that a transaction was served in or nil if it is unknown.
+func (h *History) GetByTxHash(hash common.Hash) (*Bundle, error) {
+	var b *Bundle
+	err := h.db.View(func(txn *badger.Txn) error {
+		id, ok, err := getBundleIDByTxHash(txn, hash)
+		if err != nil || !ok {
+			return err
+		}
+
+		b, err = getBundle(txn, id)
+		return err
+	})
+	return b, err
+}
+
+// List returns up to limit bundles ordered from the most recently served. Only bundles with an ID lower than
+// before are returned, which allows paging through the history by passing the ID of the last bundle in the
+// previous page. A before value of 0 starts from the most recent bundle.
+func (h *History) List(limit int, before uint64) ([]*Bundle, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	bundles := []*Bundle{}
+	err := h.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		opts.PrefetchSize = limit
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		p := []byte(bundleKeyPrefix)
+		start := append([]byte(dbutils.JoinValues(bundleKeyPrefix, "")), 0xff)
+		if before > 0 {
+			start = getBundleKey(before - 1)
+		}
+		for it.Seek(start); it.ValidForPrefix(p) && len(bundles) < limit; it.Next() {
+			var b Bundle
+			if err := it.Item().Value(func(v []byte) error { return json.Unmarshal(v, &b) }); err != nil {
+				return err
+			}
+			bundles = append(bundles, &b)
+		}
+		return nil
+	})
+	return bundles, err
+}
+
+// UpdateTx applies fn to a transaction in the latest bundle it was served in. Nothing is done if the
+// transaction is unknown.
+func (h *History) UpdateTx(hash common.Hash, fn func(tx *BundleTx)) error {
+	return h.db.Update(func(txn *badger.Txn) error {
+		id, ok, err := getBundleIDByTxHash(txn, hash)
+		if err != nil || !ok {
+			return err
+		}
+		b, err := getBundle(txn, id)
+		if err != nil || b == nil {
+			return err
+		}
+
+		for _, tx := range b.Txs {
+			if tx.Hash == hash {
+				fn(tx)
+			}
+		}
+		return h.put(txn, b)
+	})
+}
+
+// SetIncluded records that a transaction has been included on-chain and whether its execution reverted. A
+// block of 0 means the block is not yet known.
+func (h *History) SetIncluded(hash common.Hash, block uint64, blockHash common.Hash, reverted bool) error {
+	return h.UpdateTx(hash, func(tx *BundleTx) {
+		tx.Status = TxStatusIncluded
+		if reverted {
+			tx.Status = TxStatusReverted
+		}
+		tx.Reason = ""
+		if block > 0 {
+			tx.IncludedBlock = block
+			tx.IncludedBlockHash = &blockHash
+		}
+	})
+}
+
+// SetReportedIncluded records that the sequencer reported a transaction as included and whether its execution
+// reverted. The block it was included in is left as is and a final transaction that did not revert keeps its
+// status.
+func (h *History) SetReportedIncluded(hash common.Hash, reverted bool) error {
+	return h.UpdateTx(hash, func(tx *BundleTx) {
+		switch {
+		case reverted:
+			tx.Status = TxStatusReverted
+		case tx.Status != TxStatusFinal:
+			tx.Status = TxStatusIncluded
+		}
+		tx.Reason = ""
+	})
+}
+
+// SetFinal records that a transaction has been included deep enough to no longer be at risk of a reorg.
+// Transactions that reverted keep their status.
+func (h *History) SetFinal(hash common.Hash) error {
+	return h.UpdateTx(hash, func(tx *BundleTx) {
+		if tx.Status != TxStatusReverted {
+			tx.Status = TxStatusFinal
+		}
+	})
+}
+
+// SetDropped records that a transaction was removed after failing validation in the sequencer and the reason
+// why.
+func (h *History) SetDropped(hash common.Hash, reason string) error {
+	return h.UpdateTx(hash, func(tx *BundleTx) {
+		tx.Status = TxStatusDropped
+		tx.Reason = reason
+	})
+}
+
+// SetReinjected records that a transaction was added back to the mempool and the reason why.
+func (h *History) SetReinjected(hash common.Hash, reason string) error {
+	return h.UpdateTx(hash, func(tx *BundleTx) {
+		tx.Status = TxStatusReinjected
+		tx.Reason = reason
+		tx.IncludedBlock = 0
+		tx.IncludedBlockHash = nil
+	})
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+func mockBundle(servedAt time.Time, hashes ...common.Hash) *Bundle {
+	txs := []*BundleTx{}
+	for _, hash := range hashes {
+		txs = append(txs, &BundleTx{Hash: hash, Status: TxStatusServed})
+	}
+	return &Bundle{Txs: txs, ServedAt: servedAt}
+}
+
+// TestGetByTxHash verifies that a served bundle can be found by the hash of any RIP-7560 transaction in it
+// and that the latest bundle is returned for transactions served more than once.
+func TestGetByTxHash(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	h := New(db, 0)
+
+	now := time.Now()
+	b1 := mockBundle(now, common.Hash{0x01}, common.Hash{0x02})
+	b2 := mockBundle(now, common.Hash{0x02})
+	for _, b := range []*Bundle{b1, b2} {
+		if err := h.Add(b); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if b2.ID <= b1.ID {
+		t.Fatalf("got ids %d and %d, want increasing ids", b1.ID, b2.ID)
+	}
+
+	if b, err := h.GetByTxHash(common.Hash{0x01}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if b == nil || b.ID != b1.ID {
+		t.Fatalf("got %v, want bundle %d", b, b1.ID)
+	}
+	if b, err := h.GetByTxHash(common.Hash{0x02}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if b == nil || b.ID != b2.ID {
+		t.Fatalf("got %v, want bundle %d", b, b2.ID)
+	}
+	if b, err := h.GetByTxHash(common.Hash{0x03}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if b != nil {
+		t.Fatalf("got %v, want nil", b)
+	}
+}
+
+// TestList verifies that bundles are listed from the most recently served and can be paged through.
+func TestList(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	h := New(db, 0)
+
+	now := time.Now()
+	bundles := []*Bundle{}
+	for i := 0; i < 3; i++ {
+		b := mockBundle(now.Add(time.Duration(i)*time.Second), common.Hash{byte(i)})
+		if err := h.Add(b); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		bundles = append(bundles, b)
+	}
+
+	page, err := h.List(2, 0)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(page) != 2 || page[0].ID != bundles[2].ID || page[1].ID != bundles[1].ID {
+		t.Fatalf("got %v, want two most recent bundles", page)
+	}
+
+	page, err = h.List(2, uint64(page[1].ID))
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(page) != 1 || page[0].ID != bundles[0].ID {
+		t.Fatalf("got %v, want oldest bundle", page)
+	}
+}
+
+// TestUpdateTxOutcome verifies that the outcome of a RIP-7560 transaction is saved to the bundle it was
+// served in.
+func TestUpdateTxOutcome(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	h := New(db, time.Hour)
+
+	hash := common.Hash{0x01}
+	if err := h.Add(mockBundle(time.Now(), hash, common.Hash{0x02})); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := h.SetIncluded(hash, 10, common.Hash{0xaa}, false); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	b, _ := h.GetByTxHash(hash)
+	if tx := b.Txs[0]; tx.Status != TxStatusIncluded || tx.IncludedBlock != 10 {
+		t.Fatalf("got %v, want included in block 10", tx)
+	} else if b.Txs[1].Status != TxStatusServed {
+		t.Fatalf("got %s, want other tx unchanged", b.Txs[1].Status)
+	}
+
+	if err := h.SetReinjected(hash, "reorged out"); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	b, _ = h.GetByTxHash(hash)
+	if tx := b.Txs[0]; tx.Status != TxStatusReinjected || tx.Reason != "reorged out" || tx.IncludedBlockHash != nil {
+		t.Fatalf("got %v, want reinjected", tx)
+	}
+}
@@ -437,7 +437,14 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 // NOTE: For batched requests in the current version, "json-rpc-request" on the Gin context contains only the
 // last request in the array.
 func Controller(api interface{}) gin.HandlerFunc {
+	return ControllerWithFactory(func(c *gin.Context) interface{} { return api })
+}
+
+// ControllerWithFactory is the same as Controller but calls newAPI once per HTTP request to get the api that
+// handles it. This allows the api to depend on details of the request such as c.ClientIP().
+func ControllerWithFactory(newAPI func(c *gin.Context) interface{}) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		api := newAPI(c)
 		if c.Request.Method != "POST" {
 			jsonrpcError(c, -32700, "Parse error", "POST method excepted", nil)
 			return
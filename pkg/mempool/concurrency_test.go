@@ -0,0 +1,299 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+const (
+	concurrentSenders = 16
+	txsPerSender      = 8
+)
+
+func newConcurrentTestTx(sender int, nonce uint64, tip int64) *transaction.TransactionArgs {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Sender = &common.Address{0x01, byte(sender)}
+	n := hexutil.Uint64(nonce)
+	tx.Nonce = &n
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(tip))
+	tx.MaxFeePerGas = (*hexutil.Big)(big.NewInt(tip))
+	return tx
+}
+
+func getConcurrentTestStores(t *testing.T) []struct {
+	name  string
+	store Store
+} {
+	db := testutils.DBMock()
+	t.Cleanup(func() { db.Close() })
+
+	return []struct {
+		name  string
+		store Store
+	}{
+		{"badger", NewBadgerStore(db)},
+		{"memory", NewMemoryStore()},
+	}
+}
+
+// TestConcurrentAccess adds, reads, and removes transactions from many goroutines at the same time and
+// verifies the mempool ends up in a consistent state. Run with -race to check for data races.
+func TestConcurrentAccess(t *testing.T) {
+	for _, tc := range getConcurrentTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			mem, _ := NewWithStore(tc.store)
+			mem.SetGetNonceFunc(func(sender common.Address, key *big.Int) (uint64, error) {
+				return 0, nil
+			})
+			events, unsubscribe := mem.Subscribe(concurrentSenders * txsPerSender)
+			defer unsubscribe()
+
+			var writers, readers sync.WaitGroup
+			done := make(chan struct{})
+			for i := 0; i < 4; i++ {
+				readers.Add(1)
+				go func(i int) {
+					defer readers.Done()
+					for {
+						select {
+						case <-done:
+							return
+						case <-events:
+						default:
+						}
+
+						tx := newConcurrentTestTx(i, 0, 1)
+						if _, err := mem.Dump(); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+						if _, err := mem.GetTxs(tx.GetSender()); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+						if _, err := mem.LookupTx(tx.ToTransaction().Hash()); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+						if err := mem.PromoteQueued(); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+						if err := mem.UpdateMetadata(func(_ *transaction.TransactionArgs, meta *TxMetadata) { meta.RevalidationCount++ }, tx); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+						_ = mem.GetMetadata(tx)
+						_ = mem.GetBestTxs(big.NewInt(int64(i)), txsPerSender)
+						_ = mem.GetInFlight()
+					}
+				}(i)
+			}
+
+			for i := 0; i < concurrentSenders; i++ {
+				writers.Add(1)
+				go func(i int) {
+					defer writers.Done()
+					for n := txsPerSender - 1; n >= 0; n-- {
+						if err := mem.AddTx(newConcurrentTestTx(i, uint64(n), 1)); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+					}
+				}(i)
+			}
+			writers.Wait()
+
+			if txs, _ := mem.Dump(); len(txs) != concurrentSenders*txsPerSender {
+				t.Fatalf("got %d pending txs, want %d", len(txs), concurrentSenders*txsPerSender)
+			}
+
+			for i := 0; i < concurrentSenders; i++ {
+				writers.Add(1)
+				go func(i int) {
+					defer writers.Done()
+					sender := common.Address{0x01, byte(i)}
+					txs, _ := mem.GetTxsByNonceKey(sender, big.NewInt(0))
+					if i%2 == 0 {
+						if err := mem.BundleTxs(txs...); err != nil {
+							t.Errorf("got %v, want nil", err)
+						}
+					} else if err := mem.DropTxs("test", txs...); err != nil {
+						t.Errorf("got %v, want nil", err)
+					}
+				}(i)
+			}
+			writers.Wait()
+			close(done)
+			readers.Wait()
+
+			if txs, _ := mem.Dump(); len(txs) != 0 {
+				t.Fatalf("got %d pending txs, want 0", len(txs))
+			} else if txs, _ := mem.DumpQueued(); len(txs) != 0 {
+				t.Fatalf("got %d queued txs, want 0", len(txs))
+			} else if itxs := mem.GetInFlight(); len(itxs) != concurrentSenders/2*txsPerSender {
+				t.Fatalf("got %d in-flight txs, want %d", len(itxs), concurrentSenders/2*txsPerSender)
+			}
+		})
+	}
+}
+
+// TestConcurrentEviction adds transactions from many goroutines to a mempool at capacity and verifies that
+// the limit is never exceeded.
+func TestConcurrentEviction(t *testing.T) {
+	for _, tc := range getConcurrentTestStores(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			limit := concurrentSenders / 2
+			mem, _ := NewWithStore(tc.store)
+			mem.SetLimits(limit, 0)
+			mem.SetGetBaseFeeFunc(testutils.GetMockBaseFeeFunc(big.NewInt(0)))
+
+			var wg sync.WaitGroup
+			for i := 0; i < concurrentSenders; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					err := mem.AddTx(newConcurrentTestTx(i, 0, int64(i+1)))
+					if err != nil && err != ErrMempoolFull {
+						t.Errorf("got %v, want nil or %v", err, ErrMempoolFull)
+					}
+					if txs, _ := mem.Dump(); len(txs) > limit {
+						t.Errorf("got %d txs, want at most %d", len(txs), limit)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if txs, _ := mem.Dump(); len(txs) != limit {
+				t.Fatalf("got %d txs, want %d", len(txs), limit)
+			}
+			loaded, err := tc.store.LoadTxs()
+			if err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if len(loaded) != limit {
+				t.Fatalf("got %d stored txs, want %d", len(loaded), limit)
+			}
+		})
+	}
+}
+
+// TestConcurrentReplacements verifies that the replacement price bump is enforced between replacements of the
+// same transaction that are added at the same time. Only one of them can replace the original.
+func TestConcurrentReplacements(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	mem.SetReplacementPriceBump(10)
+	if err := mem.AddTx(newConcurrentTestTx(1, 0, 100)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentSenders)
+	for i := 0; i < concurrentSenders; i++ {
+		wg.Add(1)
+		go func(tip int64) {
+			defer wg.Done()
+			errs <- mem.AddTx(newConcurrentTestTx(1, 0, tip))
+		}(110 + int64(i%2))
+	}
+	wg.Wait()
+	close(errs)
+
+	accepted := 0
+	for err := range errs {
+		if err == nil {
+			accepted++
+		} else if !errors.Is(err, ErrReplacementTxUnderpriced) {
+			t.Fatalf("got %v, want ErrReplacementTxUnderpriced", err)
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("got %d accepted replacements, want 1", accepted)
+	}
+}
+
+// blockingStore is a MemoryStore that blocks writes for one sender until released.
+type blockingStore struct {
+	*MemoryStore
+	sender  common.Address
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Update(u *StoreUpdate) error {
+	for _, entry := range u.PutTxs {
+		if entry.Tx.GetSender() == s.sender {
+			close(s.started)
+			<-s.release
+		}
+	}
+	return s.MemoryStore.Update(u)
+}
+
+// TestStoreWriteDoesNotBlockOtherSenders verifies that a slow Store write for one sender does not block
+// readers or writes for other senders.
+func TestStoreWriteDoesNotBlockOtherSenders(t *testing.T) {
+	tx1 := newConcurrentTestTx(1, 0, 1)
+	tx2 := newConcurrentTestTx(2, 0, 1)
+	store := &blockingStore{
+		MemoryStore: NewMemoryStore(),
+		sender:      tx1.GetSender(),
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	mem, _ := NewWithStore(store)
+
+	errs := make(chan error, 1)
+	go func() { errs <- mem.AddTx(tx1) }()
+	<-store.started
+
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.BundleTxs(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if txs, _ := mem.Dump(); len(txs) != 0 {
+		t.Fatalf("got %d pending txs, want 0", len(txs))
+	}
+
+	close(store.release)
+	if err := <-errs; err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if txs, _ := mem.Dump(); len(txs) != 1 || !testutils.IsTxsEqual(txs[0], tx1) {
+		t.Fatalf("got %v, want tx1", txs)
+	}
+}
+
+// TestConcurrentBaseFees verifies that callers iterating by price against different base fees at the same
+// time each get the order for their own base fee.
+func TestConcurrentBaseFees(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	x := withPrice(common.Address{0x01}, 0, 100, 3)
+	y := withPrice(common.Address{0x02}, 0, 12, 10)
+	for _, tx := range []*transaction.TransactionArgs{x, y} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentSenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bf, want := big.NewInt(0), y
+			if i%2 == 1 {
+				bf, want = big.NewInt(10), x
+			}
+			for j := 0; j < 100; j++ {
+				if txs := mem.GetBestTxs(bf, 1); len(txs) != 1 || !testutils.IsTxsEqual(txs[0], want) {
+					t.Errorf("base fee %s: got %v, want %v", bf, txs, want)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
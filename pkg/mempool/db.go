@@ -1,7 +1,10 @@
 package mempool
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+
 	badger "github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -10,55 +13,242 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
+// storeVersion is part of the key prefix for transactions in the mempool. It must be bumped whenever the
+// encoding of a TxEntry changes in a way that older entries can no longer be decoded.
+const storeVersion = "v1"
+
 var (
-	keyPrefix = dbutils.JoinValues("mempool")
+	keyPrefix         = dbutils.JoinValues("mempool", storeVersion)
+	legacyKeyPrefix   = dbutils.JoinValues("mempool", "0x")
+	removedKeyPrefix  = dbutils.JoinValues("removed")
+	inFlightKeyPrefix = dbutils.JoinValues("inflight")
 )
 
-func getUniqueKey(sender common.Address, nonce *hexutil.Uint64, bigNonce *hexutil.Big) []byte {
-	if bigNonce == nil {
-		bigNonce = new(hexutil.Big)
-		bigNonce.ToInt().SetInt64(-1)
-	}
+func getUniqueKey(tx *transaction.TransactionArgs) []byte {
 	return []byte(
-		dbutils.JoinValues(keyPrefix, sender.String(), nonce.String(), bigNonce.String()),
+		dbutils.JoinValues(
+			keyPrefix,
+			tx.GetSender().String(),
+			hexutil.EncodeUint64(tx.GetNonce()),
+			hexutil.EncodeBig(tx.GetNonceKey()),
+		),
 	)
 }
 
-func getTransactionsFromDBValue(serializedTx []byte) (*transaction.TransactionArgs, error) {
+func getRemovedKey(hash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(removedKeyPrefix, hash.String()))
+}
+
+func getInFlightKey(hash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(inFlightKeyPrefix, hash.String()))
+}
+
+func getEntryFromDBValue(serialized []byte) (*TxEntry, error) {
+	var entry TxEntry
+	if err := json.Unmarshal(serialized, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions: %v", err)
+	}
+	if entry.Tx != nil {
+		return &entry, nil
+	}
+
+	// Transactions saved before metadata was introduced are stored without a wrapper.
 	var decodedTx *transaction.TransactionArgs
-	err := rlp.DecodeBytes(serializedTx, &decodedTx)
-	if err != nil {
+	if err := json.Unmarshal(serialized, &decodedTx); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions: %v", err)
+	}
+	return &TxEntry{Tx: decodedTx}, nil
+}
+
+// getEntryFromLegacyDBValue decodes a transaction saved by a release that stored RLP encoded transactions
+// without metadata under an unversioned key.
+func getEntryFromLegacyDBValue(serialized []byte) (*TxEntry, error) {
+	var decodedTx *transaction.TransactionArgs
+	if err := rlp.DecodeBytes(serialized, &decodedTx); err != nil {
 		return nil, fmt.Errorf("failed to RLP decode transactions: %v", err)
 	}
-	return decodedTx, nil
+	return &TxEntry{Tx: decodedTx, Meta: NewTxMetadata()}, nil
+}
+
+// BadgerStore is a Store that persists the mempool to an embedded badger DB.
+type BadgerStore struct {
+	db *badger.DB
 }
 
-func loadFromDisk(db *badger.DB, q *rip7560TxQueues) error {
-	return db.View(func(txn *badger.Txn) error {
+// NewBadgerStore returns a Store that uses the given badger DB.
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{db}
+}
+
+func (s *BadgerStore) iterate(prefix string, fn func(v []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		it := txn.NewIterator(opts)
-		prefix := []byte(keyPrefix)
+		p := []byte(prefix)
+		defer it.Close()
+
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			if err := it.Item().Value(fn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// migrateLegacyTxs rewrites transactions saved under the unversioned key prefix to the current encoding.
+// Entries that cannot be decoded are deleted since they cannot be loaded by this release. Changes are written
+// with a WriteBatch so that a large legacy mempool is committed over several transactions.
+func (s *BadgerStore) migrateLegacyTxs() error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		p := []byte(legacyKeyPrefix)
 		defer it.Close()
 
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
 			item := it.Item()
+			key := item.KeyCopy(nil)
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
 
-			err := item.Value(func(v []byte) error {
-				tx, err := getTransactionsFromDBValue(v)
+			if entry, err := getEntryFromLegacyDBValue(v); err == nil {
+				data, err := json.Marshal(entry)
 				if err != nil {
+					return fmt.Errorf("failed to encode transaction: %v", err)
+				}
+				if err := wb.Set(getUniqueKey(entry.Tx), data); err != nil {
 					return err
 				}
+			}
+			if err := wb.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
+
+// LoadTxs implements the Store interface. Transactions saved by older releases are migrated first.
+func (s *BadgerStore) LoadTxs() ([]*TxEntry, error) {
+	if err := s.migrateLegacyTxs(); err != nil {
+		return nil, err
+	}
+
+	entries := []*TxEntry{}
+	err := s.iterate(keyPrefix, func(v []byte) error {
+		entry, err := getEntryFromDBValue(v)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+
+	return entries, err
+}
+
+// LoadInFlight implements the Store interface.
+func (s *BadgerStore) LoadInFlight() ([]*InFlightTx, error) {
+	itxs := []*InFlightTx{}
+	err := s.iterate(inFlightKeyPrefix, func(v []byte) error {
+		var itx *InFlightTx
+		if err := json.Unmarshal(v, &itx); err != nil {
+			return err
+		}
+
+		itxs = append(itxs, itx)
+		return nil
+	})
+
+	return itxs, err
+}
 
-				q.AddTx(tx)
-				return nil
-			})
+// GetRemoved implements the Store interface.
+func (s *BadgerStore) GetRemoved(hash common.Hash) (*TxLookup, error) {
+	var lookup *TxLookup
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(getRemovedKey(hash))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, &lookup)
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return lookup, err
+}
 
+// Update implements the Store interface.
+func (s *BadgerStore) Update(u *StoreUpdate) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, tx := range u.DeleteTxs {
+			if err := txn.Delete(getUniqueKey(tx)); err != nil {
+				return err
+			}
+		}
+		for _, entry := range u.PutTxs {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("failed to encode transaction: %v", err)
+			}
+			if err := txn.Set(getUniqueKey(entry.Tx), data); err != nil {
+				return err
+			}
+		}
+		for _, lookup := range u.PutRemoved {
+			data, err := json.Marshal(lookup)
+			if err != nil {
+				return err
+			}
+			e := badger.NewEntry(getRemovedKey(lookup.Tx.ToTransaction().Hash()), data).WithTTL(removedTxTTL)
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		for _, hash := range u.DeleteInFlight {
+			if err := txn.Delete(getInFlightKey(hash)); err != nil {
+				return err
+			}
+		}
+		for _, itx := range u.PutInFlight {
+			data, err := json.Marshal(itx)
 			if err != nil {
 				return err
 			}
+			if err := txn.Set(getInFlightKey(itx.Hash), data); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
 }
+
+// Clear implements the Store interface. Only keys owned by the mempool are dropped so that other data in the
+// same badger DB is kept.
+func (s *BadgerStore) Clear() error {
+	return s.db.DropPrefix(
+		[]byte(keyPrefix),
+		[]byte(legacyKeyPrefix),
+		[]byte(removedKeyPrefix),
+		[]byte(inFlightKeyPrefix),
+	)
+}
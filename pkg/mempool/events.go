@@ -0,0 +1,143 @@
+package mempool
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// EventType describes a change in the lifecycle of a transaction in the mempool.
+type EventType string
+
+const (
+	// EventAdded is emitted when a new transaction is admitted to the mempool.
+	EventAdded EventType = "added"
+
+	// EventReplaced is emitted when a transaction replaces an existing one with the same sender, nonce key,
+	// and nonce.
+	EventReplaced EventType = "replaced"
+
+	// EventBundled is emitted when a transaction is removed from the mempool as part of a bundle.
+	EventBundled EventType = "bundled"
+
+	// EventDropped is emitted when a transaction is removed from the mempool without being bundled.
+	EventDropped EventType = "dropped"
+
+	// EventExpired is emitted when a transaction is removed from the mempool after exceeding its TTL.
+	EventExpired EventType = "expired"
+
+	// EventReinjected is emitted when a bundled transaction is added back to the mempool because it was not
+	// included or was removed by a reorg.
+	EventReinjected EventType = "reinjected"
+)
+
+const (
+	// DropReasonExpired is the reason given for transactions that have been in the mempool for too long.
+	DropReasonExpired = "transaction expired"
+
+	// DropReasonCleared is the reason given for transactions removed when the mempool is cleared.
+	DropReasonCleared = "mempool cleared"
+)
+
+// Event is emitted to subscribers on every lifecycle change of a transaction in the mempool.
+type Event struct {
+	Type EventType                    `json:"type"`
+	Hash common.Hash                  `json:"hash"`
+	Tx   *transaction.TransactionArgs `json:"tx"`
+
+	// Prev is the transaction that was replaced. Only set for EventReplaced.
+	Prev *transaction.TransactionArgs `json:"prev,omitempty"`
+
+	// Reason is the cause for removal or reinjection. Only set for EventDropped, EventExpired, and
+	// EventReinjected.
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// Missed is the number of events dropped for the subscriber since the previous one it received because
+	// its buffer was full.
+	Missed uint64 `json:"missed,omitempty"`
+}
+
+type subscriber struct {
+	ch     chan *Event
+	missed uint64
+}
+
+type eventFeed struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]*subscriber
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{subs: make(map[int]*subscriber)}
+}
+
+func (f *eventFeed) subscribe(buffer int) (<-chan *Event, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.nextID
+	f.nextID++
+	sub := &subscriber{ch: make(chan *Event, buffer)}
+	f.subs[id] = sub
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			delete(f.subs, id)
+			close(sub.ch)
+		})
+	}
+}
+
+func (f *eventFeed) emit(typ EventType, reason string, prev *transaction.TransactionArgs, txs ...*transaction.TransactionArgs) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.subs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, tx := range txs {
+		ev := &Event{
+			Type:   typ,
+			Hash:   tx.ToTransaction().Hash(),
+			Tx:     tx,
+			Prev:   prev,
+			Reason: reason,
+			Time:   now,
+		}
+		for _, sub := range f.subs {
+			// Events are emitted concurrently under the read lock, so the count of missed events is claimed
+			// atomically and given back if the send fails.
+			out := ev
+			if missed := atomic.SwapUint64(&sub.missed, 0); missed > 0 {
+				cp := *ev
+				cp.Missed = missed
+				out = &cp
+			}
+			select {
+			case sub.ch <- out:
+			default:
+				// The subscriber's buffer is full. The event is dropped for it rather than block the mempool
+				// and counted towards the next event it receives.
+				atomic.AddUint64(&sub.missed, out.Missed+1)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel that receives an Event for every lifecycle change of a transaction in the
+// mempool and a function to cancel the subscription. Events are delivered without blocking the mempool, so
+// once a subscriber's buffer is full any further events for it are dropped until it catches up. The number of
+// events dropped is set as Missed on the next event it receives, after which it should fall back to reading
+// the mempool for the transactions it tracks.
+func (m *Mempool) Subscribe(buffer int) (<-chan *Event, func()) {
+	return m.events.subscribe(buffer)
+}
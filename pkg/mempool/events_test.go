@@ -0,0 +1,156 @@
+package mempool
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func expectEvent(t *testing.T, ch <-chan *Event, typ EventType, reason string) *Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		if ev.Type != typ {
+			t.Fatalf("got event %s, want %s", ev.Type, typ)
+		}
+		if ev.Reason != reason {
+			t.Fatalf("got reason %q, want %q", ev.Reason, reason)
+		}
+		return ev
+	default:
+		t.Fatalf("got no event, want %s", typ)
+	}
+	return nil
+}
+
+// TestMempoolEvents verifies that subscribers receive an event for every lifecycle change of a RIP-7560
+// transaction in the mempool.
+func TestMempoolEvents(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	ch, unsubscribe := mem.Subscribe(10)
+	defer unsubscribe()
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.MaxFeePerGas = (*hexutil.Big)(big.NewInt(0).Add((*big.Int)(tx1.MaxFeePerGas), common.Big1))
+	tx3 := testutils.MockValidInitRip7560Tx()
+	tx3.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	ev := expectEvent(t, ch, EventAdded, "")
+	if ev.Hash != tx1.ToTransaction().Hash() {
+		t.Fatalf("got hash %s, want %s", ev.Hash, tx1.ToTransaction().Hash())
+	}
+
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	ev = expectEvent(t, ch, EventReplaced, "")
+	if !testutils.IsTxsEqual(ev.Prev, tx1) {
+		t.Fatal("incorrect replaced tx")
+	}
+
+	if err := mem.AddTx(tx3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expectEvent(t, ch, EventAdded, "")
+
+	if err := mem.BundleTxs(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expectEvent(t, ch, EventBundled, "")
+
+	if err := mem.DropTxs(DropReasonExpired, tx3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expectEvent(t, ch, EventExpired, DropReasonExpired)
+	if reason, _ := mem.GetDropReason(tx3.ToTransaction().Hash()); reason != DropReasonExpired {
+		t.Fatalf("got reason %q, want %q", reason, DropReasonExpired)
+	}
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expectEvent(t, ch, EventAdded, "")
+	if err := mem.DropTxs("bad tx", tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expectEvent(t, ch, EventDropped, "bad tx")
+}
+
+// TestMempoolUnsubscribe verifies that a subscription stops receiving events once cancelled.
+func TestMempoolUnsubscribe(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	ch, unsubscribe := mem.Subscribe(10)
+	unsubscribe()
+
+	if err := mem.AddTx(testutils.MockValidInitRip7560Tx()); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("got event, want closed channel")
+	}
+}
+
+// TestReinjectInFlightDroppedEvent verifies that subscribers receive a dropped event with the reason when an
+// in-flight RIP-7560 transaction can no longer be admitted to the mempool.
+func TestReinjectInFlightDroppedEvent(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(1)
+	mem.SetGetNonceFunc(mockNonce(&next))
+	ch, unsubscribe := mem.Subscribe(10)
+	defer unsubscribe()
+
+	tx := withNonce(0)
+	itx := &InFlightTx{Tx: tx, Hash: tx.ToTransaction().Hash()}
+	if err := mem.ReinjectInFlight("reorg", itx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	ev := expectEvent(t, ch, EventDropped, "reorg: "+ErrNonceTooLow.Error())
+	if ev.Hash != itx.Hash {
+		t.Fatalf("got hash %s, want %s", ev.Hash, itx.Hash)
+	}
+}
+
+// TestSlowSubscriberMissesEvents verifies that events are dropped for a subscriber with a full buffer instead
+// of blocking the mempool and that the number dropped is reported on the next event it receives.
+func TestSlowSubscriberMissesEvents(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	ch, unsubscribe := mem.Subscribe(1)
+	defer unsubscribe()
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	ev := expectEvent(t, ch, EventAdded, "")
+	if ev.Hash != tx1.ToTransaction().Hash() {
+		t.Fatalf("got hash %s, want %s", ev.Hash, tx1.ToTransaction().Hash())
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("got event %s, want none", ev.Type)
+	default:
+	}
+
+	tx3 := testutils.MockValidInitRip7560Tx()
+	nonce := hexutil.Uint64(2)
+	tx3.Nonce = &nonce
+	if err := mem.AddTx(tx3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if ev := expectEvent(t, ch, EventAdded, ""); ev.Missed != 1 {
+		t.Fatalf("got missed %d, want 1", ev.Missed)
+	}
+}
@@ -0,0 +1,139 @@
+package mempool
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// InFlightTx is a transaction that has been handed to the sequencer but is not yet final.
+type InFlightTx struct {
+	Tx       *transaction.TransactionArgs `json:"tx"`
+	Meta     *TxMetadata                  `json:"meta,omitempty"`
+	Hash     common.Hash                  `json:"hash"`
+	ServedAt time.Time                    `json:"servedAt"`
+
+	// ServedBlock is the latest block number when the transaction was first tracked. A value of 0 means it has
+	// not been tracked yet.
+	ServedBlock uint64 `json:"servedBlock"`
+
+	// IncludedBlock and IncludedBlockHash are set once a receipt for the transaction has been seen.
+	IncludedBlock     uint64      `json:"includedBlock"`
+	IncludedBlockHash common.Hash `json:"includedBlockHash"`
+
+	// Credited is set once the entities of the transaction have been credited with an inclusion in their
+	// reputation.
+	Credited bool `json:"credited,omitempty"`
+}
+
+// GetInFlight returns a copy of all transactions that have been handed to the sequencer and are not yet final
+// in the order they were served.
+func (m *Mempool) GetInFlight() []*InFlightTx {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	itxs := []*InFlightTx{}
+	for _, itx := range m.inFlight {
+		cp := *itx
+		itxs = append(itxs, &cp)
+	}
+	sort.SliceStable(itxs, func(i, j int) bool {
+		return itxs[i].ServedAt.Before(itxs[j].ServedAt)
+	})
+
+	return itxs
+}
+
+// UpdateInFlight saves changes to the tracking state of an in-flight transaction.
+func (m *Mempool) UpdateInFlight(itx *InFlightTx) error {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	if _, ok := m.inFlight[itx.Hash]; !ok {
+		return nil
+	}
+
+	cp := *itx
+	if err := m.store.Update(&StoreUpdate{PutInFlight: []*InFlightTx{&cp}}); err != nil {
+		return err
+	}
+	m.inFlight[itx.Hash] = &cp
+	return nil
+}
+
+// FinalizeInFlight stops tracking in-flight transactions that have been included deep enough to no longer be
+// at risk of a reorg.
+func (m *Mempool) FinalizeInFlight(hashes ...common.Hash) error {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	if err := m.store.Update(&StoreUpdate{DeleteInFlight: hashes}); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		delete(m.inFlight, hash)
+	}
+	return nil
+}
+
+// ReinjectInFlight stops tracking an in-flight transaction and adds it back to the mempool. This is used for
+// transactions that were not included by the sequencer or were removed by a reorg. If the transaction can no
+// longer be admitted, it is dropped with the returned error as the reason. The arrival time is reset so that
+// the transaction is not expired right away and whether it was credited is kept for when it is bundled again.
+func (m *Mempool) ReinjectInFlight(reason string, itx *InFlightTx) error {
+	if err := m.FinalizeInFlight(itx.Hash); err != nil {
+		return err
+	}
+
+	meta := NewTxMetadata()
+	if itx.Meta != nil {
+		meta = itx.Meta.copy()
+		meta.ArrivedAt = time.Now()
+	}
+	meta.Credited = meta.Credited || itx.Credited
+	if err := m.AddTxWithMetadata(itx.Tx, meta); err != nil {
+		dropReason := reason + ": " + err.Error()
+		if err := m.store.Update(&StoreUpdate{
+			PutRemoved: newTxLookups(TxStatusDropped, dropReason, itx.Tx),
+		}); err != nil {
+			return err
+		}
+
+		m.events.emit(EventDropped, dropReason, nil, itx.Tx)
+		return nil
+	}
+
+	m.events.emit(EventReinjected, reason, nil, itx.Tx)
+	return nil
+}
+
+// DropInFlight stops tracking an in-flight transaction that can never be included and records the reason it
+// was dropped.
+func (m *Mempool) DropInFlight(reason string, itx *InFlightTx) error {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	err := m.store.Update(&StoreUpdate{
+		DeleteInFlight: []common.Hash{itx.Hash},
+		PutRemoved:     newTxLookups(TxStatusDropped, reason, itx.Tx),
+	})
+	if err != nil {
+		return err
+	}
+	delete(m.inFlight, itx.Hash)
+
+	m.events.emit(EventDropped, reason, nil, itx.Tx)
+	return nil
+}
+
+// GetInFlightByHash returns a copy of an in-flight transaction or nil if it is not tracked.
+func (m *Mempool) GetInFlightByHash(hash common.Hash) *InFlightTx {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	itx, ok := m.inFlight[hash]
+	if !ok {
+		return nil
+	}
+
+	cp := *itx
+	return &cp
+}
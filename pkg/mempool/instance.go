@@ -3,92 +3,395 @@
 package mempool
 
 import (
-	"bytes"
-	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
 	badger "github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
 // Mempool provides read and write access to a pool of pending AA Transactions which have passed all Client
-// checks.
+// checks. It is safe for concurrent use. Calls to the chain (e.g. fetching the on-chain nonce or base fee) are
+// made before any lock is held so that slow RPC calls do not block other readers and writers.
+//
+// Writes are serialised per sender rather than across the whole mempool. A writer locks the senders of every
+// transaction it changes, writes to the Store, and then takes mu only to apply the change to the in-memory
+// queue. Admissions, revalidation updates, and bundling for different senders therefore do not wait on each
+// other's Store I/O. Locks are acquired in the order senderMu, inFlightMu, mu.
 type Mempool struct {
-	db    *badger.DB
+	// senderMu serialises writes for the transactions of a sender. Senders are spread across a fixed number of
+	// stripes.
+	senderMu [senderLockStripes]sync.Mutex
+
+	// mu guards the queue. It is held while the queue is read or updated but never during Store I/O.
+	mu    sync.RWMutex
+	store Store
 	queue *rip7560TxQueues
+
+	// inFlightMu guards the in-flight transactions.
+	inFlightMu sync.Mutex
+	inFlight   map[common.Hash]*InFlightTx
+
+	// reserved and released are the usage added and removed by admissions that are not yet applied to the
+	// queue. They are guarded by mu.
+	reserved usage
+	released usage
+
+	maxTxs     int
+	maxGas     uint64
+	getBaseFee GetBaseFeeFunc
+	getNonce   GetNonceFunc
+	priceBump  int64
+	events     *eventFeed
 }
 
 // New creates an instance of a mempool that uses an embedded DB to persist and load AA Transactions from disk
 // incase of a reset.
 func New(db *badger.DB) (*Mempool, error) {
+	return NewWithStore(NewBadgerStore(db))
+}
+
+// NewWithStore creates an instance of a mempool that persists and loads AA Transactions using the given Store.
+func NewWithStore(store Store) (*Mempool, error) {
 	queue := newRip7560TxQueue()
-	err := loadFromDisk(db, queue)
+	entries, err := store.LoadTxs()
 	if err != nil {
 		return nil, err
 	}
+	for _, entry := range entries {
+		if entry.Meta == nil {
+			entry.Meta = NewTxMetadata()
+		}
+
+		// The on-chain nonce may have moved since the tx was saved. Hold it in the queued sub-pool until it
+		// is checked again.
+		queue.AddTx(entry.Tx, entry.Tx.ToTransaction().Hash(), entry.Meta)
+		queue.SetQueued(entry.Tx, true)
+	}
 
-	return &Mempool{db, queue}, nil
+	itxs, err := store.LoadInFlight()
+	if err != nil {
+		return nil, err
+	}
+	inFlight := make(map[common.Hash]*InFlightTx)
+	for _, itx := range itxs {
+		inFlight[itx.Hash] = itx
+	}
+
+	return &Mempool{store: store, queue: queue, events: newEventFeed(), inFlight: inFlight}, nil
 }
 
-// GetTxs returns all the AA Transactions associated with Sender address.
+// GetTxs returns all the AA Transactions associated with an entity address. Transactions sent by the entity
+// are grouped by nonce key, with each key being an independent nonce sequence.
 func (m *Mempool) GetTxs(sender common.Address) ([]*transaction.TransactionArgs, error) {
 	if sender == (common.Address{}) {
 		return []*transaction.TransactionArgs{}, nil
 	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	txs := m.queue.GetTxs(sender)
 	return txs, nil
 }
 
-// AddTx adds a AA Transaction to the mempool or replace an existing one with the Sender, and
-// Nonce values.
+// GetTxByHash returns a pending AA Transaction from the mempool by its hash or nil if it does not exist.
+func (m *Mempool) GetTxByHash(hash common.Hash) (*transaction.TransactionArgs, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queue.GetByHash(hash), nil
+}
+
+// GetTxsByNonceKey returns all the AA Transactions from a Sender that use the given RIP-7712 nonce key,
+// ordered by ascending nonce.
+func (m *Mempool) GetTxsByNonceKey(sender common.Address, nonceKey *big.Int) ([]*transaction.TransactionArgs, error) {
+	if sender == (common.Address{}) {
+		return []*transaction.TransactionArgs{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	txs := m.queue.GetTxsByNonceKey(sender, nonceKey)
+	return txs, nil
+}
+
+// AddTx adds a AA Transaction to the mempool or replace an existing one with the same Sender, NonceKey, and
+// Nonce values. A replacement must increase its fees by the replacement price bump, otherwise
+// ErrReplacementTxUnderpriced is returned. Transactions that leave a gap after the on-chain nonce are held in a
+// queued sub-pool until the earlier nonce arrives or is mined. If the mempool is at capacity, the cheapest
+// transactions by effective gas price are evicted to make room. ErrMempoolFull is returned if the transaction
+// does not outbid them.
 func (m *Mempool) AddTx(tx *transaction.TransactionArgs) error {
-	var buf bytes.Buffer
-	err := rlp.Encode(&buf, tx)
+	return m.AddTxWithMetadata(tx, NewTxMetadata())
+}
+
+// admission is the set of changes needed to add a transaction to the mempool.
+type admission struct {
+	prev  *transaction.TransactionArgs
+	evict []*transaction.TransactionArgs
+	stale []*transaction.TransactionArgs
+}
+
+// getAdmission checks if tx can be added to the mempool and returns the changes needed to do so. The caller
+// must hold mu and the lock of tx's sender.
+func (m *Mempool) getAdmission(tx *transaction.TransactionArgs, bf *big.Int, next uint64) (*admission, error) {
+	adm := &admission{prev: m.queue.Get(tx)}
+	if adm.prev != nil {
+		if err := m.checkReplacement(adm.prev, tx); err != nil {
+			return nil, err
+		}
+	}
+	evict, err := m.getEvictions(tx, bf)
 	if err != nil {
-		return fmt.Errorf("failed to RLP encode transaction: %v", err)
+		return nil, err
+	}
+	adm.evict = evict
+	if m.getNonce != nil {
+		for _, t := range m.queue.GetTxsByNonceKey(tx.GetSender(), tx.GetNonceKey()) {
+			if t.GetNonce() < next {
+				adm.stale = append(adm.stale, t)
+			}
+		}
+	}
+
+	return adm, nil
+}
+
+// AddTxWithMetadata is the same as AddTx but also saves the given metadata next to the transaction.
+func (m *Mempool) AddTxWithMetadata(tx *transaction.TransactionArgs, meta *TxMetadata) error {
+	if meta == nil {
+		meta = NewTxMetadata()
+	}
+	hash := tx.ToTransaction().Hash()
+	var next uint64
+	if m.getNonce != nil {
+		var err error
+		if next, err = m.getNonce(tx.GetSender(), tx.GetNonceKey()); err != nil {
+			return err
+		} else if tx.GetNonce() < next {
+			return ErrNonceTooLow
+		}
+	}
+	bf, err := m.getBaseFeeIfFull(tx)
+	if err != nil {
+		return err
+	}
+
+	// Evicted transactions belong to other senders which must also be locked. They are only known once the
+	// queue is read, so the admission is retried with the extra senders locked if needed.
+	var adm *admission
+	senders := []common.Address{tx.GetSender()}
+	for {
+		unlock := m.lockSenders(senders...)
+		m.mu.Lock()
+		adm, err = m.getAdmission(tx, bf, next)
+		if err != nil {
+			m.mu.Unlock()
+			unlock()
+			return err
+		}
+
+		locked := make(map[common.Address]bool)
+		for _, s := range senders {
+			locked[s] = true
+		}
+		missing := []common.Address{}
+		for _, e := range adm.evict {
+			if !locked[e.GetSender()] {
+				locked[e.GetSender()] = true
+				missing = append(missing, e.GetSender())
+			}
+		}
+		if len(missing) == 0 {
+			m.reserve(adm, tx)
+			m.mu.Unlock()
+			defer unlock()
+			break
+		}
+		m.mu.Unlock()
+		unlock()
+		senders = append(senders, missing...)
 	}
-	err = m.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(getUniqueKey(tx.GetSender(), tx.Nonce, tx.NonceKey), buf.Bytes())
+
+	err = m.store.Update(&StoreUpdate{
+		PutTxs:    []*TxEntry{{Tx: tx, Meta: meta}},
+		DeleteTxs: append(append([]*transaction.TransactionArgs{}, adm.evict...), adm.stale...),
+		PutRemoved: append(
+			newTxLookups(TxStatusDropped, DropReasonEvicted, adm.evict...),
+			newTxLookups(TxStatusDropped, DropReasonNonceTooLow, adm.stale...)...,
+		),
 	})
+	m.mu.Lock()
+	m.unreserve(adm, tx)
 	if err != nil {
+		m.mu.Unlock()
 		return err
 	}
+	m.queue.RemoveTxs(adm.evict...)
+	m.queue.RemoveTxs(adm.stale...)
+	m.queue.AddTx(tx, hash, meta)
+	if m.getNonce == nil {
+		next = m.getLaneStart(tx.GetSender(), tx.GetNonceKey())
+	}
+	m.updateLane(tx.GetSender(), tx.GetNonceKey(), next)
+	m.mu.Unlock()
 
-	m.queue.AddTx(tx)
+	m.events.emit(EventDropped, DropReasonEvicted, nil, adm.evict...)
+	if adm.prev != nil {
+		m.events.emit(EventReplaced, "", adm.prev, tx)
+	} else {
+		m.events.emit(EventAdded, "", nil, tx)
+	}
+	if len(adm.stale) > 0 {
+		m.events.emit(EventDropped, DropReasonNonceTooLow, nil, adm.stale...)
+	}
 	return nil
 }
 
-// RemoveTxs removes a list of AA Transactions from the mempool by Sender, and Nonce values.
+// getCurrent returns the transactions in txs that are still in the mempool. A transaction that has since been
+// replaced by another one with the same Sender, NonceKey, and Nonce values is left out.
+func (m *Mempool) getCurrent(txs []*transaction.TransactionArgs) []*transaction.TransactionArgs {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queue.Current(txs...)
+}
+
+// RemoveTxs removes a list of AA Transactions from the mempool by Sender, NonceKey, and Nonce values.
+// Transactions that have been replaced since they were read from the mempool are skipped. No events are
+// emitted, use BundleTxs or DropTxs to notify subscribers of the removal.
 func (m *Mempool) RemoveTxs(txs ...*transaction.TransactionArgs) error {
-	err := m.db.Update(func(txn *badger.Txn) error {
-		for _, tx := range txs {
-			err := txn.Delete(getUniqueKey(tx.GetSender(), tx.Nonce, tx.NonceKey))
-			if err != nil {
-				return err
-			}
+	defer m.lockSenders(getSenders(txs...)...)()
+	txs = m.getCurrent(txs)
+	if len(txs) == 0 {
+		return nil
+	}
+	if err := m.store.Update(&StoreUpdate{DeleteTxs: txs}); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue.RemoveTxs(txs...)
+	return nil
+}
+
+// BundleTxs removes a list of AA Transactions from the mempool that have been included in a bundle. They are
+// tracked as in-flight until they are final or need to be reinjected. Transactions that have been replaced
+// since they were read from the mempool are skipped.
+func (m *Mempool) BundleTxs(txs ...*transaction.TransactionArgs) error {
+	defer m.lockSenders(getSenders(txs...)...)()
+	m.mu.RLock()
+	txs = m.queue.Current(txs...)
+	now := time.Now()
+	itxs := []*InFlightTx{}
+	for _, tx := range txs {
+		var meta *TxMetadata
+		if stored := m.queue.GetMeta(tx); stored != nil {
+			meta = stored.copy()
 		}
+		hash, _ := m.queue.Hash(tx)
+		itxs = append(itxs, &InFlightTx{
+			Tx:       tx,
+			Meta:     meta,
+			Hash:     hash,
+			ServedAt: now,
+			Credited: meta != nil && meta.Credited,
+		})
+	}
+	m.mu.RUnlock()
+	if len(txs) == 0 {
+		return nil
+	}
+
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	err := m.store.Update(&StoreUpdate{
+		DeleteTxs:   txs,
+		PutRemoved:  newTxLookups(TxStatusBundled, "", txs...),
+		PutInFlight: itxs,
+	})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.queue.RemoveTxs(txs...)
+	m.mu.Unlock()
+	for _, itx := range itxs {
+		m.inFlight[itx.Hash] = itx
+	}
 
+	m.events.emit(EventBundled, "", nil, txs...)
+	return nil
+}
+
+// DropTxs removes a list of AA Transactions from the mempool and records the reason for dropping them. Any
+// transactions left behind a nonce gap are moved to the queued sub-pool. Transactions that have been replaced
+// since they were read from the mempool are skipped.
+func (m *Mempool) DropTxs(reason string, txs ...*transaction.TransactionArgs) error {
+	defer m.lockSenders(getSenders(txs...)...)()
+	return m.dropTxs(reason, true, m.getCurrent(txs)...)
+}
+
+// dropTxs removes transactions from the mempool. The caller must hold the locks of their senders but not mu.
+func (m *Mempool) dropTxs(reason string, demote bool, txs ...*transaction.TransactionArgs) error {
+	if len(txs) == 0 {
 		return nil
+	}
+	err := m.store.Update(&StoreUpdate{
+		DeleteTxs:  txs,
+		PutRemoved: newTxLookups(TxStatusDropped, reason, txs...),
 	})
 	if err != nil {
 		return err
 	}
 
+	m.mu.Lock()
 	m.queue.RemoveTxs(txs...)
+	if demote {
+		for _, tx := range txs {
+			m.demoteAfter(tx)
+		}
+	}
+	m.mu.Unlock()
+
+	typ := EventDropped
+	if reason == DropReasonExpired {
+		typ = EventExpired
+	}
+	m.events.emit(typ, reason, nil, txs...)
 	return nil
 }
 
-// Dump will return a list of AA Transactions from the mempool by EntryPoint in the order it arrived.
+// Dump will return a list of executable AA Transactions from the mempool in the order it arrived. Queued
+// transactions with a nonce gap are not included.
 func (m *Mempool) Dump() ([]*transaction.TransactionArgs, error) {
-	return m.queue.All(), nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queue.Pending(), nil
+}
+
+// PendingCount returns the number of executable AA Transactions in the mempool without copying them.
+func (m *Mempool) PendingCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queue.PendingCount()
 }
 
-// Clear will clear the entire embedded db and reset it to a clean state.
+// Clear will clear the entire Store and reset it to a clean state.
 func (m *Mempool) Clear() error {
-	if err := m.db.DropAll(); err != nil {
+	defer m.lockAllSenders()()
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+	if err := m.store.Clear(); err != nil {
 		return err
 	}
+	m.mu.Lock()
+	txs := m.queue.All()
 	m.queue = newRip7560TxQueue()
+	m.mu.Unlock()
+	m.inFlight = make(map[common.Hash]*InFlightTx)
 
+	m.events.emit(EventDropped, DropReasonCleared, nil, txs...)
 	return nil
 }
@@ -1,7 +1,9 @@
 package mempool
 
 import (
+	"errors"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"math/big"
 	"testing"
 
@@ -64,6 +66,72 @@ func TestReplaceTxInMempool(t *testing.T) {
 	}
 }
 
+// TestReplaceTxWithPriceBump verifies that a replacement must increase its fees by the replacement price bump.
+func TestReplaceTxWithPriceBump(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	mem.SetReplacementPriceBump(10)
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx1.MaxFeePerGas = (*hexutil.Big)(big.NewInt(100))
+	tx1.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(100))
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.MaxFeePerGas = (*hexutil.Big)(big.NewInt(109))
+	tx2.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(110))
+	tx3 := testutils.MockValidInitRip7560Tx()
+	tx3.MaxFeePerGas = (*hexutil.Big)(big.NewInt(110))
+	tx3.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(110))
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); !errors.Is(err, ErrReplacementTxUnderpriced) {
+		t.Fatalf("got %v, want ErrReplacementTxUnderpriced", err)
+	}
+	if err := mem.AddTx(tx3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestStaleTxDoesNotRemoveReplacement verifies that a transaction read from the mempool before it was replaced
+// does not remove, bundle, or update the replacement.
+func TestStaleTxDoesNotRemoveReplacement(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.MaxFeePerGas = (*hexutil.Big)(new(big.Int).Add(tx1.MaxFeePerGas.ToInt(), big.NewInt(1)))
+	tx2.MaxPriorityFeePerGas = tx2.MaxFeePerGas
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if err := mem.DropTxs("test", tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.BundleTxs(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.RemoveTxs(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	err := mem.UpdateMetadata(func(_ *transaction.TransactionArgs, meta *TxMetadata) { meta.RevalidationCount++ }, tx1)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if dump, _ := mem.Dump(); len(dump) != 1 || !testutils.IsTxsEqual(dump[0], tx2) {
+		t.Fatalf("got %v, want replacement", dump)
+	}
+	if meta := mem.GetMetadata(tx2); meta.RevalidationCount != 0 {
+		t.Fatalf("got revalidation count %d, want 0", meta.RevalidationCount)
+	}
+	if itxs := mem.GetInFlight(); len(itxs) != 0 {
+		t.Fatalf("got in-flight length %d, want 0", len(itxs))
+	}
+}
+
 // TestRemoveTxsFromMempool verifies that a Rip-7560 transactions can be added to the mempool and later removed.
 func TestRemoveTxsFromMempool(t *testing.T) {
 	db := testutils.DBMock()
@@ -140,7 +208,7 @@ func TestNewMempoolLoadsFromDisk(t *testing.T) {
 	mem1, _ := New(db)
 	tx1 := testutils.MockValidInitRip7560Tx()
 	tx2 := testutils.MockValidInitRip7560Tx()
-	tx2.BigNonce = (*hexutil.Big)(big.NewInt(0).Add((*big.Int)(tx1.BigNonce), common.Big1))
+	tx2.NonceKey = (*hexutil.Big)(big.NewInt(0).Add((*big.Int)(tx1.NonceKey), common.Big1))
 	tx2.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(0).Add((*big.Int)(tx1.MaxPriorityFeePerGas), common.Big1))
 
 	if err := mem1.AddTx(tx1); err != nil {
@@ -166,3 +234,52 @@ func TestNewMempoolLoadsFromDisk(t *testing.T) {
 		t.Fatalf("txsS not equal: %s", testutils.GetTxsDiff(tx2, memTxs[0]))
 	}
 }
+
+// TestNonceKeysInMempool verifies that Rip-7560 transactions from the same Sender with the same Nonce but a
+// different NonceKey do not replace each other and are returned grouped by NonceKey.
+func TestNonceKeysInMempool(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	mem, _ := New(db)
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx1.NonceKey = (*hexutil.Big)(big.NewInt(2))
+
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.NonceKey = (*hexutil.Big)(big.NewInt(1))
+
+	tx3 := testutils.MockValidInitRip7560Tx()
+	tx3.NonceKey = (*hexutil.Big)(big.NewInt(1))
+	tx3.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2, tx3} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	memTxs, err := mem.GetTxs(tx1.GetSender())
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	expected := []*transaction.TransactionArgs{tx3, tx2, tx1}
+	if len(memTxs) != len(expected) {
+		t.Fatalf("got length %d, want %d", len(memTxs), len(expected))
+	}
+	for i, memTx := range memTxs {
+		if !testutils.IsTxsEqual(memTx, expected[i]) {
+			t.Fatalf("txs not equal: %s", testutils.GetTxsDiff(memTx, expected[i]))
+		}
+	}
+
+	laneTxs, err := mem.GetTxsByNonceKey(tx2.GetSender(), tx2.GetNonceKey())
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(laneTxs) != 2 {
+		t.Fatalf("got length %d, want 2", len(laneTxs))
+	} else if !testutils.IsTxsEqual(laneTxs[0], tx2) {
+		t.Fatal("incorrect order: first tx out of place")
+	} else if !testutils.IsTxsEqual(laneTxs[1], tx3) {
+		t.Fatal("incorrect order: second tx out of place")
+	}
+}
@@ -0,0 +1,157 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+const (
+	// DropReasonEvicted is recorded for transactions removed to make room for a higher paying one.
+	DropReasonEvicted = "evicted: mempool full and outbid by higher gas price"
+)
+
+var (
+	// ErrMempoolFull is returned when the mempool is at capacity and the transaction does not pay a higher
+	// effective gas price than the cheapest evictable transaction.
+	ErrMempoolFull = errors.New("mempool is full: gas price too low to replace existing transactions")
+
+	// ErrTxGasTooLarge is returned when a single transaction uses more gas than the mempool is allowed to hold.
+	ErrTxGasTooLarge = errors.New("transaction total gas limit exceeds mempool capacity")
+)
+
+// GetBaseFeeFunc returns the current base fee used to compare effective gas prices during eviction.
+type GetBaseFeeFunc = func() (*big.Int, error)
+
+// SetLimits sets the maximum number of transactions and the maximum sum of total gas limits that the mempool
+// can hold. A value of 0 disables the respective limit.
+func (m *Mempool) SetLimits(maxTxs int, maxGas uint64) {
+	m.maxTxs = maxTxs
+	m.maxGas = maxGas
+}
+
+// SetGetBaseFeeFunc defines the function used to retrieve the base fee when comparing transactions for
+// eviction. If not set, a base fee of 0 is assumed.
+func (m *Mempool) SetGetBaseFeeFunc(fn GetBaseFeeFunc) {
+	m.getBaseFee = fn
+}
+
+func (m *Mempool) isFull(count int, gas uint64) bool {
+	return (m.maxTxs > 0 && count > m.maxTxs) || (m.maxGas > 0 && gas > m.maxGas)
+}
+
+// usage is a number of transactions and the sum of their total gas limits.
+type usage struct {
+	txs int
+	gas uint64
+}
+
+// getUsage returns the usage that the admission of tx adds to and removes from the mempool.
+func (adm *admission) getUsage(tx *transaction.TransactionArgs) (add usage, remove usage) {
+	add.gas = tx.GetTotalGasLimit()
+	if adm.prev == nil {
+		add.txs = 1
+	} else {
+		remove.gas = adm.prev.GetTotalGasLimit()
+	}
+	for _, e := range adm.evict {
+		remove.txs++
+		remove.gas += e.GetTotalGasLimit()
+	}
+	return add, remove
+}
+
+// reserve counts the usage of an admission towards the mempool limits until it is applied to the queue. This
+// stops admissions from different senders going over the limits while they write to the Store at the same
+// time. The caller must hold mu.
+func (m *Mempool) reserve(adm *admission, tx *transaction.TransactionArgs) {
+	add, remove := adm.getUsage(tx)
+	m.reserved.txs += add.txs
+	m.reserved.gas += add.gas
+	m.released.txs += remove.txs
+	m.released.gas += remove.gas
+}
+
+// unreserve undoes reserve. The caller must hold mu.
+func (m *Mempool) unreserve(adm *admission, tx *transaction.TransactionArgs) {
+	add, remove := adm.getUsage(tx)
+	m.reserved.txs -= add.txs
+	m.reserved.gas -= add.gas
+	m.released.txs -= remove.txs
+	m.released.gas -= remove.gas
+}
+
+// getUsageWith returns the number of transactions and the sum of total gas limits in the mempool if tx was
+// added. Admissions that are not yet applied to the queue are included.
+func (m *Mempool) getUsageWith(tx *transaction.TransactionArgs) (int, uint64) {
+	count := m.queue.Count() + m.reserved.txs - m.released.txs + 1
+	gas := m.queue.TotalGas() + m.reserved.gas + tx.GetTotalGasLimit() - m.released.gas
+	if prev := m.queue.Get(tx); prev != nil {
+		count--
+		gas -= prev.GetTotalGasLimit()
+	}
+	return count, gas
+}
+
+// getBaseFeeIfFull returns the base fee needed to compare transactions for eviction if adding tx would go over
+// the mempool limits. Otherwise it returns nil without making a call to getBaseFee.
+func (m *Mempool) getBaseFeeIfFull(tx *transaction.TransactionArgs) (*big.Int, error) {
+	m.mu.RLock()
+	full := m.isFull(m.getUsageWith(tx))
+	m.mu.RUnlock()
+	if !full || m.getBaseFee == nil {
+		return nil, nil
+	}
+
+	return m.getBaseFee()
+}
+
+// getEvictions returns the transactions that need to be dropped in order for tx to fit within the mempool
+// limits. Only the highest nonce of each (sender, nonce key) sequence is considered so that evictions never
+// leave a nonce gap behind. Once a tail is evicted, the next highest nonce in its sequence becomes a candidate.
+// A nil base fee is treated as 0. The caller must hold mu.
+func (m *Mempool) getEvictions(tx *transaction.TransactionArgs, bf *big.Int) ([]*transaction.TransactionArgs, error) {
+	if tx.HasTotalGasLimitOverflow() || (m.maxGas > 0 && tx.GetTotalGasLimit() > m.maxGas) {
+		return nil, ErrTxGasTooLarge
+	}
+
+	count, gas := m.getUsageWith(tx)
+	if !m.isFull(count, gas) {
+		return nil, nil
+	}
+	price := tx.GetEffectiveBid(bf)
+
+	candidates := []*transaction.TransactionArgs{}
+	for _, c := range m.queue.LaneTails() {
+		if c.GetSender() == tx.GetSender() && c.GetNonceKey().Cmp(tx.GetNonceKey()) == 0 {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	evict := []*transaction.TransactionArgs{}
+	for m.isFull(count, gas) {
+		idx := -1
+		var cheapest *big.Int
+		for i, c := range candidates {
+			if c == nil {
+				continue
+			}
+			if p := c.GetEffectiveBid(bf); cheapest == nil || p.Cmp(cheapest) < 0 {
+				idx, cheapest = i, p
+			}
+		}
+		if idx < 0 || price.Cmp(cheapest) <= 0 {
+			return nil, ErrMempoolFull
+		}
+
+		evicted := candidates[idx]
+		evict = append(evict, evicted)
+		count--
+		gas -= evicted.GetTotalGasLimit()
+		candidates[idx] = m.queue.GetPrevInLane(evicted)
+	}
+
+	return evict, nil
+}
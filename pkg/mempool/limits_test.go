@@ -0,0 +1,157 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func withSenderAndPrice(tx *transaction.TransactionArgs, sender common.Address, price int64) {
+	tx.Sender = &sender
+	tx.MaxFeePerGas = (*hexutil.Big)(big.NewInt(price))
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(price))
+}
+
+// TestFullMempoolEvictsCheapestTx verifies that a higher paying RIP-7560 transaction evicts the cheapest
+// transaction from a full mempool and that a drop reason is recorded for it.
+func TestFullMempoolEvictsCheapestTx(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	mem.SetLimits(2, 0)
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx1, common.HexToAddress("0x1"), 100)
+	tx2 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx2, common.HexToAddress("0x2"), 50)
+	tx3 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx3, common.HexToAddress("0x3"), 75)
+
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2, tx3} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	dump, _ := mem.Dump()
+	if len(dump) != 2 {
+		t.Fatalf("got length %d, want 2", len(dump))
+	}
+	if txs, _ := mem.GetTxs(tx2.GetSender()); len(txs) != 0 {
+		t.Fatalf("got length %d, want 0", len(txs))
+	}
+
+	reason, err := mem.GetDropReason(tx2.ToTransaction().Hash())
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if reason != DropReasonEvicted {
+		t.Fatalf("got %s, want %s", reason, DropReasonEvicted)
+	}
+}
+
+// TestFullMempoolRejectsUnderpricedTx verifies that a RIP-7560 transaction is rejected from a full mempool if
+// it does not outbid the cheapest transaction.
+func TestFullMempoolRejectsUnderpricedTx(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	mem.SetLimits(1, 0)
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx1, common.HexToAddress("0x1"), 100)
+	tx2 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx2, common.HexToAddress("0x2"), 100)
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); !errors.Is(err, ErrMempoolFull) {
+		t.Fatalf("got %v, want ErrMempoolFull", err)
+	}
+	if txs, _ := mem.GetTxs(tx1.GetSender()); len(txs) != 1 {
+		t.Fatalf("got length %d, want 1", len(txs))
+	}
+}
+
+// TestFullMempoolAllowsReplacement verifies that replacing an existing RIP-7560 transaction does not count
+// towards the mempool limits.
+func TestFullMempoolAllowsReplacement(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	mem.SetLimits(1, 0)
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(0).Add((*big.Int)(tx1.MaxPriorityFeePerGas), common.Big1))
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestMempoolGasLimit verifies that the total gas limit of the mempool is enforced.
+func TestMempoolGasLimit(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx1, common.HexToAddress("0x1"), 100)
+	tx2 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx2, common.HexToAddress("0x2"), 200)
+	mem.SetLimits(0, tx1.GetTotalGasLimit())
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if mem.queue.TotalGas() != tx2.GetTotalGasLimit() {
+		t.Fatalf("got total gas %d, want %d", mem.queue.TotalGas(), tx2.GetTotalGasLimit())
+	}
+
+	mem.SetLimits(0, tx1.GetTotalGasLimit()-1)
+	tx3 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(tx3, common.HexToAddress("0x3"), 300)
+	if err := mem.AddTx(tx3); !errors.Is(err, ErrTxGasTooLarge) {
+		t.Fatalf("got %v, want ErrTxGasTooLarge", err)
+	}
+}
+
+// TestFullMempoolEvictsWholeLane verifies that once the tail of a sequence is evicted, the next nonce in the
+// same sequence can also be evicted to make room.
+func TestFullMempoolEvictsWholeLane(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+
+	a0 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(a0, common.HexToAddress("0x1"), 10)
+	a1 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(a1, common.HexToAddress("0x1"), 10)
+	nonce := hexutil.Uint64(1)
+	a1.Nonce = &nonce
+	c0 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(c0, common.HexToAddress("0x3"), 100)
+	mem.SetLimits(0, 3*a0.GetTotalGasLimit())
+
+	for _, tx := range []*transaction.TransactionArgs{a0, a1, c0} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	b0 := testutils.MockValidInitRip7560Tx()
+	withSenderAndPrice(b0, common.HexToAddress("0x2"), 50)
+	gas := hexutil.Uint64(uint64(*b0.Gas) + a0.GetTotalGasLimit())
+	b0.Gas = &gas
+	if err := mem.AddTx(b0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if txs, _ := mem.GetTxs(a0.GetSender()); len(txs) != 0 {
+		t.Fatalf("got length %d, want 0", len(txs))
+	}
+	if txs, _ := mem.GetTxs(c0.GetSender()); len(txs) != 1 {
+		t.Fatalf("got length %d, want 1", len(txs))
+	}
+}
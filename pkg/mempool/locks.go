@@ -0,0 +1,61 @@
+package mempool
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// senderLockStripes is the number of locks that senders are spread across. Writes for senders on different
+// stripes can run at the same time.
+const senderLockStripes = 64
+
+func getSenderStripe(sender common.Address) int {
+	h := fnv.New32a()
+	_, _ = h.Write(sender.Bytes())
+	return int(h.Sum32() % senderLockStripes)
+}
+
+func getSenders(txs ...*transaction.TransactionArgs) []common.Address {
+	senders := make([]common.Address, 0, len(txs))
+	for _, tx := range txs {
+		senders = append(senders, tx.GetSender())
+	}
+	return senders
+}
+
+// lockSenders locks the stripes of the given senders in ascending order and returns a function to unlock them.
+func (m *Mempool) lockSenders(senders ...common.Address) func() {
+	seen := make(map[int]bool)
+	stripes := []int{}
+	for _, s := range senders {
+		if i := getSenderStripe(s); !seen[i] {
+			seen[i] = true
+			stripes = append(stripes, i)
+		}
+	}
+	sort.Ints(stripes)
+
+	for _, i := range stripes {
+		m.senderMu[i].Lock()
+	}
+	return func() {
+		for j := len(stripes) - 1; j >= 0; j-- {
+			m.senderMu[stripes[j]].Unlock()
+		}
+	}
+}
+
+// lockAllSenders locks every stripe and returns a function to unlock them.
+func (m *Mempool) lockAllSenders() func() {
+	for i := range m.senderMu {
+		m.senderMu[i].Lock()
+	}
+	return func() {
+		for i := len(m.senderMu) - 1; i >= 0; i-- {
+			m.senderMu[i].Unlock()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package mempool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type removedEntry struct {
+	lookup    *TxLookup
+	expiresAt time.Time
+}
+
+// MemoryStore is a Store that only keeps the mempool in memory. It is intended for tests and ephemeral
+// networks where the mempool does not need to survive a restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	txs      map[string]*TxEntry
+	order    []string
+	removed  map[common.Hash]*removedEntry
+	inFlight map[common.Hash]*InFlightTx
+}
+
+// NewMemoryStore returns an empty Store that is not persisted.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	s.reset()
+	return s
+}
+
+func (s *MemoryStore) reset() {
+	s.txs = make(map[string]*TxEntry)
+	s.order = []string{}
+	s.removed = make(map[common.Hash]*removedEntry)
+	s.inFlight = make(map[common.Hash]*InFlightTx)
+}
+
+// LoadTxs implements the Store interface.
+func (s *MemoryStore) LoadTxs() ([]*TxEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []*TxEntry{}
+	for _, key := range s.order {
+		if entry, ok := s.txs[key]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// LoadInFlight implements the Store interface.
+func (s *MemoryStore) LoadInFlight() ([]*InFlightTx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	itxs := []*InFlightTx{}
+	for _, itx := range s.inFlight {
+		cp := *itx
+		itxs = append(itxs, &cp)
+	}
+	return itxs, nil
+}
+
+// GetRemoved implements the Store interface.
+func (s *MemoryStore) GetRemoved(hash common.Hash) (*TxLookup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.removed[hash]
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, nil
+	}
+	return e.lookup, nil
+}
+
+// Update implements the Store interface.
+func (s *MemoryStore) Update(u *StoreUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tx := range u.DeleteTxs {
+		delete(s.txs, string(getUniqueKey(tx)))
+	}
+	for _, entry := range u.PutTxs {
+		key := string(getUniqueKey(entry.Tx))
+		if _, ok := s.txs[key]; !ok {
+			s.order = append(s.order, key)
+		}
+		s.txs[key] = entry
+	}
+	s.compactOrder()
+
+	now := time.Now()
+	for hash, e := range s.removed {
+		if now.After(e.expiresAt) {
+			delete(s.removed, hash)
+		}
+	}
+	for _, lookup := range u.PutRemoved {
+		s.removed[lookup.Tx.ToTransaction().Hash()] = &removedEntry{lookup, now.Add(removedTxTTL)}
+	}
+
+	for _, hash := range u.DeleteInFlight {
+		delete(s.inFlight, hash)
+	}
+	for _, itx := range u.PutInFlight {
+		cp := *itx
+		s.inFlight[itx.Hash] = &cp
+	}
+
+	return nil
+}
+
+// compactOrder drops keys of deleted transactions from the arrival order.
+func (s *MemoryStore) compactOrder() {
+	if len(s.order) == len(s.txs) {
+		return
+	}
+
+	order := make([]string, 0, len(s.txs))
+	seen := make(map[string]bool)
+	for _, key := range s.order {
+		if _, ok := s.txs[key]; ok && !seen[key] {
+			seen[key] = true
+			order = append(order, key)
+		}
+	}
+	s.order = order
+}
+
+// Clear implements the Store interface.
+func (s *MemoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
@@ -0,0 +1,98 @@
+package mempool
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TxMetadata is information about a transaction that is collected by the bundler and saved next to it in the
+// Store.
+type TxMetadata struct {
+	// ArrivedAt is the time the transaction was first added to the mempool or reinjected after it was
+	// bundled.
+	ArrivedAt time.Time `json:"arrivedAt"`
+
+	// SubmitterIP is the IP address of the client that sent the transaction.
+	SubmitterIP string `json:"submitterIp,omitempty"`
+
+	// ValidAfter and ValidUntil are the validity window returned by the last successful simulation. A
+	// ValidUntil of 0 means the transaction does not expire.
+	ValidAfter uint64 `json:"validAfter,omitempty"`
+	ValidUntil uint64 `json:"validUntil,omitempty"`
+
+	// TouchedContracts are the contracts accessed during validation.
+	TouchedContracts []common.Address `json:"touchedContracts,omitempty"`
+
+	// AltMempoolIds are the alternative mempools the transaction was admitted to because it needed one of
+	// their rule exceptions. It is empty for transactions that pass the canonical rules.
+	AltMempoolIds []string `json:"altMempoolIds,omitempty"`
+
+	// RevalidationCount is the number of times the transaction passed validation again after admission.
+	RevalidationCount int       `json:"revalidationCount"`
+	LastValidatedAt   time.Time `json:"lastValidatedAt"`
+
+	// Credited is carried over from a reinjected in-flight transaction whose entities were already credited
+	// with an inclusion that was later removed by a reorg. It prevents the inclusion from being counted twice.
+	Credited bool `json:"credited,omitempty"`
+}
+
+// NewTxMetadata returns metadata for a transaction that arrived now.
+func NewTxMetadata() *TxMetadata {
+	now := time.Now()
+	return &TxMetadata{ArrivedAt: now, LastValidatedAt: now}
+}
+
+func (meta *TxMetadata) copy() *TxMetadata {
+	cp := *meta
+	cp.TouchedContracts = append([]common.Address{}, meta.TouchedContracts...)
+	cp.AltMempoolIds = append([]string{}, meta.AltMempoolIds...)
+	return &cp
+}
+
+// GetMetadata returns a copy of the metadata for a transaction in the mempool by Sender, NonceKey, and Nonce
+// values. Nil is returned if the transaction is not in the mempool.
+func (m *Mempool) GetMetadata(tx *transaction.TransactionArgs) *TxMetadata {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	meta := m.queue.GetMeta(tx)
+	if meta == nil {
+		return nil
+	}
+	return meta.copy()
+}
+
+// UpdateMetadata applies fn to the metadata of each transaction in the mempool and saves the results in a
+// single Store update. Transactions that have been replaced or removed since they were read from the mempool
+// are skipped.
+func (m *Mempool) UpdateMetadata(
+	fn func(tx *transaction.TransactionArgs, meta *TxMetadata),
+	txs ...*transaction.TransactionArgs,
+) error {
+	defer m.lockSenders(getSenders(txs...)...)()
+	m.mu.RLock()
+	entries := []*TxEntry{}
+	for _, tx := range m.queue.Current(txs...) {
+		if meta := m.queue.GetMeta(tx); meta != nil {
+			next := meta.copy()
+			fn(tx, next)
+			entries = append(entries, &TxEntry{Tx: tx, Meta: next})
+		}
+	}
+	m.mu.RUnlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := m.store.Update(&StoreUpdate{PutTxs: entries}); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range entries {
+		m.queue.SetMeta(entry.Tx, entry.Meta)
+	}
+	return nil
+}
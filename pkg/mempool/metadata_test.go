@@ -0,0 +1,86 @@
+package mempool
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestMetadataPersistsAcrossRestart verifies that transaction metadata saved to the DB is loaded again by a
+// new mempool instance.
+func TestMetadataPersistsAcrossRestart(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	mem, _ := New(db)
+	tx := testutils.MockValidInitRip7560Tx()
+	arrivedAt := time.Now().Add(-time.Minute).Round(time.Second)
+	meta := &TxMetadata{
+		ArrivedAt:        arrivedAt,
+		SubmitterIP:      "127.0.0.1",
+		ValidAfter:       1,
+		ValidUntil:       2,
+		TouchedContracts: []common.Address{{0x01}},
+	}
+
+	if err := mem.AddTxWithMetadata(tx, meta); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	err := mem.UpdateMetadata(func(_ *transaction.TransactionArgs, meta *TxMetadata) {
+		meta.RevalidationCount++
+	}, tx)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	restarted, err := New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	got := restarted.GetMetadata(tx)
+	if got == nil {
+		t.Fatal("got nil, want metadata")
+	} else if !got.ArrivedAt.Equal(arrivedAt) {
+		t.Fatalf("got arrivedAt %v, want %v", got.ArrivedAt, arrivedAt)
+	} else if got.SubmitterIP != meta.SubmitterIP {
+		t.Fatalf("got submitterIP %s, want %s", got.SubmitterIP, meta.SubmitterIP)
+	} else if got.ValidAfter != 1 || got.ValidUntil != 2 {
+		t.Fatalf("got validity window [%d, %d], want [1, 2]", got.ValidAfter, got.ValidUntil)
+	} else if len(got.TouchedContracts) != 1 || got.TouchedContracts[0] != (common.Address{0x01}) {
+		t.Fatalf("got touched contracts %v, want [%s]", got.TouchedContracts, common.Address{0x01})
+	} else if got.RevalidationCount != 1 {
+		t.Fatalf("got revalidation count %d, want 1", got.RevalidationCount)
+	}
+}
+
+// TestLoadLegacyTx verifies that transactions saved to the DB without metadata can still be loaded.
+func TestLoadLegacyTx(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	tx := testutils.MockValidInitRip7560Tx()
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set(getUniqueKey(tx), data)
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	mem, err := New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if stored := mem.queue.Get(tx); stored == nil {
+		t.Fatal("got nil, want tx")
+	} else if !testutils.IsTxsEqual(stored, tx) {
+		t.Fatalf("txs not equal: %s", testutils.GetTxsDiff(tx, stored))
+	} else if meta := mem.GetMetadata(tx); meta == nil || meta.ArrivedAt.IsZero() {
+		t.Fatalf("got metadata %v, want arrival time set", meta)
+	}
+}
@@ -0,0 +1,280 @@
+package mempool
+
+import (
+	"container/heap"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/wangjia184/sortedset"
+)
+
+// getLaneKey returns a key that is unique for every (sender, nonce key) sequence.
+func getLaneKey(sender common.Address, nonceKey *big.Int) string {
+	return dbutils.JoinValues(sender.String(), nonceKey.String())
+}
+
+// getEffectiveTip returns the amount per gas the transaction pays above the given base fee, including the
+// builder fee spread over its total gas limit. A nil base fee is treated as 0.
+func getEffectiveTip(tx *transaction.TransactionArgs, baseFee *big.Int) *big.Int {
+	bf := baseFee
+	if bf == nil {
+		bf = big.NewInt(0)
+	}
+	return new(big.Int).Sub(tx.GetEffectiveBid(bf), bf)
+}
+
+// getPriceScore converts the effective tip of a transaction into a sorted set score. Tips beyond the range of
+// an int64 are clamped.
+func getPriceScore(tx *transaction.TransactionArgs, baseFee *big.Int) sortedset.SCORE {
+	tip := getEffectiveTip(tx, baseFee)
+	if !tip.IsInt64() {
+		if tip.Sign() > 0 {
+			return sortedset.SCORE(math.MaxInt64)
+		}
+		return sortedset.SCORE(math.MinInt64)
+	}
+	return sortedset.SCORE(tip.Int64())
+}
+
+// refreshLaneHead updates the price index for a (sender, nonce key) sequence. Only the lowest nonce of each
+// sequence is indexed and only if it is executable.
+func (q *rip7560TxQueues) refreshLaneHead(sender common.Address, nonceKey *big.Int) {
+	lk := getLaneKey(sender, nonceKey)
+	if lss, ok := q.senders[sender][nonceKey.String()]; ok {
+		if n := lss.PeekMin(); n != nil && !q.queued[n.Key()] {
+			tx := n.Value.(*transaction.TransactionArgs)
+			q.heads.AddOrUpdate(lk, getPriceScore(tx, q.refBaseFee), tx)
+			return
+		}
+	}
+	q.heads.Remove(lk)
+}
+
+// HasReferenceBaseFee returns true if the price index is scored against the given base fee.
+func (q *rip7560TxQueues) HasReferenceBaseFee(baseFee *big.Int) bool {
+	if q.refBaseFee == nil || baseFee == nil {
+		return q.refBaseFee == nil && baseFee == nil
+	}
+	return q.refBaseFee.Cmp(baseFee) == 0
+}
+
+// SetReferenceBaseFee scores the price index against a new base fee. This is linear in the number of sequences
+// and is only needed when the base fee changes.
+func (q *rip7560TxQueues) SetReferenceBaseFee(baseFee *big.Int) {
+	if q.HasReferenceBaseFee(baseFee) {
+		return
+	}
+	if baseFee != nil {
+		baseFee = new(big.Int).Set(baseFee)
+	}
+	q.refBaseFee = baseFee
+	for _, n := range q.heads.GetByRankRange(1, -1, false) {
+		tx := n.Value.(*transaction.TransactionArgs)
+		q.heads.AddOrUpdate(n.Key(), getPriceScore(tx, baseFee), tx)
+	}
+}
+
+// nextInLane returns the transaction that follows tx in its (sender, nonce key) sequence if it is executable.
+func (q *rip7560TxQueues) nextInLane(tx *transaction.TransactionArgs) *transaction.TransactionArgs {
+	lss, ok := q.senders[tx.GetSender()][tx.GetNonceKey().String()]
+	if !ok {
+		return nil
+	}
+	rank := lss.FindRank(string(getUniqueKey(tx)))
+	if rank == 0 {
+		return nil
+	}
+	if n := lss.GetByRank(rank+1, false); n != nil && !q.queued[n.Key()] {
+		return n.Value.(*transaction.TransactionArgs)
+	}
+	return nil
+}
+
+// getArrival returns the position of a transaction in the queue by arrival time starting from 0.
+func (q *rip7560TxQueues) getArrival(tx *transaction.TransactionArgs) int {
+	return q.all.FindRank(string(getUniqueKey(tx))) - 1
+}
+
+// LaneHeads returns the lowest executable nonce of every (sender, nonce key) sequence.
+func (q *rip7560TxQueues) LaneHeads() []*transaction.TransactionArgs {
+	batch := []*transaction.TransactionArgs{}
+	for _, n := range q.heads.GetByRankRange(1, -1, false) {
+		batch = append(batch, n.Value.(*transaction.TransactionArgs))
+	}
+	return batch
+}
+
+type pricedTx struct {
+	tx    *transaction.TransactionArgs
+	score sortedset.SCORE
+}
+
+type pricedTxHeap []*pricedTx
+
+func (h pricedTxHeap) Len() int           { return len(h) }
+func (h pricedTxHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h pricedTxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pricedTxHeap) Push(x any)        { *h = append(*h, x.(*pricedTx)) }
+func (h *pricedTxHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// priceIterator walks the executable transactions of the queue from the highest to lowest effective bid one
+// step at a time. A transaction is only returned after every transaction before it in the same (sender, nonce
+// key) sequence. The queue may change between steps, in which case transactions added after the iterator
+// started may or may not be returned but no transaction is returned twice.
+type priceIterator struct {
+	baseFee *big.Int
+
+	// headScore and headKey are the position in the price index of the last lane head taken from it. The next
+	// lane head is the one ranked right below it, even if the lane has since left the index or moved.
+	started   bool
+	headScore sortedset.SCORE
+	headKey   string
+
+	next pricedTxHeap
+	seen map[string]bool
+}
+
+func newPriceIterator(baseFee *big.Int) *priceIterator {
+	return &priceIterator{baseFee: baseFee, seen: make(map[string]bool)}
+}
+
+// isBelowHead returns true if n is ranked below the last lane head taken by the iterator.
+func (it *priceIterator) isBelowHead(n *sortedset.SortedSetNode) bool {
+	return n.Score() < it.headScore || (n.Score() == it.headScore && n.Key() < it.headKey)
+}
+
+// nextHead returns the highest ranked lane head below the last one taken by the iterator.
+func (it *priceIterator) nextHead(q *rip7560TxQueues) *sortedset.SortedSetNode {
+	if !it.started {
+		return q.heads.PeekMax()
+	}
+
+	// The rank of the last head is found directly if it is still indexed at the same position. Otherwise
+	// the highest rank below it is found with a binary search over ranks.
+	below := 0
+	if n := q.heads.GetByKey(it.headKey); n != nil && n.Score() == it.headScore {
+		below = q.heads.FindRank(it.headKey) - 1
+	} else {
+		lo, hi := 0, q.heads.GetCount()
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if it.isBelowHead(q.heads.GetByRank(mid, false)) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		below = lo
+	}
+	if below == 0 {
+		return nil
+	}
+	return q.heads.GetByRank(below, false)
+}
+
+// step returns the next transaction or nil if there are no more. The price index of q must be scored against
+// the base fee of the iterator.
+func (it *priceIterator) step(q *rip7560TxQueues) *transaction.TransactionArgs {
+	for {
+		var tx *transaction.TransactionArgs
+		head := it.nextHead(q)
+		if head != nil && (it.next.Len() == 0 || head.Score() >= it.next[0].score) {
+			tx = head.Value.(*transaction.TransactionArgs)
+			it.started, it.headScore, it.headKey = true, head.Score(), head.Key()
+		} else if it.next.Len() > 0 {
+			tx = heap.Pop(&it.next).(*pricedTx).tx
+		} else {
+			return nil
+		}
+
+		key := string(getUniqueKey(tx))
+		if it.seen[key] {
+			continue
+		}
+		it.seen[key] = true
+		if n := q.nextInLane(tx); n != nil {
+			heap.Push(&it.next, &pricedTx{tx: n, score: getPriceScore(n, it.baseFee)})
+		}
+		return tx
+	}
+}
+
+// IterateByPrice calls fn with executable AA Transactions from the highest to lowest tip above the given base
+// fee plus builder fee per gas until fn returns false. Transactions from the same sender and nonce key are
+// always returned in nonce order. Each step takes the lock only to read the next transaction and costs
+// logarithmic time in the size of the mempool, so callers that only need the best few transactions do not
+// pay for the rest. fn is called without holding the lock and may call other Mempool methods.
+func (m *Mempool) IterateByPrice(baseFee *big.Int, fn func(tx *transaction.TransactionArgs) bool) {
+	it := newPriceIterator(baseFee)
+	for {
+		tx := m.stepByPrice(it)
+		if tx == nil || !fn(tx) {
+			return
+		}
+	}
+}
+
+// stepByPrice returns the next transaction of it. The write lock is only taken if the price index must first
+// be rescored against the base fee of it, which is linear in the number of sequences.
+func (m *Mempool) stepByPrice(it *priceIterator) *transaction.TransactionArgs {
+	m.mu.RLock()
+	if m.queue.HasReferenceBaseFee(it.baseFee) {
+		defer m.mu.RUnlock()
+		return it.step(m.queue)
+	}
+	m.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue.SetReferenceBaseFee(it.baseFee)
+	return it.step(m.queue)
+}
+
+// GetBestTxs returns up to k executable AA Transactions with the highest bid above the given base fee. A k of 0
+// or less returns all executable transactions.
+func (m *Mempool) GetBestTxs(baseFee *big.Int, k int) []*transaction.TransactionArgs {
+	txs := []*transaction.TransactionArgs{}
+	m.IterateByPrice(baseFee, func(tx *transaction.TransactionArgs) bool {
+		txs = append(txs, tx)
+		return k <= 0 || len(txs) < k
+	})
+	return txs
+}
+
+// LaneTx is an executable AA Transaction along with its position in the mempool by arrival time.
+type LaneTx struct {
+	Tx      *transaction.TransactionArgs
+	Arrival int
+}
+
+// GetLaneHeads returns the executable AA Transaction with the lowest nonce of every sender and nonce key
+// sequence. The cost is linear in the number of sequences rather than the number of transactions.
+func (m *Mempool) GetLaneHeads() []*LaneTx {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	heads := []*LaneTx{}
+	for _, tx := range m.queue.LaneHeads() {
+		heads = append(heads, &LaneTx{Tx: tx, Arrival: m.queue.getArrival(tx)})
+	}
+	return heads
+}
+
+// GetNextInLane returns the executable AA Transaction that follows tx in its sender and nonce key sequence or
+// nil if there is none.
+func (m *Mempool) GetNextInLane(tx *transaction.TransactionArgs) *LaneTx {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if n := m.queue.nextInLane(tx); n != nil {
+		return &LaneTx{Tx: n, Arrival: m.queue.getArrival(n)}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package mempool
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func withPrice(sender common.Address, nonce uint64, maxFee int64, tip int64) *transaction.TransactionArgs {
+	tx := withNonce(nonce)
+	tx.Sender = &sender
+	tx.MaxFeePerGas = (*hexutil.Big)(big.NewInt(maxFee))
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(big.NewInt(tip))
+	return tx
+}
+
+func checkTxsOrder(t *testing.T, got []*transaction.TransactionArgs, want ...*transaction.TransactionArgs) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got length %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !testutils.IsTxsEqual(got[i], want[i]) {
+			t.Fatalf("incorrect order at index %d: %s", i, testutils.GetTxsDiff(want[i], got[i]))
+		}
+	}
+}
+
+// TestGetBestTxs verifies that executable RIP-7560 transactions are returned by highest tip while keeping the
+// nonce order of each sender.
+func TestGetBestTxs(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	a0 := withPrice(common.Address{0x01}, 0, 100, 1)
+	a1 := withPrice(common.Address{0x01}, 1, 100, 10)
+	b0 := withPrice(common.Address{0x02}, 0, 100, 5)
+	c5 := withPrice(common.Address{0x03}, 5, 100, 50)
+	for _, tx := range []*transaction.TransactionArgs{a0, a1, b0, c5} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	checkTxsOrder(t, mem.GetBestTxs(nil, 0), b0, a0, a1)
+	checkTxsOrder(t, mem.GetBestTxs(nil, 2), b0, a0)
+
+	if err := mem.DropTxs("test", b0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	checkTxsOrder(t, mem.GetBestTxs(nil, 0), a0, a1)
+}
+
+// TestGetBestTxsBaseFeeChange verifies that the order of RIP-7560 transactions is updated when the base fee
+// caps the tip of some transactions.
+func TestGetBestTxsBaseFeeChange(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	x := withPrice(common.Address{0x01}, 0, 100, 3)
+	y := withPrice(common.Address{0x02}, 0, 12, 10)
+	for _, tx := range []*transaction.TransactionArgs{x, y} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	checkTxsOrder(t, mem.GetBestTxs(big.NewInt(0), 0), y, x)
+	checkTxsOrder(t, mem.GetBestTxs(big.NewInt(10), 0), x, y)
+	checkTxsOrder(t, mem.GetBestTxs(big.NewInt(0), 0), y, x)
+}
+
+// TestLaneHeads verifies that only the lowest executable nonce of each sequence is returned as a head along
+// with its arrival position, and that later nonces are reached through GetNextInLane.
+func TestLaneHeads(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	a0 := withPrice(common.Address{0x01}, 0, 100, 1)
+	b0 := withPrice(common.Address{0x02}, 0, 100, 5)
+	a1 := withPrice(common.Address{0x01}, 1, 100, 10)
+	c5 := withPrice(common.Address{0x03}, 5, 100, 50)
+	for _, tx := range []*transaction.TransactionArgs{a0, b0, a1, c5} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	heads := mem.GetLaneHeads()
+	if len(heads) != 2 {
+		t.Fatalf("got %d heads, want 2", len(heads))
+	}
+	arrivals := make(map[int]*transaction.TransactionArgs)
+	for _, h := range heads {
+		arrivals[h.Arrival] = h.Tx
+	}
+	if !testutils.IsTxsEqual(arrivals[0], a0) || !testutils.IsTxsEqual(arrivals[1], b0) {
+		t.Fatalf("got %v, want a0 at 0 and b0 at 1", arrivals)
+	}
+
+	if n := mem.GetNextInLane(a0); n == nil || !testutils.IsTxsEqual(n.Tx, a1) || n.Arrival != 2 {
+		t.Fatalf("got %v, want a1 at 2", n)
+	}
+	if n := mem.GetNextInLane(a1); n != nil {
+		t.Fatalf("got %v, want nil", n)
+	}
+}
+
+// TestLaneHeadsArrivalAfterRemoval verifies that transactions added after a removal are still ordered after
+// every transaction that arrived before them.
+func TestLaneHeadsArrivalAfterRemoval(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	a0 := withPrice(common.Address{0x01}, 0, 100, 1)
+	c0 := withPrice(common.Address{0x03}, 0, 100, 1)
+	d0 := withPrice(common.Address{0x04}, 0, 100, 1)
+	b0 := withPrice(common.Address{0x02}, 0, 100, 1)
+	for _, tx := range []*transaction.TransactionArgs{a0, c0, d0} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if err := mem.DropTxs("test", a0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(b0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	arrivals := make(map[int]*transaction.TransactionArgs)
+	for _, h := range mem.GetLaneHeads() {
+		arrivals[h.Arrival] = h.Tx
+	}
+	checkTxsOrder(t, []*transaction.TransactionArgs{arrivals[0], arrivals[1], arrivals[2]}, c0, d0, b0)
+}
+
+// TestIterateByPriceCallsMempool verifies that fn can call other Mempool methods while iterating.
+func TestIterateByPriceCallsMempool(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	a0 := withPrice(common.Address{0x01}, 0, 100, 1)
+	b0 := withPrice(common.Address{0x02}, 0, 100, 5)
+	for _, tx := range []*transaction.TransactionArgs{a0, b0} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	got := []*transaction.TransactionArgs{}
+	mem.IterateByPrice(nil, func(tx *transaction.TransactionArgs) bool {
+		got = append(got, tx)
+		if err := mem.DropTxs("test", tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		return true
+	})
+	checkTxsOrder(t, got, b0, a0)
+	checkTxsOrder(t, mem.GetBestTxs(nil, 0))
+}
@@ -1,14 +1,36 @@
 package mempool
 
 import (
+	"math/big"
+	"sort"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"github.com/wangjia184/sortedset"
 )
 
+// nonceLanes holds a sorted set per RIP-7712 nonce key for a single sender. Each set is scored by nonce so
+// that transactions on different keys form independent sequences.
+type nonceLanes map[string]*sortedset.SortedSet
+
 type rip7560TxQueues struct {
 	all      *sortedset.SortedSet
 	entities map[common.Address]*sortedset.SortedSet
+	senders  map[common.Address]nonceLanes
+	byHash   map[common.Hash]string
+	hashes   map[string]common.Hash
+	queued   map[string]bool
+	meta     map[string]*TxMetadata
+	totalGas uint64
+
+	// seq is incremented on every AddTx and used as the arrival score so that scores are never reused after
+	// a removal.
+	seq int64
+
+	// heads indexes the lowest executable nonce of every (sender, nonce key) sequence by tip above
+	// refBaseFee.
+	heads      *sortedset.SortedSet
+	refBaseFee *big.Int
 }
 
 func (q *rip7560TxQueues) getEntitiesSortedSet(entity common.Address) *sortedset.SortedSet {
@@ -19,28 +41,100 @@ func (q *rip7560TxQueues) getEntitiesSortedSet(entity common.Address) *sortedset
 	return q.entities[entity]
 }
 
-func (q *rip7560TxQueues) AddTx(tx *transaction.TransactionArgs) {
-	rip7560Tx := tx.ToTransaction().Rip7560TransactionData()
-	key := string(getUniqueKey(tx.GetSender(), tx.Nonce, tx.NonceKey))
+func (q *rip7560TxQueues) getLaneSortedSet(sender common.Address, nonceKey *big.Int) *sortedset.SortedSet {
+	if _, ok := q.senders[sender]; !ok {
+		q.senders[sender] = make(nonceLanes)
+	}
+	lk := nonceKey.String()
+	if _, ok := q.senders[sender][lk]; !ok {
+		q.senders[sender][lk] = sortedset.New()
+	}
+
+	return q.senders[sender][lk]
+}
+
+// getSortedLaneKeys returns the nonce keys used by a sender in ascending order.
+func (q *rip7560TxQueues) getSortedLaneKeys(sender common.Address) []string {
+	lanes := q.senders[sender]
+	keys := make([]string, 0, len(lanes))
+	for k := range lanes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := new(big.Int).SetString(keys[i], 10)
+		b, _ := new(big.Int).SetString(keys[j], 10)
+		return a.Cmp(b) < 0
+	})
+
+	return keys
+}
 
-	q.all.AddOrUpdate(key, sortedset.SCORE(q.all.GetCount()), tx)
-	// TODO : Need to consider both bignonce and nonce
-	q.getEntitiesSortedSet(*rip7560Tx.Sender).AddOrUpdate(key, sortedset.SCORE(int64(rip7560Tx.Nonce)), tx)
+// AddTx adds a transaction to the queue or replaces the one with the same sender, nonce key, and nonce. The
+// hash is computed once by the caller when the transaction is admitted since rebuilding it is expensive.
+func (q *rip7560TxQueues) AddTx(tx *transaction.TransactionArgs, hash common.Hash, meta *TxMetadata) {
+	key := string(getUniqueKey(tx))
+	if n := q.all.GetByKey(key); n != nil {
+		// Replacements may reference different entities, so clear the old entry from every index first.
+		q.RemoveTxs(n.Value.(*transaction.TransactionArgs))
+	}
+
+	q.seq++
+	q.all.AddOrUpdate(key, sortedset.SCORE(q.seq), tx)
+	q.byHash[hash] = key
+	q.hashes[key] = hash
+	q.meta[key] = meta
+	q.totalGas += tx.GetTotalGasLimit()
+	q.getLaneSortedSet(tx.GetSender(), tx.GetNonceKey()).
+		AddOrUpdate(key, sortedset.SCORE(tx.GetNonce()), tx)
 	if deployer := tx.GetDeployer(); deployer != common.HexToAddress("0x") {
 		fss := q.getEntitiesSortedSet(deployer)
-		fss.AddOrUpdate(key, sortedset.SCORE(fss.GetCount()), tx)
+		fss.AddOrUpdate(key, sortedset.SCORE(q.seq), tx)
 	}
 	if paymaster := tx.GetPaymaster(); paymaster != common.HexToAddress("0x") {
 		pss := q.getEntitiesSortedSet(paymaster)
-		pss.AddOrUpdate(key, sortedset.SCORE(pss.GetCount()), tx)
+		pss.AddOrUpdate(key, sortedset.SCORE(q.seq), tx)
 	}
+	q.refreshLaneHead(tx.GetSender(), tx.GetNonceKey())
 }
 
+// GetTxs returns all transactions associated with an entity. Transactions where the entity is the sender are
+// grouped by ascending nonce key and ordered by descending nonce within each key. These are followed by any
+// transactions where the entity is the deployer or paymaster.
 func (q *rip7560TxQueues) GetTxs(entity common.Address) []*transaction.TransactionArgs {
-	ess := q.getEntitiesSortedSet(entity)
-	nodes := ess.GetByRankRange(-1, -ess.GetCount(), false)
 	var batch []*transaction.TransactionArgs
-	for _, n := range nodes {
+	seen := make(map[string]bool)
+	for _, lk := range q.getSortedLaneKeys(entity) {
+		lss := q.senders[entity][lk]
+		for _, n := range lss.GetByRankRange(-1, -lss.GetCount(), false) {
+			seen[n.Key()] = true
+			batch = append(batch, n.Value.(*transaction.TransactionArgs))
+		}
+	}
+
+	if ess, ok := q.entities[entity]; ok {
+		for _, n := range ess.GetByRankRange(-1, -ess.GetCount(), false) {
+			if seen[n.Key()] {
+				continue
+			}
+			batch = append(batch, n.Value.(*transaction.TransactionArgs))
+		}
+	}
+
+	return batch
+}
+
+// GetTxsByNonceKey returns all transactions from a sender using the given nonce key in ascending nonce order.
+func (q *rip7560TxQueues) GetTxsByNonceKey(sender common.Address, nonceKey *big.Int) []*transaction.TransactionArgs {
+	batch := []*transaction.TransactionArgs{}
+	lanes, ok := q.senders[sender]
+	if !ok {
+		return batch
+	}
+	lss, ok := lanes[nonceKey.String()]
+	if !ok {
+		return batch
+	}
+	for _, n := range lss.GetByRankRange(1, -1, false) {
 		batch = append(batch, n.Value.(*transaction.TransactionArgs))
 	}
 
@@ -57,14 +151,202 @@ func (q *rip7560TxQueues) All() []*transaction.TransactionArgs {
 	return batch
 }
 
-func (q *rip7560TxQueues) RemoveTxs(txArgsList ...*transaction.TransactionArgs) {
+// Pending returns all executable transactions in the order they arrived.
+func (q *rip7560TxQueues) Pending() []*transaction.TransactionArgs {
+	nodes := q.all.GetByRankRange(1, -1, false)
+	batch := []*transaction.TransactionArgs{}
+	for _, n := range nodes {
+		if !q.queued[n.Key()] {
+			batch = append(batch, n.Value.(*transaction.TransactionArgs))
+		}
+	}
+
+	return batch
+}
+
+// Queued returns all transactions waiting on an earlier nonce in the order they arrived.
+func (q *rip7560TxQueues) Queued() []*transaction.TransactionArgs {
+	nodes := q.all.GetByRankRange(1, -1, false)
+	batch := []*transaction.TransactionArgs{}
+	for _, n := range nodes {
+		if q.queued[n.Key()] {
+			batch = append(batch, n.Value.(*transaction.TransactionArgs))
+		}
+	}
+
+	return batch
+}
+
+// IsQueued returns true if the transaction is waiting on an earlier nonce.
+func (q *rip7560TxQueues) IsQueued(tx *transaction.TransactionArgs) bool {
+	return q.queued[string(getUniqueKey(tx))]
+}
+
+// SetQueued moves a transaction in the queue between the pending and queued state.
+func (q *rip7560TxQueues) SetQueued(tx *transaction.TransactionArgs, queued bool) {
+	key := string(getUniqueKey(tx))
+	if queued {
+		q.queued[key] = true
+	} else {
+		delete(q.queued, key)
+	}
+	q.refreshLaneHead(tx.GetSender(), tx.GetNonceKey())
+}
 
+// Get returns the transaction in the queue with the same sender, nonce key, and nonce as the given one.
+func (q *rip7560TxQueues) Get(tx *transaction.TransactionArgs) *transaction.TransactionArgs {
+	if n := q.all.GetByKey(string(getUniqueKey(tx))); n != nil {
+		return n.Value.(*transaction.TransactionArgs)
+	}
+	return nil
+}
+
+// GetByHash returns the transaction in the queue with the given hash.
+func (q *rip7560TxQueues) GetByHash(hash common.Hash) *transaction.TransactionArgs {
+	key, ok := q.byHash[hash]
+	if !ok {
+		return nil
+	}
+	if n := q.all.GetByKey(key); n != nil {
+		return n.Value.(*transaction.TransactionArgs)
+	}
+	return nil
+}
+
+// GetMeta returns the metadata of the transaction in the queue with the same sender, nonce key, and nonce as
+// the given one.
+func (q *rip7560TxQueues) GetMeta(tx *transaction.TransactionArgs) *TxMetadata {
+	return q.meta[string(getUniqueKey(tx))]
+}
+
+// SetMeta replaces the metadata of a transaction in the queue.
+func (q *rip7560TxQueues) SetMeta(tx *transaction.TransactionArgs, meta *TxMetadata) {
+	key := string(getUniqueKey(tx))
+	if _, ok := q.meta[key]; ok {
+		q.meta[key] = meta
+	}
+}
+
+// Count returns the number of transactions in the queue.
+func (q *rip7560TxQueues) Count() int {
+	return q.all.GetCount()
+}
+
+// PendingCount returns the number of executable transactions in the queue.
+func (q *rip7560TxQueues) PendingCount() int {
+	return q.all.GetCount() - len(q.queued)
+}
+
+// Hash returns the hash of a transaction in the queue without rebuilding it.
+func (q *rip7560TxQueues) Hash(tx *transaction.TransactionArgs) (common.Hash, bool) {
+	hash, ok := q.hashes[string(getUniqueKey(tx))]
+	return hash, ok
+}
+
+// Current returns the transactions in txs that are in the queue with the same hash. Transactions that have
+// been removed or replaced by another one with the same Sender, NonceKey, and Nonce values are left out. The
+// hash is only rebuilt for transactions that are not the same instance as the one in the queue.
+func (q *rip7560TxQueues) Current(txs ...*transaction.TransactionArgs) []*transaction.TransactionArgs {
+	cur := []*transaction.TransactionArgs{}
+	for _, tx := range txs {
+		key := string(getUniqueKey(tx))
+		n := q.all.GetByKey(key)
+		if n == nil {
+			continue
+		}
+		if n.Value.(*transaction.TransactionArgs) == tx || q.hashes[key] == tx.ToTransaction().Hash() {
+			cur = append(cur, tx)
+		}
+	}
+
+	return cur
+}
+
+// TotalGas returns the sum of the total gas limits of all transactions in the queue.
+func (q *rip7560TxQueues) TotalGas() uint64 {
+	return q.totalGas
+}
+
+// LaneTails returns the transaction with the highest nonce for every sender and nonce key. These are the only
+// transactions that can be dropped without creating a nonce gap.
+func (q *rip7560TxQueues) LaneTails() []*transaction.TransactionArgs {
+	batch := []*transaction.TransactionArgs{}
+	for _, lanes := range q.senders {
+		for _, lss := range lanes {
+			if n := lss.PeekMax(); n != nil {
+				batch = append(batch, n.Value.(*transaction.TransactionArgs))
+			}
+		}
+	}
+
+	return batch
+}
+
+// GetPrevInLane returns the transaction in the same sender and nonce key sequence with the next lower nonce or
+// nil if tx is the lowest.
+func (q *rip7560TxQueues) GetPrevInLane(tx *transaction.TransactionArgs) *transaction.TransactionArgs {
+	lanes, ok := q.senders[tx.GetSender()]
+	if !ok {
+		return nil
+	}
+	lss, ok := lanes[tx.GetNonceKey().String()]
+	if !ok {
+		return nil
+	}
+	rank := lss.FindRank(string(getUniqueKey(tx)))
+	if rank <= 1 {
+		return nil
+	}
+	if n := lss.GetByRank(rank-1, false); n != nil {
+		return n.Value.(*transaction.TransactionArgs)
+	}
+	return nil
+}
+
+func (q *rip7560TxQueues) removeFromLane(txArgs *transaction.TransactionArgs, key string) {
+	lanes, ok := q.senders[txArgs.GetSender()]
+	if !ok {
+		return
+	}
+	lk := txArgs.GetNonceKey().String()
+	if lss, ok := lanes[lk]; ok {
+		lss.Remove(key)
+		if lss.GetCount() == 0 {
+			delete(lanes, lk)
+		}
+	}
+	if len(lanes) == 0 {
+		delete(q.senders, txArgs.GetSender())
+	}
+}
+
+func (q *rip7560TxQueues) removeFromEntity(entity common.Address, key string) {
+	if ess, ok := q.entities[entity]; ok {
+		ess.Remove(key)
+		if ess.GetCount() == 0 {
+			delete(q.entities, entity)
+		}
+	}
+}
+
+func (q *rip7560TxQueues) RemoveTxs(txArgsList ...*transaction.TransactionArgs) {
 	for _, txArgs := range txArgsList {
-		key := string(getUniqueKey(txArgs.GetSender(), txArgs.Nonce, txArgs.NonceKey))
-		q.all.Remove(key)
-		q.getEntitiesSortedSet(txArgs.GetSender()).Remove(key)
-		q.getEntitiesSortedSet(txArgs.GetDeployer()).Remove(key)
-		q.getEntitiesSortedSet(txArgs.GetPaymaster()).Remove(key)
+		key := string(getUniqueKey(txArgs))
+		n := q.all.Remove(key)
+		if n == nil {
+			continue
+		}
+
+		stored := n.Value.(*transaction.TransactionArgs)
+		delete(q.byHash, q.hashes[key])
+		delete(q.hashes, key)
+		delete(q.queued, key)
+		delete(q.meta, key)
+		q.totalGas -= stored.GetTotalGasLimit()
+		q.removeFromLane(stored, key)
+		q.refreshLaneHead(stored.GetSender(), stored.GetNonceKey())
+		q.removeFromEntity(stored.GetDeployer(), key)
+		q.removeFromEntity(stored.GetPaymaster(), key)
 	}
 }
 
@@ -72,5 +354,11 @@ func newRip7560TxQueue() *rip7560TxQueues {
 	return &rip7560TxQueues{
 		all:      sortedset.New(),
 		entities: make(map[common.Address]*sortedset.SortedSet),
+		senders:  make(map[common.Address]nonceLanes),
+		byHash:   make(map[common.Hash]string),
+		hashes:   make(map[string]common.Hash),
+		queued:   make(map[string]bool),
+		meta:     make(map[string]*TxMetadata),
+		heads:    sortedset.New(),
 	}
 }
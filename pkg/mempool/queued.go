@@ -0,0 +1,127 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// DropReasonNonceTooLow is recorded for transactions with a nonce that has already been used on-chain.
+const DropReasonNonceTooLow = "nonce too low"
+
+// ErrNonceTooLow is returned when a transaction uses a nonce that has already been used on-chain.
+var ErrNonceTooLow = errors.New("nonce too low")
+
+// GetNonceFunc returns the next expected on-chain nonce for a sender and RIP-7712 nonce key.
+type GetNonceFunc = func(sender common.Address, key *big.Int) (uint64, error)
+
+// SetGetNonceFunc defines the function used to retrieve the on-chain nonce when deciding if a transaction is
+// executable. If not set, the lowest nonce of each (sender, nonce key) sequence in the mempool is assumed to be
+// executable and gaps are detected against the sequence itself.
+func (m *Mempool) SetGetNonceFunc(fn GetNonceFunc) {
+	m.getNonce = fn
+}
+
+// getLaneStart returns the lowest nonce in the mempool for a sender and nonce key. This is used as the next
+// expected nonce when no GetNonceFunc is set. The caller must hold mu.
+func (m *Mempool) getLaneStart(sender common.Address, key *big.Int) uint64 {
+	txs := m.queue.GetTxsByNonceKey(sender, key)
+	if len(txs) == 0 {
+		return 0
+	}
+	return txs[0].GetNonce()
+}
+
+// updateLane sets every transaction in a (sender, nonce key) sequence as pending if it continues from next
+// without a gap and as queued otherwise. Transactions with a nonce below next are returned as stale. The caller
+// must hold mu.
+func (m *Mempool) updateLane(sender common.Address, key *big.Int, next uint64) []*transaction.TransactionArgs {
+	stale := []*transaction.TransactionArgs{}
+	for _, tx := range m.queue.GetTxsByNonceKey(sender, key) {
+		switch n := tx.GetNonce(); {
+		case n < next:
+			stale = append(stale, tx)
+		case n == next:
+			m.queue.SetQueued(tx, false)
+			next++
+		default:
+			m.queue.SetQueued(tx, true)
+		}
+	}
+
+	return stale
+}
+
+// demoteAfter moves all pending transactions in the same sequence as tx with a higher nonce to the queued
+// sub-pool. This is used when tx is removed without being bundled and therefore leaves a nonce gap. The caller
+// must hold mu.
+func (m *Mempool) demoteAfter(tx *transaction.TransactionArgs) {
+	for _, t := range m.queue.GetTxsByNonceKey(tx.GetSender(), tx.GetNonceKey()) {
+		if t.GetNonce() > tx.GetNonce() {
+			m.queue.SetQueued(t, true)
+		}
+	}
+}
+
+// PromoteQueued checks the on-chain nonce of every sequence with queued transactions and moves any that are
+// now executable to the pending sub-pool. Transactions with a nonce that has already been used are dropped. If
+// no GetNonceFunc is set, queued transactions are only promoted once they continue from the lowest nonce of
+// their sequence without a gap.
+func (m *Mempool) PromoteQueued() error {
+	type lane struct {
+		sender common.Address
+		key    *big.Int
+		next   uint64
+	}
+	lanes := []*lane{}
+	seen := make(map[string]bool)
+	m.mu.RLock()
+	for _, tx := range m.queue.Queued() {
+		lk := tx.GetSender().String() + tx.GetNonceKey().String()
+		if !seen[lk] {
+			seen[lk] = true
+			lanes = append(lanes, &lane{sender: tx.GetSender(), key: tx.GetNonceKey()})
+		}
+	}
+	m.mu.RUnlock()
+	if len(lanes) == 0 {
+		return nil
+	}
+
+	if m.getNonce != nil {
+		for _, l := range lanes {
+			next, err := m.getNonce(l.sender, l.key)
+			if err != nil {
+				return err
+			}
+			l.next = next
+		}
+	}
+
+	senders := []common.Address{}
+	for _, l := range lanes {
+		senders = append(senders, l.sender)
+	}
+	defer m.lockSenders(senders...)()
+	m.mu.Lock()
+	stale := []*transaction.TransactionArgs{}
+	for _, l := range lanes {
+		if m.getNonce == nil {
+			l.next = m.getLaneStart(l.sender, l.key)
+		}
+		stale = append(stale, m.updateLane(l.sender, l.key, l.next)...)
+	}
+	m.mu.Unlock()
+
+	return m.dropTxs(DropReasonNonceTooLow, false, stale...)
+}
+
+// DumpQueued will return a list of AA Transactions from the queued sub-pool in the order it arrived. These
+// are waiting on an earlier nonce and are not executable.
+func (m *Mempool) DumpQueued() ([]*transaction.TransactionArgs, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.queue.Queued(), nil
+}
@@ -0,0 +1,159 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func mockNonce(next *uint64) GetNonceFunc {
+	return func(sender common.Address, key *big.Int) (uint64, error) {
+		return *next, nil
+	}
+}
+
+func withNonce(nonce uint64) *transaction.TransactionArgs {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Nonce = (*hexutil.Uint64)(&nonce)
+	return tx
+}
+
+// TestFutureNonceIsQueued verifies that a RIP-7560 transaction with a nonce gap is held in the queued
+// sub-pool and promoted once the missing nonce arrives.
+func TestFutureNonceIsQueued(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	tx0 := withNonce(0)
+	tx2 := withNonce(2)
+	tx1 := withNonce(1)
+
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 0 {
+		t.Fatalf("got pending length %d, want 0", len(dump))
+	}
+	if queued, _ := mem.DumpQueued(); len(queued) != 1 {
+		t.Fatalf("got queued length %d, want 1", len(queued))
+	}
+
+	if err := mem.AddTx(tx0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 || !testutils.IsTxsEqual(dump[0], tx0) {
+		t.Fatalf("got pending %v, want tx with nonce 0", dump)
+	}
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 3 {
+		t.Fatalf("got pending length %d, want 3", len(dump))
+	}
+	if queued, _ := mem.DumpQueued(); len(queued) != 0 {
+		t.Fatalf("got queued length %d, want 0", len(queued))
+	}
+
+	if err := mem.DropTxs("bad tx", tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if queued, _ := mem.DumpQueued(); len(queued) != 1 || !testutils.IsTxsEqual(queued[0], tx2) {
+		t.Fatalf("got queued %v, want tx with nonce 2", queued)
+	}
+}
+
+// TestGapWithoutNonceFunc verifies that a RIP-7560 transaction is queued behind a gap in its own sequence when
+// there is no on-chain nonce source and promoted once the gap is filled.
+func TestGapWithoutNonceFunc(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+
+	tx0 := withNonce(0)
+	tx2 := withNonce(2)
+	tx1 := withNonce(1)
+	for _, tx := range []*transaction.TransactionArgs{tx0, tx2} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if err := mem.PromoteQueued(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if queued, _ := mem.DumpQueued(); len(queued) != 1 || !testutils.IsTxsEqual(queued[0], tx2) {
+		t.Fatalf("got queued %v, want tx with nonce 2", queued)
+	}
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 3 {
+		t.Fatalf("got pending length %d, want 3", len(dump))
+	}
+}
+
+// TestPromoteQueuedAfterMined verifies that queued RIP-7560 transactions are promoted once the earlier nonce
+// is mined and that stale transactions are dropped.
+func TestPromoteQueuedAfterMined(t *testing.T) {
+	mem, _ := NewWithStore(NewMemoryStore())
+	next := uint64(0)
+	mem.SetGetNonceFunc(mockNonce(&next))
+
+	tx0 := withNonce(0)
+	tx1 := withNonce(1)
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.BundleTxs(tx0); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	tx3 := withNonce(3)
+	if err := mem.AddTx(tx3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	next = 3
+	if err := mem.PromoteQueued(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 || !testutils.IsTxsEqual(dump[0], tx3) {
+		t.Fatalf("got pending %v, want tx with nonce 3", dump)
+	}
+	if reason, _ := mem.GetDropReason(tx1.ToTransaction().Hash()); reason != DropReasonNonceTooLow {
+		t.Fatalf("got reason %q, want %q", reason, DropReasonNonceTooLow)
+	}
+
+	if err := mem.AddTx(withNonce(2)); !errors.Is(err, ErrNonceTooLow) {
+		t.Fatalf("got %v, want ErrNonceTooLow", err)
+	}
+}
+
+// TestLoadedTxsAreQueued verifies that RIP-7560 transactions loaded from disk are held until their nonce is
+// checked again.
+func TestLoadedTxsAreQueued(t *testing.T) {
+	store := NewMemoryStore()
+	mem, _ := NewWithStore(store)
+	if err := mem.AddTx(withNonce(0)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	mem, _ = NewWithStore(store)
+	if dump, _ := mem.Dump(); len(dump) != 0 {
+		t.Fatalf("got pending length %d, want 0", len(dump))
+	}
+	if err := mem.PromoteQueued(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 {
+		t.Fatalf("got pending length %d, want 1", len(dump))
+	}
+}
@@ -0,0 +1,55 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// ErrReplacementTxUnderpriced is returned when a transaction replaces a pending one with the same Sender,
+// NonceKey, and Nonce values without increasing its fees by the replacement price bump.
+var ErrReplacementTxUnderpriced = errors.New("mempool: replacement tx underpriced")
+
+// SetReplacementPriceBump sets the minimum percentage that a transaction must increase both maxFeePerGas and
+// maxPriorityFeePerGas by in order to replace a pending one. If not set, a replacement must not lower either
+// fee.
+func (m *Mempool) SetReplacementPriceBump(pct int64) {
+	m.priceBump = pct
+}
+
+// checkReplacement returns an error if tx does not pay enough to replace prev. The check is done while holding
+// mu so that concurrent replacements of the same transaction are compared against each other.
+func (m *Mempool) checkReplacement(prev *transaction.TransactionArgs, tx *transaction.TransactionArgs) error {
+	return CheckReplacement(prev, tx, m.priceBump)
+}
+
+// CheckReplacement returns ErrReplacementTxUnderpriced if tx does not increase both maxFeePerGas and
+// maxPriorityFeePerGas of prev by at least priceBump percent. Missing fee fields are treated as zero.
+func CheckReplacement(prev *transaction.TransactionArgs, tx *transaction.TransactionArgs, priceBump int64) error {
+	bump := func(fee *hexutil.Big) *big.Int {
+		v := new(big.Int).Mul(feeOrZero(fee), big.NewInt(100+priceBump))
+		return v.Div(v, big.NewInt(100))
+	}
+	minMf := bump(prev.MaxFeePerGas)
+	minMpf := bump(prev.MaxPriorityFeePerGas)
+	if feeOrZero(tx.MaxFeePerGas).Cmp(minMf) < 0 || feeOrZero(tx.MaxPriorityFeePerGas).Cmp(minMpf) < 0 {
+		return fmt.Errorf(
+			"%w: must increase maxFeePerGas to >= %s and maxPriorityFeePerGas to >= %s (%d%% bump)",
+			ErrReplacementTxUnderpriced,
+			minMf,
+			minMpf,
+			priceBump,
+		)
+	}
+	return nil
+}
+
+func feeOrZero(fee *hexutil.Big) *big.Int {
+	if fee == nil {
+		return big.NewInt(0)
+	}
+	return fee.ToInt()
+}
@@ -0,0 +1,70 @@
+package mempool
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TxStatus is the state of a transaction that is or was known to the mempool.
+type TxStatus string
+
+const (
+	// TxStatusPending is set for transactions that are waiting in the mempool.
+	TxStatusPending TxStatus = "pending"
+
+	// TxStatusQueued is set for transactions that are waiting in the mempool on an earlier nonce.
+	TxStatusQueued TxStatus = "queued"
+
+	// TxStatusBundled is set for transactions that have been handed to the sequencer in a bundle.
+	TxStatusBundled TxStatus = "bundled"
+
+	// TxStatusDropped is set for transactions that were removed from the mempool without being bundled.
+	TxStatusDropped TxStatus = "dropped"
+)
+
+const removedTxTTL = 24 * time.Hour
+
+// TxLookup is the result of looking up a transaction in the mempool by hash.
+type TxLookup struct {
+	Tx     *transaction.TransactionArgs `json:"tx"`
+	Status TxStatus                     `json:"status"`
+	Reason string                       `json:"reason,omitempty"`
+}
+
+func newTxLookups(status TxStatus, reason string, txs ...*transaction.TransactionArgs) []*TxLookup {
+	lookups := []*TxLookup{}
+	for _, tx := range txs {
+		lookups = append(lookups, &TxLookup{Tx: tx, Status: status, Reason: reason})
+	}
+	return lookups
+}
+
+// LookupTx returns a transaction by hash along with its status. Transactions removed from the mempool are
+// only remembered for a limited time. Nil is returned if the hash is unknown.
+func (m *Mempool) LookupTx(hash common.Hash) (*TxLookup, error) {
+	m.mu.RLock()
+	tx := m.queue.GetByHash(hash)
+	queued := tx != nil && m.queue.IsQueued(tx)
+	m.mu.RUnlock()
+	if tx != nil {
+		if queued {
+			return &TxLookup{Tx: tx, Status: TxStatusQueued}, nil
+		}
+		return &TxLookup{Tx: tx, Status: TxStatusPending}, nil
+	}
+
+	return m.store.GetRemoved(hash)
+}
+
+// GetDropReason returns the reason a transaction with the given hash was dropped from the mempool. An empty
+// string is returned if no reason was recorded.
+func (m *Mempool) GetDropReason(hash common.Hash) (string, error) {
+	lookup, err := m.store.GetRemoved(hash)
+	if err != nil || lookup == nil || lookup.Status != TxStatusDropped {
+		return "", err
+	}
+
+	return lookup.Reason, nil
+}
@@ -0,0 +1,65 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestLookupTx verifies that a RIP-7560 transaction can be found by hash while pending and after it has been
+// removed from the mempool.
+func TestLookupTx(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	mem, _ := New(db)
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.Sender = &common.Address{0x01}
+	h1 := tx1.ToTransaction().Hash()
+	h2 := tx2.ToTransaction().Hash()
+
+	if lookup, err := mem.LookupTx(h1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if lookup != nil {
+		t.Fatalf("got %v, want nil", lookup)
+	}
+
+	if err := mem.AddTx(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTx(tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if lookup, err := mem.LookupTx(h1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if lookup.Status != TxStatusPending {
+		t.Fatalf("got status %s, want %s", lookup.Status, TxStatusPending)
+	} else if !testutils.IsTxsEqual(lookup.Tx, tx1) {
+		t.Fatalf("txs not equal: %s", testutils.GetTxsDiff(tx1, lookup.Tx))
+	}
+
+	if err := mem.BundleTxs(tx1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.DropTxs("bad tx", tx2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if tx, _ := mem.GetTxByHash(h1); tx != nil {
+		t.Fatal("got pending tx, want nil")
+	}
+
+	if lookup, err := mem.LookupTx(h1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if lookup.Status != TxStatusBundled {
+		t.Fatalf("got status %s, want %s", lookup.Status, TxStatusBundled)
+	} else if !testutils.IsTxsEqual(lookup.Tx, tx1) {
+		t.Fatalf("txs not equal: %s", testutils.GetTxsDiff(tx1, lookup.Tx))
+	}
+
+	if lookup, err := mem.LookupTx(h2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if lookup.Status != TxStatusDropped || lookup.Reason != "bad tx" {
+		t.Fatalf("got status %s with reason %q, want %s", lookup.Status, lookup.Reason, TxStatusDropped)
+	}
+}
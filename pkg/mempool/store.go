@@ -0,0 +1,50 @@
+package mempool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TxEntry is a transaction along with its metadata as saved in a Store.
+type TxEntry struct {
+	Tx   *transaction.TransactionArgs `json:"tx"`
+	Meta *TxMetadata                  `json:"meta"`
+}
+
+// StoreUpdate is a set of changes that a Store must apply atomically.
+type StoreUpdate struct {
+	// PutTxs adds or replaces transactions and their metadata by Sender, NonceKey, and Nonce values.
+	PutTxs []*TxEntry
+
+	// DeleteTxs removes transactions by Sender, NonceKey, and Nonce values.
+	DeleteTxs []*transaction.TransactionArgs
+
+	// PutRemoved records the status of transactions no longer in the mempool. These records only need to
+	// be kept for a limited time.
+	PutRemoved []*TxLookup
+
+	// PutInFlight adds or replaces in-flight transactions by hash.
+	PutInFlight []*InFlightTx
+
+	// DeleteInFlight removes in-flight transactions by hash.
+	DeleteInFlight []common.Hash
+}
+
+// Store is the persistence layer of a Mempool. The in-memory indexes of the Mempool are rebuilt from the Store
+// on startup.
+type Store interface {
+	// LoadTxs returns all transactions and their metadata in the Store.
+	LoadTxs() ([]*TxEntry, error)
+
+	// LoadInFlight returns all in-flight transactions in the Store.
+	LoadInFlight() ([]*InFlightTx, error)
+
+	// GetRemoved returns the status of a transaction no longer in the mempool or nil if it is unknown.
+	GetRemoved(hash common.Hash) (*TxLookup, error)
+
+	// Update applies all changes in a StoreUpdate atomically.
+	Update(u *StoreUpdate) error
+
+	// Clear removes all data from the Store.
+	Clear() error
+}
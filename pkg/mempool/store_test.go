@@ -0,0 +1,160 @@
+package mempool
+
+import (
+	"errors"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestStores verifies that every Store implementation persists transactions, removal records, and in-flight
+// transactions in the same way.
+func TestStores(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{"badger", NewBadgerStore(db)},
+		{"memory", NewMemoryStore()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tx1 := testutils.MockValidInitRip7560Tx()
+			tx2 := testutils.MockValidInitRip7560Tx()
+			tx2.Sender = &common.Address{0x01}
+			h1 := tx1.ToTransaction().Hash()
+
+			err := tc.store.Update(&StoreUpdate{PutTxs: []*TxEntry{{Tx: tx1}, {Tx: tx2}}})
+			if err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			err = tc.store.Update(&StoreUpdate{
+				DeleteTxs:   []*transaction.TransactionArgs{tx1},
+				PutRemoved:  newTxLookups(TxStatusBundled, "", tx1),
+				PutInFlight: []*InFlightTx{{Tx: tx1, Hash: h1}},
+			})
+			if err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+
+			if txs, err := tc.store.LoadTxs(); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if len(txs) != 1 || !testutils.IsTxsEqual(txs[0].Tx, tx2) {
+				t.Fatalf("got %v, want only second tx", txs)
+			}
+			if lookup, err := tc.store.GetRemoved(h1); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if lookup == nil || lookup.Status != TxStatusBundled {
+				t.Fatalf("got %v, want bundled status", lookup)
+			}
+			if itxs, err := tc.store.LoadInFlight(); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if len(itxs) != 1 || itxs[0].Hash != h1 {
+				t.Fatalf("got %v, want in-flight tx", itxs)
+			}
+
+			if err := tc.store.Update(&StoreUpdate{DeleteInFlight: []common.Hash{h1}}); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if itxs, _ := tc.store.LoadInFlight(); len(itxs) != 0 {
+				t.Fatalf("got in-flight length %d, want 0", len(itxs))
+			}
+
+			if err := tc.store.Clear(); err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if txs, _ := tc.store.LoadTxs(); len(txs) != 0 {
+				t.Fatalf("got length %d, want 0", len(txs))
+			}
+		})
+	}
+}
+
+// TestBadgerStoreClearKeepsOtherData verifies that clearing the mempool does not drop data owned by other
+// modules in the same badger DB.
+func TestBadgerStoreClearKeepsOtherData(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+
+	otherKey := []byte(dbutils.JoinValues("history", "bundle", "0x01"))
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set(otherKey, []byte{0x01})
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	s := NewBadgerStore(db)
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := s.Update(&StoreUpdate{PutTxs: []*TxEntry{{Tx: tx}}}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if txs, _ := s.LoadTxs(); len(txs) != 0 {
+		t.Fatalf("got length %d, want 0", len(txs))
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(otherKey)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestBadgerStoreMigratesLegacyTxs verifies that RLP encoded transactions saved under the unversioned key
+// prefix are rewritten to the current encoding and that entries which cannot be decoded are cleared.
+func TestBadgerStoreMigratesLegacyTxs(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.To = &common.Address{0x01}
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	legacyKey := []byte(dbutils.JoinValues(
+		"mempool",
+		tx.GetSender().String(),
+		hexutil.EncodeUint64(tx.GetNonce()),
+		hexutil.EncodeBig(tx.GetNonceKey()),
+	))
+	err = db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(legacyKey, data); err != nil {
+			return err
+		}
+		return txn.Set([]byte(dbutils.JoinValues("mempool", "0xbad")), []byte{0xff})
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	s := NewBadgerStore(db)
+	for i := 0; i < 2; i++ {
+		if txs, err := s.LoadTxs(); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		} else if len(txs) != 1 || string(getUniqueKey(txs[0].Tx)) != string(getUniqueKey(tx)) || txs[0].Meta == nil {
+			t.Fatalf("got %v, want migrated tx", txs)
+		}
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(legacyKey)
+		return err
+	})
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Fatalf("got %v, want ErrKeyNotFound", err)
+	}
+}
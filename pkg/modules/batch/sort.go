@@ -3,17 +3,47 @@ package batch
 import (
 	"sort"
 
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
-// SortByNonce returns a BatchHandlerFunc that ensures txs with same sender is ordered by ascending nonce
-// regardless of gas price.
+func getLaneKey(tx *transaction.TransactionArgs) string {
+	return dbutils.JoinValues(tx.GetSender().String(), tx.GetNonceKey().String())
+}
+
+// SortByNonce returns a BatchHandlerFunc that ensures txs with same sender and nonce key are ordered by
+// ascending nonce regardless of gas price. Each (sender, nonce key) pair is an independent sequence, so the
+// positions taken up by a sequence in the batch are kept and only the txs within it are reordered.
 func SortByNonce() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		sort.SliceStable(ctx.Batch, func(i, j int) bool {
-			return ctx.Batch[i].Sender == ctx.Batch[j].Sender &&
-				*ctx.Batch[i].Nonce < *ctx.Batch[j].Nonce
-		})
+		idxByLane := make(map[string][]int)
+		lanes := []string{}
+		for i, tx := range ctx.Batch {
+			lk := getLaneKey(tx)
+			if _, ok := idxByLane[lk]; !ok {
+				lanes = append(lanes, lk)
+			}
+			idxByLane[lk] = append(idxByLane[lk], i)
+		}
+
+		for _, lk := range lanes {
+			idx := idxByLane[lk]
+			if len(idx) < 2 {
+				continue
+			}
+
+			txs := make([]*transaction.TransactionArgs, 0, len(idx))
+			for _, i := range idx {
+				txs = append(txs, ctx.Batch[i])
+			}
+			sort.SliceStable(txs, func(i, j int) bool {
+				return txs[i].GetNonce() < txs[j].GetNonce()
+			})
+			for n, i := range idx {
+				ctx.Batch[i] = txs[n]
+			}
+		}
 
 		return nil
 	}
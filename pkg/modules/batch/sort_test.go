@@ -0,0 +1,52 @@
+package batch_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/batch"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestSortByNonce verifies that SortByNonce orders Rip-7560 transactions by ascending nonce within each
+// sender and nonce key while leaving the positions of other transactions unchanged.
+func TestSortByNonce(t *testing.T) {
+	nonce2 := uint64(2)
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx1.Nonce = (*hexutil.Uint64)(&nonce2)
+
+	tx2 := testutils.MockValidInitRip7560Tx()
+	*tx2.Sender = testutils.ValidAddress2
+
+	tx3 := testutils.MockValidInitRip7560Tx()
+	tx3.NonceKey = (*hexutil.Big)(big.NewInt(1))
+	tx3.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+
+	tx4 := testutils.MockValidInitRip7560Tx()
+
+	tx5 := testutils.MockValidInitRip7560Tx()
+	tx5.NonceKey = (*hexutil.Big)(big.NewInt(1))
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*transaction.TransactionArgs{tx1, tx2, tx3, tx4, tx5},
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	expected := []*transaction.TransactionArgs{tx4, tx2, tx5, tx1, tx3}
+	if err := batch.SortByNonce()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != len(expected) {
+		t.Fatalf("got length %d, want %d", len(ctx.Batch), len(expected))
+	}
+	for i, tx := range ctx.Batch {
+		if !testutils.IsTxsEqual(tx, expected[i]) {
+			t.Fatalf("incorrect order: tx at index %d out of place", i)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package bundlesim implements a bundler module that simulates a candidate batch in order before it is handed
+// to the sequencer. Transactions that pass validation on their own can still conflict once bundled, for
+// example by sharing a paymaster deposit, by reading storage that an earlier transaction writes, or by relying
+// on state that the execution of an earlier transaction changes. Each transaction is run on top of the state
+// changed by the execution of every transaction accepted before it in the batch, so all of these are caught.
+package bundlesim
+
+import (
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/state"
+)
+
+const (
+	// DropReasonPrefix is prepended to the simulation error when recording why a transaction was dropped.
+	DropReasonPrefix = "bundle simulation failed"
+
+	// DeferReasonPrefix is prepended to the reason a transaction was held back for a later batch.
+	DeferReasonPrefix = "bundle conflict"
+
+	// ConflictEntitiesKey is the key in the batch context data holding the entities at fault for removed or
+	// deferred transactions.
+	ConflictEntitiesKey = "bundlesim_conflict_entities"
+)
+
+// GetBalanceFunc returns the latest balance of an address.
+type GetBalanceFunc = func(acc common.Address) (*big.Int, error)
+
+// ExecuteFunc runs the validation and execution of a transaction on top of the latest state modified by the
+// given OverrideSet and returns the accounts it changed as an OverrideSet. A non-nil error means the
+// transaction failed validation, unless it is a transport error which aborts the batch.
+type ExecuteFunc = func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error)
+
+// TraceFunc traces validation for a transaction against the latest state. Rule violations and reverts are
+// returned as a simulation.EntityError and are used to find the entity at fault for a dropped transaction.
+type TraceFunc = func(tx *transaction.TransactionArgs) error
+
+// Simulator runs the transactions in a batch in order on chained state.
+type Simulator struct {
+	gb      GetBalanceFunc
+	execute ExecuteFunc
+	trace   TraceFunc
+	rep     *entities.Reputation
+}
+
+// New returns a Simulator. The trace function is optional and dropped transactions that are not attributed to
+// an entity by the node are charged to their payer or sender if it is nil.
+func New(gb GetBalanceFunc, execute ExecuteFunc, trace TraceFunc) *Simulator {
+	return &Simulator{gb: gb, execute: execute, trace: trace}
+}
+
+// UseReputation defines the Reputation instance used to penalize entities responsible for transactions that
+// are dropped by the bundle simulation.
+func (s *Simulator) UseReputation(rep *entities.Reputation) {
+	s.rep = rep
+}
+
+type failure struct {
+	index  int
+	entity common.Address
+	reason string
+	drop   bool
+}
+
+// bundleState accumulates the effects of the transactions accepted so far in a batch.
+type bundleState struct {
+	overrides state.OverrideSet
+	changedBy map[common.Address]common.Address
+	balances  map[common.Address]*big.Int
+	spent     map[common.Address]*big.Int
+	failed    map[string]bool
+}
+
+func newBundleState() *bundleState {
+	return &bundleState{
+		overrides: state.OverrideSet{},
+		changedBy: make(map[common.Address]common.Address),
+		balances:  make(map[common.Address]*big.Int),
+		spent:     make(map[common.Address]*big.Int),
+		failed:    make(map[string]bool),
+	}
+}
+
+func getLaneKey(tx *transaction.TransactionArgs) string {
+	return tx.GetSender().Hex() + tx.GetNonceKey().String()
+}
+
+func getPayer(tx *transaction.TransactionArgs) common.Address {
+	if pm := tx.GetPaymaster(); pm != (common.Address{}) {
+		return pm
+	}
+	return tx.GetSender()
+}
+
+// getConflict returns the first entity of tx whose state was changed by an earlier transaction from a different
+// sender. The sender is returned if the conflict is on another contract.
+func (b *bundleState) getConflict(tx *transaction.TransactionArgs) (common.Address, string) {
+	for _, entity := range []common.Address{tx.GetPaymaster(), tx.GetDeployer(), tx.GetSender()} {
+		if entity == (common.Address{}) {
+			continue
+		}
+		if by, ok := b.changedBy[entity]; ok && by != tx.GetSender() {
+			return entity, fmt.Sprintf("state of %s changed by %s", entity.Hex(), by.Hex())
+		}
+	}
+	return tx.GetSender(), "state changed by an earlier transaction"
+}
+
+func (b *bundleState) apply(sender common.Address, changes state.OverrideSet) {
+	b.overrides = state.Merge(b.overrides, changes)
+	for addr := range changes {
+		b.changedBy[addr] = sender
+	}
+}
+
+// getResponsibleEntity returns the entity at fault for a transaction that fails on its own. The error from the
+// node is used if it is attributed to an entity. Otherwise the failure is attributed from the validation trace,
+// and then to the payer if it cannot cover the maximum cost of the transaction.
+func (s *Simulator) getResponsibleEntity(
+	b *bundleState,
+	tx *transaction.TransactionArgs,
+	err error,
+) (common.Address, error) {
+	var ee *simulation.EntityError
+	if stderrors.As(err, &ee) {
+		return simulation.GetEntityAddress(tx, ee.Entity), nil
+	}
+	if s.trace != nil {
+		if terr := s.trace(tx); errors.IsTransportError(terr) {
+			return common.Address{}, terr
+		} else if stderrors.As(terr, &ee) {
+			return simulation.GetEntityAddress(tx, ee.Entity), nil
+		}
+	}
+	if bal, ok := b.balances[getPayer(tx)]; ok && bal.Cmp(tx.GetMaxCost()) < 0 {
+		return getPayer(tx), nil
+	}
+	return tx.GetSender(), nil
+}
+
+func (s *Simulator) check(b *bundleState, index int, tx *transaction.TransactionArgs) (*failure, error) {
+	if b.failed[getLaneKey(tx)] {
+		return &failure{index, tx.GetSender(), "earlier nonce was removed from batch", false}, nil
+	}
+
+	payer := getPayer(tx)
+	bal, ok := b.balances[payer]
+	if !ok {
+		var err error
+		if bal, err = s.gb(payer); err != nil {
+			return nil, err
+		}
+		b.balances[payer] = bal
+	}
+	spent, ok := b.spent[payer]
+	if !ok {
+		spent = big.NewInt(0)
+	}
+	cost := tx.GetMaxCost()
+	if spent.Sign() > 0 && new(big.Int).Add(spent, cost).Cmp(bal) > 0 {
+		return &failure{index, payer, fmt.Sprintf("insufficient balance of %s for batch", payer.Hex()), false}, nil
+	}
+
+	changes, err := s.execute(tx, b.overrides)
+	if errors.IsTransportError(err) {
+		return nil, err
+	} else if err != nil {
+		// A transaction that only fails on top of the earlier ones is deferred to a later batch rather than
+		// dropped.
+		if len(b.overrides) > 0 {
+			_, aerr := s.execute(tx, nil)
+			if errors.IsTransportError(aerr) {
+				return nil, aerr
+			} else if aerr == nil {
+				entity, reason := b.getConflict(tx)
+				return &failure{index, entity, fmt.Sprintf("%s: %s", reason, err), false}, nil
+			}
+			err = aerr
+		}
+
+		entity, terr := s.getResponsibleEntity(b, tx, err)
+		if terr != nil {
+			return nil, terr
+		}
+		return &failure{index, entity, err.Error(), true}, nil
+	}
+
+	b.spent[payer] = spent.Add(spent, cost)
+	b.apply(tx.GetSender(), changes)
+	return nil, nil
+}
+
+// SimulateBatch returns a BatchHandlerFunc that runs every transaction in the batch in order on top of the state
+// changed by the execution of the ones before it. The maximum cost of the transactions sharing a payer must
+// also fit within its latest balance. Transactions that fail on their own are dropped from the mempool and the
+// responsible entity is penalized. Transactions that only fail because of an earlier transaction in the batch
+// are deferred to a later batch, as are later nonces of a sequence that lost a transaction to an earlier
+// module. The entities at fault are recorded in the context data. If the node cannot be reached, an error is
+// returned and nothing is dropped.
+func (s *Simulator) SimulateBatch() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		b := newBundleState()
+		for _, item := range ctx.PendingRemoval {
+			b.failed[getLaneKey(item.Tx)] = true
+		}
+		for _, item := range ctx.Deferred {
+			b.failed[getLaneKey(item.Tx)] = true
+		}
+		fails := []*failure{}
+		for i, tx := range ctx.Batch {
+			f, err := s.check(b, i, tx)
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				b.failed[getLaneKey(tx)] = true
+				fails = append(fails, f)
+			}
+		}
+		if len(fails) == 0 {
+			return nil
+		}
+
+		sort.SliceStable(fails, func(i, j int) bool {
+			return fails[i].index > fails[j].index
+		})
+		ents := []string{}
+		for _, f := range fails {
+			ents = append(ents, f.entity.Hex())
+			if !f.drop {
+				ctx.DeferTxIndex(f.index, fmt.Sprintf("%s: %s", DeferReasonPrefix, f.reason))
+				continue
+			}
+
+			ctx.MarkTxIndexForRemoval(f.index, fmt.Sprintf("%s: %s", DropReasonPrefix, f.reason))
+			if s.rep != nil {
+				if err := s.rep.Penalize(f.entity); err != nil {
+					return err
+				}
+			}
+		}
+		ctx.Data[ConflictEntitiesKey] = ents
+		return nil
+	}
+}
@@ -0,0 +1,234 @@
+package bundlesim
+
+import (
+	stderrors "errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/state"
+)
+
+func mockTx(sender common.Address, nonce uint64) *transaction.TransactionArgs {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Sender = &sender
+	tx.Nonce = (*hexutil.Uint64)(&nonce)
+	return tx
+}
+
+func executeOk(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+	return state.OverrideSet{}, nil
+}
+
+func newCtx(txs ...*transaction.TransactionArgs) *modules.BatchHandlerCtx {
+	return modules.NewBatchHandlerContext(txs, big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1))
+}
+
+// TestSimulateBatchSharedPaymaster verifies that a RIP-7560 transaction is deferred if an earlier transaction
+// in the batch spends the paymaster balance it relies on.
+func TestSimulateBatchSharedPaymaster(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0)
+	tx2 := mockTx(common.Address{0x02}, 0)
+	bal := new(big.Int).Add(tx1.GetMaxCost(), big.NewInt(1))
+	gb := func(acc common.Address) (*big.Int, error) {
+		return bal, nil
+	}
+
+	ctx := newCtx(tx1, tx2)
+	if err := New(gb, executeOk, nil).SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 1 || !testutils.IsTxsEqual(ctx.Batch[0], tx1) {
+		t.Fatalf("got %v, want only tx1 in batch", ctx.Batch)
+	}
+	if len(ctx.Deferred) != 1 || !testutils.IsTxsEqual(ctx.Deferred[0].Tx, tx2) {
+		t.Fatalf("got %v, want tx2 deferred", ctx.Deferred)
+	}
+	if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got %d removals, want 0", len(ctx.PendingRemoval))
+	}
+	ents := ctx.Data[ConflictEntitiesKey].([]string)
+	if len(ents) != 1 || ents[0] != tx2.GetPaymaster().Hex() {
+		t.Fatalf("got %v, want paymaster at fault", ents)
+	}
+}
+
+// TestSimulateBatchDropsInvalid verifies that a RIP-7560 transaction failing validation on its own is removed
+// and later nonces from the same sender are deferred.
+func TestSimulateBatchDropsInvalid(t *testing.T) {
+	bad := common.Address{0x02}
+	tx1 := mockTx(common.Address{0x01}, 0)
+	tx2 := mockTx(bad, 0)
+	tx3 := mockTx(bad, 1)
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2, tx3} {
+		tx.Paymaster = nil
+	}
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+	execute := func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		if tx.GetSender() == bad {
+			return nil, stderrors.New("signature error")
+		}
+		return state.OverrideSet{}, nil
+	}
+
+	ctx := newCtx(tx1, tx2, tx3)
+	sim := New(gb, execute, nil)
+	if err := sim.SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 1 || !testutils.IsTxsEqual(ctx.Batch[0], tx1) {
+		t.Fatalf("got %v, want only tx1 in batch", ctx.Batch)
+	}
+	if len(ctx.PendingRemoval) != 1 || !testutils.IsTxsEqual(ctx.PendingRemoval[0].Tx, tx2) {
+		t.Fatalf("got %v, want tx2 removed", ctx.PendingRemoval)
+	}
+	if ctx.PendingRemoval[0].Reason != DropReasonPrefix+": signature error" {
+		t.Fatalf("got reason %q, want simulation failure", ctx.PendingRemoval[0].Reason)
+	}
+	if len(ctx.Deferred) != 1 || !testutils.IsTxsEqual(ctx.Deferred[0].Tx, tx3) {
+		t.Fatalf("got %v, want tx3 deferred", ctx.Deferred)
+	}
+}
+
+// TestSimulateBatchChainedStateConflict verifies that a RIP-7560 transaction is deferred if it only fails on
+// top of the state changed by the execution of an earlier transaction from a different sender.
+func TestSimulateBatchChainedStateConflict(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0)
+	tx2 := mockTx(common.Address{0x02}, 0)
+	slot := map[common.Hash]common.Hash{{0x01}: {0x01}}
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+	execute := func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		if tx.GetSender() == tx1.GetSender() {
+			return state.OverrideSet{tx2.GetDeployer(): state.OverrideAccount{StateDiff: &slot}}, nil
+		}
+		if _, ok := os[tx2.GetDeployer()]; ok {
+			return nil, stderrors.New("deployer reverted")
+		}
+		return state.OverrideSet{}, nil
+	}
+
+	ctx := newCtx(tx1, tx2)
+	if err := New(gb, execute, nil).SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 1 || !testutils.IsTxsEqual(ctx.Batch[0], tx1) {
+		t.Fatalf("got %v, want only tx1 in batch", ctx.Batch)
+	}
+	if len(ctx.Deferred) != 1 || !testutils.IsTxsEqual(ctx.Deferred[0].Tx, tx2) {
+		t.Fatalf("got %v, want tx2 deferred", ctx.Deferred)
+	}
+	if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got %d removals, want 0", len(ctx.PendingRemoval))
+	}
+	ents := ctx.Data[ConflictEntitiesKey].([]string)
+	if len(ents) != 1 || ents[0] != tx2.GetDeployer().Hex() {
+		t.Fatalf("got %v, want deployer at fault", ents)
+	}
+}
+
+// TestSimulateBatchChainsLaneNonces verifies that a later nonce is run on top of the state changed by the
+// earlier nonce of the same sequence.
+func TestSimulateBatchChainsLaneNonces(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0)
+	tx2 := mockTx(common.Address{0x01}, 1)
+	nonce := hexutil.Uint64(1)
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+	execute := func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		if tx.GetNonce() == 0 {
+			return state.OverrideSet{tx.GetSender(): state.OverrideAccount{Nonce: &nonce}}, nil
+		}
+		if oa, ok := os[tx.GetSender()]; !ok || oa.Nonce == nil || *oa.Nonce != nonce {
+			return nil, stderrors.New("invalid nonce")
+		}
+		return state.OverrideSet{}, nil
+	}
+
+	ctx := newCtx(tx1, tx2)
+	if err := New(gb, execute, nil).SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(ctx.Batch))
+	}
+}
+
+// TestSimulateBatchDropAttributedByTrace verifies that a dropped RIP-7560 transaction is charged to the entity
+// that the validation trace attributes the failure to.
+func TestSimulateBatchDropAttributedByTrace(t *testing.T) {
+	tx := mockTx(common.Address{0x01}, 0)
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+	execute := func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		return nil, stderrors.New("validation failed")
+	}
+	trace := func(tx *transaction.TransactionArgs) error {
+		return &simulation.EntityError{Entity: simulation.EntityPaymaster, Err: stderrors.New("paymaster reverted")}
+	}
+
+	ctx := newCtx(tx)
+	if err := New(gb, execute, trace).SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.PendingRemoval) != 1 {
+		t.Fatalf("got %d removals, want 1", len(ctx.PendingRemoval))
+	}
+	ents := ctx.Data[ConflictEntitiesKey].([]string)
+	if len(ents) != 1 || ents[0] != tx.GetPaymaster().Hex() {
+		t.Fatalf("got %v, want paymaster at fault", ents)
+	}
+}
+
+// TestSimulateBatchTransportError verifies that the batch is aborted without dropping any RIP-7560
+// transactions if the node cannot be reached.
+func TestSimulateBatchTransportError(t *testing.T) {
+	tx := mockTx(common.Address{0x01}, 0)
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+	execute := func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		return nil, errors.WrapTransportError(stderrors.New("connection refused"))
+	}
+
+	ctx := newCtx(tx)
+	if err := New(gb, execute, nil).SimulateBatch()(ctx); !errors.IsTransportError(err) {
+		t.Fatalf("got %v, want transport error", err)
+	}
+	if len(ctx.PendingRemoval) != 0 || len(ctx.Deferred) != 0 {
+		t.Fatalf("got %d removals and %d deferred, want 0", len(ctx.PendingRemoval), len(ctx.Deferred))
+	}
+}
+
+// TestSimulateBatchDefersAfterEarlierRemoval verifies that later nonces are deferred if an earlier module
+// removed a RIP-7560 transaction from the same sequence.
+func TestSimulateBatchDefersAfterEarlierRemoval(t *testing.T) {
+	tx1 := mockTx(common.Address{0x01}, 0)
+	tx2 := mockTx(common.Address{0x01}, 1)
+	gb := func(acc common.Address) (*big.Int, error) {
+		return new(big.Int).Lsh(big.NewInt(1), 128), nil
+	}
+
+	ctx := newCtx(tx1, tx2)
+	ctx.MarkTxIndexForRemoval(0, "expired")
+	if err := New(gb, executeOk, nil).SimulateBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 0 {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	}
+	if len(ctx.Deferred) != 1 || !testutils.IsTxsEqual(ctx.Deferred[0].Tx, tx2) {
+		t.Fatalf("got %v, want tx2 deferred", ctx.Deferred)
+	}
+}
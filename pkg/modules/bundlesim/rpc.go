@@ -0,0 +1,123 @@
+package bundlesim
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/state"
+)
+
+// GetBalanceWithEthClient returns a GetBalanceFunc that relies on an eth client.
+func GetBalanceWithEthClient(eth *ethclient.Client) GetBalanceFunc {
+	return func(acc common.Address) (*big.Int, error) {
+		return eth.BalanceAt(context.Background(), acc, nil)
+	}
+}
+
+// prestateAccount is an account in the result of the prestateTracer.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Nonce   *uint64                     `json:"nonce,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateDiff is the result of the prestateTracer in diff mode.
+type prestateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+// toOverrides returns the state after the traced call as an OverrideSet. Storage slots that were cleared are
+// left out of the post state by the tracer and are set to zero.
+func (d *prestateDiff) toOverrides() state.OverrideSet {
+	os := state.OverrideSet{}
+	for addr, post := range d.Post {
+		oa := state.OverrideAccount{Balance: post.Balance, Code: post.Code}
+		if post.Nonce != nil {
+			nonce := hexutil.Uint64(*post.Nonce)
+			oa.Nonce = &nonce
+		}
+		diff := make(map[common.Hash]common.Hash)
+		if pre, ok := d.Pre[addr]; ok {
+			for slot := range pre.Storage {
+				diff[slot] = common.Hash{}
+			}
+		}
+		for slot, val := range post.Storage {
+			diff[slot] = val
+		}
+		if len(diff) > 0 {
+			oa.StateDiff = &diff
+		}
+		os[addr] = oa
+	}
+	return os
+}
+
+// ExecuteWithRpcClient returns an ExecuteFunc that calls debug_traceCall with the prestateTracer in diff mode
+// on top of the given state overrides. The node must support tracing RIP-7560 transactions with
+// debug_traceCall.
+func ExecuteWithRpcClient(rpc *rpc.Client) ExecuteFunc {
+	return func(tx *transaction.TransactionArgs, os state.OverrideSet) (state.OverrideSet, error) {
+		cfg := map[string]any{
+			"tracer":         "prestateTracer",
+			"tracerConfig":   map[string]any{"diffMode": true},
+			"stateOverrides": os,
+		}
+		var res prestateDiff
+		if err := rpc.CallContext(context.Background(), &res, "debug_traceCall", tx, "latest", cfg); err != nil {
+			return nil, errors.WrapTransportError(err)
+		}
+		return res.toOverrides(), nil
+	}
+}
+
+// GetAltMempoolsFunc returns the alternative mempools a transaction may be validated under.
+type GetAltMempoolsFunc = func(tx *transaction.TransactionArgs) *altmempools.Directory
+
+// GetAltMempoolsWithMetadata returns a GetAltMempoolsFunc that limits a transaction to the alternative
+// mempools it was tagged with when it was added to the mempool.
+func GetAltMempoolsWithMetadata(mem *mempool.Mempool, d *altmempools.Directory) GetAltMempoolsFunc {
+	return func(tx *transaction.TransactionArgs) *altmempools.Directory {
+		meta := mem.GetMetadata(tx)
+		if meta == nil {
+			return nil
+		}
+		return d.Only(meta.AltMempoolIds)
+	}
+}
+
+// TraceWithRpcClient returns a TraceFunc that calls debug_traceRip7560Validation. The isStaked function is
+// used to apply the staked entity exceptions and can be nil. The gam function returns the alternative
+// mempools that may waive rules for each transaction and can also be nil.
+func TraceWithRpcClient(
+	rpc *rpc.Client,
+	chainID *big.Int,
+	isStaked simulation.IsStakedFunc,
+	gam GetAltMempoolsFunc,
+) TraceFunc {
+	return func(tx *transaction.TransactionArgs) error {
+		var am *altmempools.Directory
+		if gam != nil {
+			am = gam(tx)
+		}
+		_, err := simulation.TraceSimulateValidation(&simulation.TraceInput{
+			Rpc:         rpc,
+			Tx:          tx,
+			ChainID:     chainID,
+			IsStaked:    isStaked,
+			AltMempools: am,
+		})
+		return err
+	}
+}
@@ -0,0 +1,22 @@
+package checks
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// ValidateBuilderFee checks the builderFee is at least the minimum accepted by the bundler. A nil minimum
+// accepts any builder fee.
+func ValidateBuilderFee(txArgs *transaction.TransactionArgs, min *big.Int) error {
+	if min == nil {
+		return nil
+	}
+
+	if fee := txArgs.GetBuilderFee(); fee.Cmp(min) < 0 {
+		return fmt.Errorf("builderFee: must be equal to or greater than %s", min.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package checks
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestBuilderFeeBelowMin calls checks.ValidateBuilderFee with a builderFee below the minimum. Expect error.
+func TestBuilderFeeBelowMin(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.BuilderFee = (*hexutil.Big)(big.NewInt(1))
+	if err := ValidateBuilderFee(tx, big.NewInt(2)); err == nil {
+		t.Fatal("got nil, want err")
+	}
+
+	tx.BuilderFee = nil
+	if err := ValidateBuilderFee(tx, big.NewInt(2)); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestBuilderFeeAtMin calls checks.ValidateBuilderFee with a builderFee equal to the minimum. Expect nil.
+func TestBuilderFeeAtMin(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.BuilderFee = (*hexutil.Big)(big.NewInt(2))
+	if err := ValidateBuilderFee(tx, big.NewInt(2)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := ValidateBuilderFee(tx, nil); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
@@ -0,0 +1,17 @@
+package checks
+
+import (
+	"errors"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// ValidateGasLimits checks that the sum of verificationGasLimit, paymasterVerificationGasLimit, gas, and
+// paymasterPostOpGasLimit does not overflow.
+func ValidateGasLimits(txArgs *transaction.TransactionArgs) error {
+	if txArgs.HasTotalGasLimitOverflow() {
+		return errors.New("gas limits: total gas limit overflows uint64")
+	}
+
+	return nil
+}
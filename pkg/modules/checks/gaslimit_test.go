@@ -0,0 +1,31 @@
+package checks
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestGasLimitsOverflow calls checks.ValidateGasLimits with gas limits that sum to more than a uint64. Expect
+// error.
+func TestGasLimitsOverflow(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	gas := hexutil.Uint64(math.MaxUint64)
+	tx.Gas = &gas
+	if err := ValidateGasLimits(tx); err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if total := tx.GetTotalGasLimit(); total != math.MaxUint64 {
+		t.Fatalf("got total gas limit %d, want %d", total, uint64(math.MaxUint64))
+	}
+}
+
+// TestGasLimitsOk calls checks.ValidateGasLimits with valid gas limits. Expect nil.
+func TestGasLimitsOk(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := ValidateGasLimits(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
@@ -10,12 +10,22 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
 )
 
+// calcNewThresholds returns new threshold values where newFee = oldFee  * (100 + priceBump) / 100.
+func calcNewThresholds(cap *big.Int, tip *big.Int, priceBump int64) (newCap *big.Int, newTip *big.Int) {
+	a := big.NewInt(100 + priceBump)
+	b := big.NewInt(100)
+	newCap = new(big.Int).Mul(a, cap)
+	newTip = new(big.Int).Mul(a, tip)
+	return newCap.Div(newCap, b), newTip.Div(newTip, b)
+}
+
 func TestNoPendingTxs(t *testing.T) {
 	var penTxs []*transaction.TransactionArgs
 	tx := testutils.MockValidInitRip7560Tx()
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if err != nil {
@@ -31,6 +41,7 @@ func TestPendingTxsWithNewTx(t *testing.T) {
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if err != nil {
@@ -45,6 +56,7 @@ func TestPendingTxsWithNoGasFeeReplacement(t *testing.T) {
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if !errors.Is(err, ErrReplacementTxUnderpriced) {
@@ -56,11 +68,16 @@ func TestPendingTxsWithOnlyMaxFeeReplacement(t *testing.T) {
 	penTx := testutils.MockValidInitRip7560Tx()
 	penTxs := []*transaction.TransactionArgs{penTx}
 	tx := testutils.MockValidInitRip7560Tx()
-	maxFeePerGas, _ := calcNewThresholds((*big.Int)(tx.MaxFeePerGas), (*big.Int)(tx.MaxPriorityFeePerGas))
+	maxFeePerGas, _ := calcNewThresholds(
+		(*big.Int)(tx.MaxFeePerGas),
+		(*big.Int)(tx.MaxPriorityFeePerGas),
+		DefaultPriceBump,
+	)
 	tx.MaxFeePerGas = (*hexutil.Big)(maxFeePerGas)
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if !errors.Is(err, ErrReplacementTxUnderpriced) {
@@ -72,11 +89,16 @@ func TestPendingTxsWithOnlyMaxPriorityFeeReplacement(t *testing.T) {
 	penTx := testutils.MockValidInitRip7560Tx()
 	penTxs := []*transaction.TransactionArgs{penTx}
 	tx := testutils.MockValidInitRip7560Tx()
-	maxFeePerGas, _ := calcNewThresholds((*big.Int)(tx.MaxFeePerGas), (*big.Int)(tx.MaxPriorityFeePerGas))
+	maxFeePerGas, _ := calcNewThresholds(
+		(*big.Int)(tx.MaxFeePerGas),
+		(*big.Int)(tx.MaxPriorityFeePerGas),
+		DefaultPriceBump,
+	)
 	tx.MaxFeePerGas = (*hexutil.Big)(maxFeePerGas)
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if !errors.Is(err, ErrReplacementTxUnderpriced) {
@@ -88,14 +110,73 @@ func TestPendingTxsWithOkGasFeeReplacement(t *testing.T) {
 	penTx := testutils.MockValidInitRip7560Tx()
 	penTxs := []*transaction.TransactionArgs{penTx}
 	tx := testutils.MockValidInitRip7560Tx()
-	maxFeePerGas, _ := calcNewThresholds((*big.Int)(tx.MaxFeePerGas), (*big.Int)(tx.MaxPriorityFeePerGas))
+	maxFeePerGas, maxPriorityFeePerGas := calcNewThresholds(
+		(*big.Int)(tx.MaxFeePerGas),
+		(*big.Int)(tx.MaxPriorityFeePerGas),
+		DefaultPriceBump,
+	)
 	tx.MaxFeePerGas = (*hexutil.Big)(maxFeePerGas)
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(maxPriorityFeePerGas)
+	err := ValidatePendingTxs(
+		tx,
+		penTxs,
+		DefaultPriceBump,
+	)
+
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+}
+
+func TestPendingTxsWithDifferentNonceKey(t *testing.T) {
+	penTx := testutils.MockValidInitRip7560Tx()
+	penTxs := []*transaction.TransactionArgs{penTx}
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.NonceKey = (*hexutil.Big)(big.NewInt(1))
 	err := ValidatePendingTxs(
 		tx,
 		penTxs,
+		DefaultPriceBump,
 	)
 
 	if err != nil {
 		t.Fatalf("got err %v, want nil", err)
 	}
 }
+
+func TestPendingTxsWithCustomPriceBump(t *testing.T) {
+	penTx := testutils.MockValidInitRip7560Tx()
+	penTxs := []*transaction.TransactionArgs{penTx}
+	tx := testutils.MockValidInitRip7560Tx()
+	maxFeePerGas, maxPriorityFeePerGas := calcNewThresholds(
+		(*big.Int)(tx.MaxFeePerGas),
+		(*big.Int)(tx.MaxPriorityFeePerGas),
+		DefaultPriceBump,
+	)
+	tx.MaxFeePerGas = (*hexutil.Big)(maxFeePerGas)
+	tx.MaxPriorityFeePerGas = (*hexutil.Big)(maxPriorityFeePerGas)
+
+	if err := ValidatePendingTxs(tx, penTxs, 25); !errors.Is(err, ErrReplacementTxUnderpriced) {
+		t.Fatalf("got %v, want ErrReplacementTxUnderpriced", err)
+	}
+	if err := ValidatePendingTxs(tx, penTxs, 5); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+}
+
+func TestPendingTxsWithMissingFeeReplacement(t *testing.T) {
+	penTx := testutils.MockValidInitRip7560Tx()
+	penTxs := []*transaction.TransactionArgs{penTx}
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.MaxFeePerGas = nil
+	tx.MaxPriorityFeePerGas = nil
+	err := ValidatePendingTxs(
+		tx,
+		penTxs,
+		DefaultPriceBump,
+	)
+
+	if !errors.Is(err, ErrReplacementTxUnderpriced) {
+		t.Fatalf("got %v, want ErrReplacementTxUnderpriced", err)
+	}
+}
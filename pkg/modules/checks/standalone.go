@@ -3,6 +3,7 @@
 package checks
 
 import (
+	stderrors "errors"
 	"math/big"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
 	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
@@ -29,6 +31,10 @@ type Standalone struct {
 	maxVerificationGas *big.Int
 	maxBatchGasLimit   *big.Int
 	repConst           *entities.ReputationConstants
+	priceBump          int64
+	minBuilderFee      *big.Int
+	isStaked           simulation.IsStakedFunc
+	altMempools        *altmempools.Directory
 }
 
 // New returns a Standalone instance with methods that can be used in Client and Bundler modules to perform
@@ -48,21 +54,56 @@ func New(
 		maxVerificationGas,
 		maxBatchGasLimit,
 		repConst,
+		DefaultPriceBump,
+		nil,
+		nil,
+		nil,
 	}
 }
 
+// SetReplacementPriceBump sets the minimum percentage that a tx must increase both maxFeePerGas and
+// maxPriorityFeePerGas by in order to replace a pending tx with the same sender, nonce key, and nonce.
+func (s *Standalone) SetReplacementPriceBump(pct int64) {
+	s.priceBump = pct
+}
+
+// SetMinBuilderFee sets the minimum builderFee that a tx must pay in order to be accepted. A nil value
+// accepts any builder fee.
+func (s *Standalone) SetMinBuilderFee(fee *big.Int) {
+	s.minBuilderFee = fee
+}
+
+// SetIsStakedFunc defines the function used to apply the staked entity exceptions when tracing validation.
+// All entities are treated as unstaked if it is not set.
+func (s *Standalone) SetIsStakedFunc(fn simulation.IsStakedFunc) {
+	s.isStaked = fn
+}
+
+// SetAltMempools defines the alternative mempools that may waive validation rules for a new tx. The ids of
+// the alternative mempools a tx needs are saved in its metadata.
+func (s *Standalone) SetAltMempools(d *altmempools.Directory) {
+	s.altMempools = d
+}
+
 // ValidateTxValues returns a Rip7560TxHandler that runs through some first line sanity checks for new Rip7560Txs
 // received by the Client. This should be one of the first modules executed by the Client.
+//
+// The replacement price bump is checked here against a snapshot of pending txs to reject underpriced
+// replacements early. The mempool enforces it again when the tx is added.
 func (s *Standalone) ValidateTxValues() modules.Rip7560TxHandlerFunc {
 	return func(ctx *modules.TxHandlerCtx) error {
+		if err := ValidatePendingTxs(ctx.Tx, ctx.GetPendingSenderTxs(), s.priceBump); err != nil {
+			return errors.NewRPCError(errors.REPLACEMENT_UNDERPRICED, err.Error(), err.Error())
+		}
+
 		gc := getCodeWithEthClient(s.eth)
 
 		g := new(errgroup.Group)
 		g.Go(func() error { return ValidateSender(ctx.Tx, gc) })
 		g.Go(func() error { return ValidatePaymasterAndData(ctx.Tx, gc) })
 		g.Go(func() error { return ValidateFeePerGas(ctx.Tx, gasprice.GetBaseFeeWithEthClient(s.eth)) })
-		// TODO : Uncommenting after modifications related to 2d nonce manager
-		//g.Go(func() error { return ValidatePendingTxs(ctx.Tx, ctx.GetPendingSenderTxs()) })
+		g.Go(func() error { return ValidateBuilderFee(ctx.Tx, s.minBuilderFee) })
+		g.Go(func() error { return ValidateGasLimits(ctx.Tx) })
 
 		if err := g.Wait(); err != nil {
 			return errors.NewRPCError(errors.INVALID_FIELDS, err.Error(), err.Error())
@@ -90,18 +131,25 @@ func (s *Standalone) SimulateTx() modules.Rip7560TxHandlerFunc {
 					nil,
 				)
 			}
+			ctx.Metadata.ValidAfter, ctx.Metadata.ValidUntil = simulation.GetValidityWindow(sim)
 			return nil
 		})
 		g.Go(func() error {
 			out, err := simulation.TraceSimulateValidation(&simulation.TraceInput{
-				Rpc:     s.rpc,
-				Tx:      ctx.Tx,
-				ChainID: ctx.ChainID,
+				Rpc:         s.rpc,
+				Tx:          ctx.Tx,
+				ChainID:     ctx.ChainID,
+				IsStaked:    s.isStaked,
+				AltMempools: s.altMempools,
 			})
-			if err != nil {
+			if stderrors.Is(err, simulation.ErrDeployerSenderMismatch) {
+				return errors.NewRPCError(errors.INVALID_DEPLOYMENT, err.Error(), err.Error())
+			} else if err != nil {
 				return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
 			}
 
+			ctx.Metadata.TouchedContracts = out.TouchedContracts
+			ctx.Metadata.AltMempoolIds = out.AltMempoolIds
 			ch, err := getCodeHashes(out.TouchedContracts, gc)
 			if err != nil {
 				return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
@@ -20,6 +20,7 @@ type PendingRemovalItem struct {
 type BatchHandlerCtx struct {
 	Batch          []*transaction.TransactionArgs
 	PendingRemoval []*PendingRemovalItem
+	Deferred       []*PendingRemovalItem
 	ChainID        *big.Int
 	BaseFee        *big.Int
 	Tip            *big.Int
@@ -41,6 +42,7 @@ func NewBatchHandlerContext(
 	return &BatchHandlerCtx{
 		Batch:          batchAppended,
 		PendingRemoval: []*PendingRemovalItem{},
+		Deferred:       []*PendingRemovalItem{},
 		ChainID:        chainID,
 		BaseFee:        baseFee,
 		Tip:            tip,
@@ -52,6 +54,27 @@ func NewBatchHandlerContext(
 // MarkTxIndexForRemoval will remove the op by index from the batch and add it to the pending removal array.
 // This should be used for txs that are not to be included on-chain and dropped from the mempool.
 func (c *BatchHandlerCtx) MarkTxIndexForRemoval(index int, reason string) {
+	if tx := c.removeTxIndex(index); tx != nil {
+		c.PendingRemoval = append(c.PendingRemoval, &PendingRemovalItem{
+			Tx:     tx,
+			Reason: reason,
+		})
+	}
+}
+
+// DeferTxIndex will remove the op by index from the batch and add it to the deferred array. This should be
+// used for txs that are still valid but cannot be included in the current batch. They are kept in the
+// mempool for a later batch.
+func (c *BatchHandlerCtx) DeferTxIndex(index int, reason string) {
+	if tx := c.removeTxIndex(index); tx != nil {
+		c.Deferred = append(c.Deferred, &PendingRemovalItem{
+			Tx:     tx,
+			Reason: reason,
+		})
+	}
+}
+
+func (c *BatchHandlerCtx) removeTxIndex(index int) *transaction.TransactionArgs {
 	var batch []*transaction.TransactionArgs
 	var tx *transaction.TransactionArgs
 	for i, curr := range c.Batch {
@@ -62,14 +85,11 @@ func (c *BatchHandlerCtx) MarkTxIndexForRemoval(index int, reason string) {
 		}
 	}
 	if tx == nil {
-		return
+		return nil
 	}
 
 	c.Batch = batch
-	c.PendingRemoval = append(c.PendingRemoval, &PendingRemovalItem{
-		Tx:     tx,
-		Reason: reason,
-	})
+	return tx
 }
 
 // TxHandlerCtx is the object passed to Rip7560TxHandler functions during the Client's SendRip7560Transaction
@@ -77,12 +97,14 @@ func (c *BatchHandlerCtx) MarkTxIndexForRemoval(index int, reason string) {
 type TxHandlerCtx struct {
 	Tx                  *transaction.TransactionArgs
 	ChainID             *big.Int
+	Metadata            *mempool.TxMetadata
 	pendingSenderTxs    []*transaction.TransactionArgs
 	pendingDeployerTxs  []*transaction.TransactionArgs
 	pendingPaymasterTxs []*transaction.TransactionArgs
 }
 
-// NewTxHandlerContext creates a new TxHandlerCtx using a given tx.
+// NewTxHandlerContext creates a new TxHandlerCtx using a given tx. Modules can record information about the
+// tx in Metadata which will be saved to the mempool along with it.
 func NewTxHandlerContext(
 	txArgs *transaction.TransactionArgs,
 	chainID *big.Int,
@@ -105,6 +127,7 @@ func NewTxHandlerContext(
 	return &TxHandlerCtx{
 		Tx:                  txArgs,
 		ChainID:             chainID,
+		Metadata:            mempool.NewTxMetadata(),
 		pendingSenderTxs:    pso,
 		pendingDeployerTxs:  pdo,
 		pendingPaymasterTxs: ppo,
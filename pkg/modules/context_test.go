@@ -1,12 +1,9 @@
 package modules
 
 import (
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
-	"math/big"
 	"testing"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 )
@@ -47,11 +44,11 @@ func TestGetPendingSenderTxs(t *testing.T) {
 	_ = mem.AddTx(penTx1)
 
 	penTx2 := testutils.MockValidInitRip7560Tx()
-	*penTx2.BigNonce = (hexutil.Big)(*big.NewInt(0).Add((*big.Int)(penTx1.BigNonce), common.Big1))
+	*penTx2.Nonce = *penTx1.Nonce + 1
 	_ = mem.AddTx(penTx2)
 
 	penTx3 := testutils.MockValidInitRip7560Tx()
-	*penTx3.BigNonce = (hexutil.Big)(*big.NewInt(0).Add((*big.Int)(penTx2.BigNonce), common.Big1))
+	*penTx3.Nonce = *penTx2.Nonce + 1
 	_ = mem.AddTx(penTx3)
 
 	ctx, err := NewTxHandlerContext(
@@ -108,7 +105,7 @@ func TestGetPendingFactoryTxs(t *testing.T) {
 	}
 
 	expectedPenTxs := []*transaction.TransactionArgs{penTx3, penTx2, penTx1}
-	penTxs := ctx.GetPendingSenderTxs()
+	penTxs := ctx.GetPendingFactoryTxs()
 	if len(penTxs) != len(expectedPenTxs) {
 		t.Fatalf("got length %d, want %d", len(penTxs), len(expectedPenTxs))
 	}
@@ -153,7 +150,7 @@ func TestGetPendingPaymasterTxs(t *testing.T) {
 	}
 
 	expectedPenTxs := []*transaction.TransactionArgs{penTx3, penTx2, penTx1}
-	penTxs := ctx.GetPendingSenderTxs()
+	penTxs := ctx.GetPendingPaymasterTxs()
 	if len(penTxs) != len(expectedPenTxs) {
 		t.Fatalf("got length %d, want %d", len(penTxs), len(expectedPenTxs))
 	}
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
 // Reputation provides Client and Bundler modules to track the reputation of every entity seen in a
@@ -19,11 +20,18 @@ type Reputation struct {
 	db       *badger.DB
 	eth      *ethclient.Client
 	repConst *ReputationConstants
+	isStaked IsStakedFunc
 }
 
 // New returns an instance of a Reputation object to track and appropriately process Rip7560Txs by entity status.
 func New(db *badger.DB, eth *ethclient.Client, repConst *ReputationConstants) *Reputation {
-	return &Reputation{db, eth, repConst}
+	return &Reputation{db, eth, repConst, isStakedNoop()}
+}
+
+// SetIsStakedFunc defines the function used to check if an entity is staked. Staked entities are given higher
+// mempool limits. All entities are treated as unstaked by default.
+func (r *Reputation) SetIsStakedFunc(fn IsStakedFunc) {
+	r.isStaked = fn
 }
 
 // CheckStatus returns a Rip7560TxHandler that is used by the Client to determine if the Rip-7560 transaction is allowed based
@@ -95,51 +103,38 @@ func (r *Reputation) CheckStatus() modules.Rip7560TxHandlerFunc {
 }
 
 // ValidateTxLimit returns a Rip7560TxHandler that is used by the Client to determine if the transaction is allowed
-// based on the number of pending txs in the mempool.
+// based on the number of pending txs in the mempool. Senders are always held to SameSenderMempoolCount. Staked
+// deployers and paymasters are allowed up to SameStakedEntityMempoolCount pending txs instead of the unstaked
+// limit.
 func (r *Reputation) ValidateTxLimit() modules.Rip7560TxHandlerFunc {
 	return func(ctx *modules.TxHandlerCtx) error {
-		pso := ctx.GetPendingSenderTxs()
-		if len(pso) == r.repConst.SameSenderMempoolCount {
-			return errors.NewRPCError(
-				errors.INVALID_ENTITY_STAKE,
-				fmt.Sprintf(
-					"unstaked entity: %s exceeds pending txs limit of %d",
-					ctx.Tx.Sender.Hex(),
-					r.repConst.SameSenderMempoolCount,
-				),
-				nil,
-			)
+		if err := validatePendingLimit(
+			ctx.GetSender(),
+			len(ctx.GetPendingSenderTxs()),
+			r.repConst.SameSenderMempoolCount,
+		); err != nil {
+			return err
 		}
 
 		deployer := ctx.GetDeployer()
 		if deployer != common.HexToAddress("0x") {
-			pfo := ctx.GetPendingFactoryTxs()
-			if len(pfo) == r.repConst.SameUnstakedEntityMempoolCount {
-				return errors.NewRPCError(
-					errors.INVALID_ENTITY_STAKE,
-					fmt.Sprintf(
-						"unstaked entity: %s exceeds pending txs limit of %d",
-						deployer.Hex(),
-						r.repConst.SameUnstakedEntityMempoolCount,
-					),
-					nil,
-				)
+			limit, err := r.getEntityLimit(deployer)
+			if err != nil {
+				return err
+			}
+			if err := validatePendingLimit(deployer, len(ctx.GetPendingFactoryTxs()), limit); err != nil {
+				return err
 			}
 		}
 
 		paymaster := ctx.GetPaymaster()
 		if paymaster != common.HexToAddress("0x") {
-			ppo := ctx.GetPendingPaymasterTxs()
-			if len(ppo) == r.repConst.SameUnstakedEntityMempoolCount {
-				return errors.NewRPCError(
-					errors.INVALID_ENTITY_STAKE,
-					fmt.Sprintf(
-						"unstaked entity: %s exceeds pending txs limit of %d",
-						paymaster.Hex(),
-						r.repConst.SameUnstakedEntityMempoolCount,
-					),
-					nil,
-				)
+			limit, err := r.getEntityLimit(paymaster)
+			if err != nil {
+				return err
+			}
+			if err := validatePendingLimit(paymaster, len(ctx.GetPendingPaymasterTxs()), limit); err != nil {
+				return err
 			}
 		}
 
@@ -147,6 +142,35 @@ func (r *Reputation) ValidateTxLimit() modules.Rip7560TxHandlerFunc {
 	}
 }
 
+// getEntityLimit returns the number of pending txs allowed for a deployer or paymaster based on its stake.
+func (r *Reputation) getEntityLimit(entity common.Address) (int, error) {
+	staked, err := r.isStaked(entity)
+	if err != nil {
+		return 0, errors.NewRPCError(errors.INVALID_ENTITY_STAKE, err.Error(), err.Error())
+	}
+
+	if staked {
+		return r.repConst.SameStakedEntityMempoolCount, nil
+	}
+	return r.repConst.SameUnstakedEntityMempoolCount, nil
+}
+
+// validatePendingLimit returns an error if the entity already has limit or more pending txs.
+func validatePendingLimit(entity common.Address, pending int, limit int) error {
+	if pending >= limit {
+		return errors.NewRPCError(
+			errors.INVALID_ENTITY_STAKE,
+			fmt.Sprintf(
+				"entity: %s exceeds pending txs limit of %d",
+				entity.Hex(),
+				limit,
+			),
+			nil,
+		)
+	}
+	return nil
+}
+
 // IncTxsSeen returns a Rip7560TxHandler that is used by the Client to increment the txsSeen counter for all
 // included entities.
 func (r *Reputation) IncTxsSeen() modules.Rip7560TxHandlerFunc {
@@ -171,39 +195,46 @@ func (r *Reputation) IncTxsSeen() modules.Rip7560TxHandlerFunc {
 }
 
 // IncTxsIncluded returns a BatchHandler used by the Bundler to increment txsIncluded counters for all
-// relevant entities in the batch. This module should be used last once batches have been sent.
+// relevant entities in the batch. This module should be used last once batches have been sent. Bundlers that
+// learn about inclusion from receipts or the sequencer should use IncTxsIncludedFor instead.
 func (r *Reputation) IncTxsIncluded() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		return r.db.Update(func(txn *badger.Txn) error {
-			c := make(addressCounter)
-			for _, aaTxRaw := range ctx.Batch {
-				if _, ok := c[aaTxRaw.GetSender()]; !ok {
-					c[aaTxRaw.GetSender()] = 0
-				}
-				c[aaTxRaw.GetSender()]++
+		return r.IncTxsIncludedFor(ctx.Batch...)
+	}
+}
 
-				deployer := aaTxRaw.GetDeployer()
-				if deployer != common.HexToAddress("0x") {
-					if _, ok := c[deployer]; !ok {
-						c[deployer] = 0
-					}
+// IncTxsIncludedFor increments txsIncluded counters for all relevant entities in the given transactions. This
+// should be called once the transactions are known to be included on-chain.
+func (r *Reputation) IncTxsIncludedFor(txs ...*transaction.TransactionArgs) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		c := make(addressCounter)
+		for _, aaTxRaw := range txs {
+			if _, ok := c[aaTxRaw.GetSender()]; !ok {
+				c[aaTxRaw.GetSender()] = 0
+			}
+			c[aaTxRaw.GetSender()]++
 
-					c[deployer]++
+			deployer := aaTxRaw.GetDeployer()
+			if deployer != common.HexToAddress("0x") {
+				if _, ok := c[deployer]; !ok {
+					c[deployer] = 0
 				}
 
-				paymaster := aaTxRaw.GetPaymaster()
-				if paymaster != common.HexToAddress("0x") {
-					if _, ok := c[paymaster]; !ok {
-						c[paymaster] = 0
-					}
+				c[deployer]++
+			}
 
-					c[paymaster]++
+			paymaster := aaTxRaw.GetPaymaster()
+			if paymaster != common.HexToAddress("0x") {
+				if _, ok := c[paymaster]; !ok {
+					c[paymaster] = 0
 				}
+
+				c[paymaster]++
 			}
+		}
 
-			return incrementTxsIncludedByEntity(txn, c)
-		})
-	}
+		return incrementTxsIncludedByEntity(txn, c)
+	})
 }
 
 func (r *Reputation) Override(entries []*ReputationOverride) error {
@@ -215,3 +246,12 @@ func (r *Reputation) Override(entries []*ReputationOverride) error {
 		return err
 	})
 }
+
+// Penalize increments the txsSeen counter for an entity without a matching increment to txsIncluded. This
+// lowers the inclusion rate of entities that are responsible for transactions that became invalid while in
+// the mempool.
+func (r *Reputation) Penalize(entity common.Address) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		return incrementTxsSeenByEntity(txn, entity)
+	})
+}
@@ -0,0 +1,135 @@
+package entities
+
+import (
+	stderrors "errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+)
+
+func isStakedMock(staked ...common.Address) IsStakedFunc {
+	return func(entity common.Address) (bool, error) {
+		for _, addr := range staked {
+			if addr == entity {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// TestValidateTxLimitStakedPaymaster verifies that a staked paymaster is allowed more pending txs than an
+// unstaked one up to the staked limit.
+func TestValidateTxLimitStakedPaymaster(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	senders := []common.Address{testutils.ValidAddress1, testutils.ValidAddress2, testutils.ValidAddress3}
+	for _, sender := range senders[:2] {
+		tx := testutils.MockValidInitRip7560Tx()
+		*tx.Sender = sender
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	tx := testutils.MockValidInitRip7560Tx()
+	*tx.Sender = senders[2]
+	ctx, err := modules.NewTxHandlerContext(tx, big.NewInt(1), mem)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	repConst := &ReputationConstants{
+		SameSenderMempoolCount:         4,
+		SameUnstakedEntityMempoolCount: 2,
+		SameStakedEntityMempoolCount:   3,
+	}
+	rep := New(nil, nil, repConst)
+	if err := rep.ValidateTxLimit()(ctx); err == nil {
+		t.Fatal("got nil, want err for unstaked paymaster")
+	}
+
+	rep.SetIsStakedFunc(isStakedMock(tx.GetPaymaster(), tx.GetDeployer()))
+	if err := rep.ValidateTxLimit()(ctx); err != nil {
+		t.Fatalf("got %v, want nil for staked paymaster", err)
+	}
+
+	repConst.SameStakedEntityMempoolCount = 2
+	if err := rep.ValidateTxLimit()(ctx); err == nil {
+		t.Fatal("got nil, want err for staked paymaster at limit")
+	}
+}
+
+// TestValidateTxLimitStakeError verifies that a failed stake lookup is returned as a JSON-RPC error.
+func TestValidateTxLimitStakeError(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	ctx, err := modules.NewTxHandlerContext(tx, big.NewInt(1), mem)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	rep := New(nil, nil, &ReputationConstants{SameSenderMempoolCount: 4, SameUnstakedEntityMempoolCount: 2})
+	rep.SetIsStakedFunc(func(entity common.Address) (bool, error) {
+		return false, stderrors.New("connection refused")
+	})
+	err = rep.ValidateTxLimit()(ctx)
+	var rpcErr *errors.RPCError
+	if !stderrors.As(err, &rpcErr) || rpcErr.Code() != errors.INVALID_ENTITY_STAKE {
+		t.Fatalf("got %v, want RPCError with code %d", err, errors.INVALID_ENTITY_STAKE)
+	}
+}
+
+// TestValidateTxLimitAtLimit verifies that an entity is allowed up to one less than its limit of pending txs,
+// that reaching the limit returns the same error code whether or not it is staked, and that a staked sender is
+// still held to the sender limit.
+func TestValidateTxLimitAtLimit(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	for i := int64(0); i < 2; i++ {
+		tx := testutils.MockValidInitRip7560Tx()
+		tx.NonceKey = (*hexutil.Big)(big.NewInt(i))
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.NonceKey = (*hexutil.Big)(big.NewInt(2))
+	ctx, err := modules.NewTxHandlerContext(tx, big.NewInt(1), mem)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	checkCode := func(err error) {
+		t.Helper()
+		var rpcErr *errors.RPCError
+		if !stderrors.As(err, &rpcErr) || rpcErr.Code() != errors.INVALID_ENTITY_STAKE {
+			t.Fatalf("got %v, want RPCError with code %d", err, errors.INVALID_ENTITY_STAKE)
+		}
+	}
+
+	repConst := &ReputationConstants{
+		SameSenderMempoolCount:         3,
+		SameUnstakedEntityMempoolCount: 3,
+		SameStakedEntityMempoolCount:   3,
+	}
+	rep := New(nil, nil, repConst)
+	if err := rep.ValidateTxLimit()(ctx); err != nil {
+		t.Fatalf("got %v, want nil below limit", err)
+	}
+
+	repConst.SameUnstakedEntityMempoolCount = 2
+	checkCode(rep.ValidateTxLimit()(ctx))
+
+	rep.SetIsStakedFunc(isStakedMock(tx.GetSender(), tx.GetDeployer(), tx.GetPaymaster()))
+	repConst.SameStakedEntityMempoolCount = 2
+	checkCode(rep.ValidateTxLimit()(ctx))
+
+	repConst.SameStakedEntityMempoolCount = 10
+	repConst.SameSenderMempoolCount = 2
+	checkCode(rep.ValidateTxLimit()(ctx))
+}
@@ -4,6 +4,15 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// IsStakedFunc returns true if an entity meets the minimum stake requirements.
+type IsStakedFunc = func(entity common.Address) (bool, error)
+
+func isStakedNoop() IsStakedFunc {
+	return func(entity common.Address) (bool, error) {
+		return false, nil
+	}
+}
+
 type ReputationOverride struct {
 	Address     common.Address `json:"address"`
 	TxsSeen     int            `json:"txsSeen"`
@@ -17,6 +26,7 @@ type ReputationConstants struct {
 	MinStakeValue                  int64
 	SameSenderMempoolCount         int
 	SameUnstakedEntityMempoolCount int
+	SameStakedEntityMempoolCount   int
 	ThrottledEntityMempoolCount    int
 	ThrottledEntityLiveBlocks      int
 	ThrottledEntityBundleCount     int
@@ -1,26 +1,35 @@
 package expire
 
 import (
-	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
 // TestDropExpired calls (*ExpireHandler).DropExpired and verifies that it marks old Rip-7560 transactions for
 // pending removal.
 func TestDropExpired(t *testing.T) {
-	exp := New(time.Second * 30)
+	mem, err := mempool.NewWithStore(mempool.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
 	tx1 := testutils.MockValidInitRip7560Tx()
 	tx2 := testutils.MockValidInitRip7560Tx()
-	*tx2.Data = common.Hex2Bytes("0xdead")
-	exp.seenAt = map[common.Hash]time.Time{
-		tx1.ToTransaction().Hash(): time.Now().Add(time.Second * -45),
-		tx2.ToTransaction().Hash(): time.Now().Add(time.Second * -15),
+	*tx2.ExecutionData = common.Hex2Bytes("dead")
+	tx2.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+	if err := mem.AddTxWithMetadata(tx1, &mempool.TxMetadata{ArrivedAt: time.Now().Add(time.Second * -45)}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddTxWithMetadata(tx2, &mempool.TxMetadata{ArrivedAt: time.Now().Add(time.Second * -15)}); err != nil {
+		t.Fatalf("got %v, want nil", err)
 	}
+	exp := New(mem, time.Second*30)
 
 	ctx := modules.NewBatchHandlerContext(
 		[]*transaction.TransactionArgs{tx1, tx2},
@@ -40,5 +49,4 @@ func TestDropExpired(t *testing.T) {
 	} else if !testutils.IsTxsEqual(ctx.PendingRemoval[0].Tx, tx1) {
 		t.Fatal("incorrect pending removal: Didn't drop bad tx")
 	}
-
 }
@@ -0,0 +1,37 @@
+package gasprice
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// getLegacyBid returns the MaxFeePerGas plus the builder fee spread over the total gas limit. This is the
+// amount per gas paid to the bundler on networks that don't support EIP-1559.
+func getLegacyBid(txArgs *transaction.TransactionArgs) *big.Int {
+	bid := new(big.Int).Set(txArgs.MaxFeePerGas.ToInt())
+	return bid.Add(bid, new(big.Int).Sub(txArgs.GetEffectiveBid(nil), txArgs.GetDynamicGasPrice(nil)))
+}
+
+// FilterBuilderFee returns a BatchHandlerFunc that will drop all the Rip7560Txs with a builder fee below the
+// given minimum. A nil minimum disables the filter.
+func FilterBuilderFee(min *big.Int) modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		if min == nil || min.Sign() <= 0 {
+			return nil
+		}
+
+		for i := len(ctx.Batch) - 1; i >= 0; i-- {
+			if fee := ctx.Batch[i].GetBuilderFee(); fee.Cmp(min) < 0 {
+				ctx.MarkTxIndexForRemoval(
+					i,
+					fmt.Sprintf("builderFee: %s is below minimum of %s", fee.String(), min.String()),
+				)
+			}
+		}
+
+		return nil
+	}
+}
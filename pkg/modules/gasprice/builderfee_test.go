@@ -0,0 +1,73 @@
+package gasprice_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestFilterBuilderFee verifies that FilterBuilderFee drops all Rip-7560 transactions from a batch with a
+// builder fee below the minimum.
+func TestFilterBuilderFee(t *testing.T) {
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx1.BuilderFee = (*hexutil.Big)(big.NewInt(9))
+
+	tx2 := testutils.MockValidInitRip7560Tx()
+	*tx2.Sender = testutils.ValidAddress2
+	tx2.BuilderFee = (*hexutil.Big)(big.NewInt(10))
+
+	tx3 := testutils.MockValidInitRip7560Tx()
+	*tx3.Sender = testutils.ValidAddress3
+	tx3.BuilderFee = nil
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*transaction.TransactionArgs{tx1, tx2, tx3},
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := gasprice.FilterBuilderFee(big.NewInt(10))(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 || !testutils.IsTxsEqual(ctx.Batch[0], tx2) {
+		t.Fatalf("got %v, want only tx2 in batch", ctx.Batch)
+	} else if len(ctx.PendingRemoval) != 2 {
+		t.Fatalf("got %d removals, want 2", len(ctx.PendingRemoval))
+	}
+}
+
+// TestSortByGasPriceBuilderFee verifies that SortByGasPrice counts the builder fee spread over the total gas
+// limit as part of the effective Gas Price.
+func TestSortByGasPriceBuilderFee(t *testing.T) {
+	bf := big.NewInt(1)
+	gas := new(big.Int).SetUint64(testutils.MockValidInitRip7560Tx().GetTotalGasLimit())
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	*tx1.MaxFeePerGas = hexutil.Big(*big.NewInt(3))
+	*tx1.MaxPriorityFeePerGas = hexutil.Big(*big.NewInt(2))
+	tx1.BuilderFee = (*hexutil.Big)(big.NewInt(0))
+
+	tx2 := testutils.MockValidInitRip7560Tx()
+	*tx2.Sender = testutils.ValidAddress2
+	*tx2.MaxFeePerGas = hexutil.Big(*big.NewInt(2))
+	*tx2.MaxPriorityFeePerGas = hexutil.Big(*big.NewInt(1))
+	tx2.BuilderFee = (*hexutil.Big)(new(big.Int).Mul(gas, big.NewInt(2)))
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*transaction.TransactionArgs{tx1, tx2},
+		testutils.ChainID,
+		bf,
+		big.NewInt(0),
+		nil,
+	)
+	if err := gasprice.SortByGasPrice()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !testutils.IsTxsEqual(ctx.Batch[0], tx2) || !testutils.IsTxsEqual(ctx.Batch[1], tx1) {
+		t.Fatal("incorrect order: want tx with higher builder fee first")
+	}
+}
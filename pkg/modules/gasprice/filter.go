@@ -1,7 +1,6 @@
 package gasprice
 
 import (
-	"github.com/stackup-wallet/stackup-bundler/internal/utils"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"math/big"
 
@@ -10,7 +9,8 @@ import (
 )
 
 // FilterUnderpriced returns a BatchHandlerFunc that will filter out all the Rip7560Txs that are below either the
-// dynamic or legacy GasPrice set in the context.
+// dynamic or legacy GasPrice set in the context. The builder fee spread over the total gas limit counts towards
+// the GasPrice but not towards the base fee.
 func FilterUnderpriced() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
 		var b []*transaction.TransactionArgs
@@ -18,11 +18,12 @@ func FilterUnderpriced() modules.BatchHandlerFunc {
 			if ctx.BaseFee != nil && ctx.BaseFee.Cmp(common.Big0) != 0 && ctx.Tip != nil {
 				gp := big.NewInt(0).Add(ctx.BaseFee, ctx.Tip)
 				dgp := txArgs.GetDynamicGasPrice(ctx.BaseFee)
-				if dgp.Cmp(gp) >= 0 {
+				bid := txArgs.GetEffectiveBid(ctx.BaseFee)
+				if dgp.Cmp(ctx.BaseFee) >= 0 && bid.Cmp(gp) >= 0 {
 					b = append(b, txArgs)
 				}
 			} else if ctx.GasPrice != nil {
-				if utils.CompareHexBigWithBig(txArgs.MaxFeePerGas, ctx.GasPrice) >= 0 {
+				if getLegacyBid(txArgs).Cmp(ctx.GasPrice) >= 0 {
 					b = append(b, txArgs)
 				}
 			}
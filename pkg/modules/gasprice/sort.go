@@ -1,23 +1,23 @@
 package gasprice
 
 import (
-	"github.com/stackup-wallet/stackup-bundler/internal/utils"
 	"sort"
 
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
 )
 
-// SortByGasPrice returns a BatchHandlerFunc that will sort the context batch by highest GasPrice first.
+// SortByGasPrice returns a BatchHandlerFunc that will sort the context batch by highest GasPrice first. The
+// builder fee spread over the total gas limit is counted as part of the GasPrice.
 func SortByGasPrice() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
 		if ctx.BaseFee != nil {
 			sort.SliceStable(ctx.Batch, func(i, j int) bool {
-				return ctx.Batch[i].GetDynamicGasPrice(ctx.BaseFee).
-					Cmp(ctx.Batch[j].GetDynamicGasPrice(ctx.BaseFee)) == 1
+				return ctx.Batch[i].GetEffectiveBid(ctx.BaseFee).
+					Cmp(ctx.Batch[j].GetEffectiveBid(ctx.BaseFee)) == 1
 			})
 		} else if ctx.GasPrice != nil {
 			sort.SliceStable(ctx.Batch, func(i, j int) bool {
-				return utils.CompareHexBigWithHexBig(ctx.Batch[i].MaxFeePerGas, ctx.Batch[j].MaxFeePerGas) == 1
+				return getLegacyBid(ctx.Batch[i]).Cmp(getLegacyBid(ctx.Batch[j])) == 1
 			})
 		}
 
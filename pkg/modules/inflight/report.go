@@ -0,0 +1,147 @@
+package inflight
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/simulation"
+)
+
+// TxOutcome is the result of a bundled transaction as reported by the sequencer.
+type TxOutcome string
+
+const (
+	// TxOutcomeIncluded is reported for transactions that were included and executed successfully.
+	TxOutcomeIncluded TxOutcome = "included"
+
+	// TxOutcomeReverted is reported for transactions that were included but reverted during execution. The
+	// entities passed validation so this counts as an inclusion.
+	TxOutcomeReverted TxOutcome = "reverted"
+
+	// TxOutcomeFailed is reported for transactions that failed validation in the sequencer. These are dropped
+	// and the responsible entity is penalized.
+	TxOutcomeFailed TxOutcome = "failed"
+
+	// TxOutcomeSkipped is reported for transactions that were left out for reasons unrelated to their validity
+	// (e.g. the block was full). These are added back to the mempool.
+	TxOutcomeSkipped TxOutcome = "skipped"
+)
+
+const (
+	// ReasonSkipped is given for transactions reinjected after being skipped by the sequencer.
+	ReasonSkipped = "skipped by sequencer"
+
+	// DropReasonPrefix is prepended to the reason given by the sequencer when a transaction fails validation.
+	DropReasonPrefix = "sequencer validation failed"
+)
+
+// TxResult is the outcome of a single bundled transaction.
+type TxResult struct {
+	Hash    common.Hash `json:"hash"`
+	Outcome TxOutcome   `json:"outcome"`
+	Reason  string      `json:"reason"`
+
+	// Entity is the entity at fault for a failed transaction. It must be one of "account", "deployer", or
+	// "paymaster" and the sender is penalized if it is empty. Reason is only used as a description.
+	Entity string `json:"entity,omitempty"`
+}
+
+// ReportError is a transaction in a report with an outcome that could not be applied.
+type ReportError struct {
+	Hash  common.Hash `json:"hash"`
+	Error string      `json:"error"`
+}
+
+// ReportSummary lists the transactions in a report that were applied, not known to the Tracker, or failed to
+// apply.
+type ReportSummary struct {
+	Applied []common.Hash  `json:"applied"`
+	Unknown []common.Hash  `json:"unknown"`
+	Errors  []*ReportError `json:"errors"`
+}
+
+// Report applies the outcomes of bundled transactions reported by the sequencer. Included and reverted
+// transactions credit the reputation of their entities, skipped ones are added back to the mempool, and
+// failed ones are dropped with the responsible entity penalized. Transactions that are not in-flight are
+// listed as unknown. The report is rejected before anything is applied if it has an unknown outcome or
+// entity. Otherwise an error applying one transaction is listed in the summary and does not stop the rest.
+func (t *Tracker) Report(results []*TxResult) (*ReportSummary, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, res := range results {
+		switch res.Outcome {
+		case TxOutcomeIncluded, TxOutcomeReverted, TxOutcomeFailed, TxOutcomeSkipped:
+		default:
+			return nil, fmt.Errorf("unknown outcome for %s: %q", res.Hash.String(), res.Outcome)
+		}
+		switch res.Entity {
+		case "", simulation.EntityAccount, simulation.EntityDeployer, simulation.EntityPaymaster:
+		default:
+			return nil, fmt.Errorf("unknown entity for %s: %q", res.Hash.String(), res.Entity)
+		}
+	}
+
+	sum := &ReportSummary{Applied: []common.Hash{}, Unknown: []common.Hash{}, Errors: []*ReportError{}}
+	for _, res := range results {
+		itx := t.mempool.GetInFlightByHash(res.Hash)
+		if itx == nil {
+			sum.Unknown = append(sum.Unknown, res.Hash)
+			continue
+		}
+
+		if err := t.apply(itx, res); err != nil {
+			t.logger.Error(err, "inflight error", "aatx_hash", res.Hash)
+			sum.Errors = append(sum.Errors, &ReportError{Hash: res.Hash, Error: err.Error()})
+			continue
+		}
+		sum.Applied = append(sum.Applied, res.Hash)
+	}
+
+	if len(sum.Unknown) > 0 {
+		t.logger.Info("reported txs not in-flight", "unknown_aatx_hashes", sum.Unknown)
+	}
+	return sum, nil
+}
+
+func (t *Tracker) apply(itx *mempool.InFlightTx, res *TxResult) error {
+	switch res.Outcome {
+	case TxOutcomeIncluded, TxOutcomeReverted:
+		if err := t.credit(itx); err != nil {
+			return err
+		}
+		if err := t.mempool.UpdateInFlight(itx); err != nil {
+			return err
+		}
+		if t.history != nil {
+			return t.history.SetReportedIncluded(itx.Hash, res.Outcome == TxOutcomeReverted)
+		}
+
+	case TxOutcomeFailed:
+		reason := DropReasonPrefix
+		if res.Reason != "" {
+			reason = fmt.Sprintf("%s: %s", DropReasonPrefix, res.Reason)
+		}
+		if err := t.mempool.DropInFlight(reason, itx); err != nil {
+			return err
+		}
+		if t.rep != nil {
+			entity := simulation.GetEntityAddress(itx.Tx, res.Entity)
+			if err := t.rep.Penalize(entity); err != nil {
+				return err
+			}
+		}
+		if t.history != nil {
+			return t.history.SetDropped(itx.Hash, reason)
+		}
+
+	case TxOutcomeSkipped:
+		if err := t.mempool.ReinjectInFlight(ReasonSkipped, itx); err != nil {
+			return err
+		}
+		return t.setReinjected(itx.Hash, ReasonSkipped)
+	}
+
+	return nil
+}
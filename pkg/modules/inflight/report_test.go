@@ -0,0 +1,185 @@
+package inflight
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func newMempoolWithInFlight(t *testing.T, txs ...*transaction.TransactionArgs) *mempool.Mempool {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	for _, tx := range txs {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if err := mem.BundleTxs(txs...); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return mem
+}
+
+// TestReportOutcomes verifies that the outcomes reported by the sequencer credit included RIP-7560
+// transactions, reinject skipped ones, and drop failed ones.
+func TestReportOutcomes(t *testing.T) {
+	included := testutils.MockValidInitRip7560Tx()
+	skipped := testutils.MockValidInitRip7560Tx()
+	*skipped.Sender = testutils.ValidAddress2
+	failed := testutils.MockValidInitRip7560Tx()
+	*failed.Sender = testutils.ValidAddress3
+	mem := newMempoolWithInFlight(t, included, skipped, failed)
+
+	unknown := common.Hash{0x01}
+	tr := New(mem, mockReceipts(map[common.Hash]*types.Receipt{}), 2)
+	sum, err := tr.Report([]*TxResult{
+		{Hash: included.ToTransaction().Hash(), Outcome: TxOutcomeIncluded},
+		{Hash: skipped.ToTransaction().Hash(), Outcome: TxOutcomeSkipped},
+		{Hash: failed.ToTransaction().Hash(), Outcome: TxOutcomeFailed, Reason: "paymaster deposit too low"},
+		{Hash: unknown, Outcome: TxOutcomeIncluded},
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(sum.Applied) != 3 || len(sum.Unknown) != 1 || sum.Unknown[0] != unknown {
+		t.Fatalf("got %v, want 3 applied and 1 unknown", sum)
+	}
+
+	if itxs := mem.GetInFlight(); len(itxs) != 1 || !itxs[0].Credited {
+		t.Fatalf("got %v, want only included tx in-flight and credited", itxs)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 || !testutils.IsTxsEqual(dump[0], skipped) {
+		t.Fatalf("got %v, want skipped tx reinjected", dump)
+	}
+	reason, _ := mem.GetDropReason(failed.ToTransaction().Hash())
+	if reason != DropReasonPrefix+": paymaster deposit too low" {
+		t.Fatalf("got reason %q, want sequencer validation failure", reason)
+	}
+}
+
+// TestReportUnknownOutcome verifies that a report with an unknown outcome is rejected without applying any
+// of its results.
+func TestReportUnknownOutcome(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	mem := newMempoolWithInFlight(t, tx)
+
+	tr := New(mem, mockReceipts(map[common.Hash]*types.Receipt{}), 2)
+	_, err := tr.Report([]*TxResult{
+		{Hash: tx.ToTransaction().Hash(), Outcome: TxOutcomeSkipped},
+		{Hash: common.Hash{0x01}, Outcome: "lost"},
+	})
+	if err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if len(mem.GetInFlight()) != 1 {
+		t.Fatal("got tx reinjected, want report not applied")
+	}
+}
+
+// TestReportUnknownEntity verifies that a report blaming an entity that is not account, deployer, or paymaster
+// is rejected without applying any of its results.
+func TestReportUnknownEntity(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	mem := newMempoolWithInFlight(t, tx)
+
+	tr := New(mem, mockReceipts(map[common.Hash]*types.Receipt{}), 2)
+	_, err := tr.Report([]*TxResult{
+		{Hash: tx.ToTransaction().Hash(), Outcome: TxOutcomeFailed, Entity: "bundler"},
+	})
+	if err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if len(mem.GetInFlight()) != 1 {
+		t.Fatal("got tx dropped, want report not applied")
+	}
+}
+
+// TestCheckCreditsOnce verifies that a RIP-7560 transaction is credited once when its receipt is first seen
+// even if it was already reported as included.
+func TestCheckCreditsOnce(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Nonce = (*hexutil.Uint64)(&testutils.DummyNonce1)
+	mem := newMempoolWithInFlight(t, tx)
+	hash := tx.ToTransaction().Hash()
+
+	receipts := map[common.Hash]*types.Receipt{
+		hash: {Status: types.ReceiptStatusSuccessful, BlockHash: common.Hash{0x01}, BlockNumber: big.NewInt(10)},
+	}
+	tr := New(mem, mockReceipts(receipts), 2)
+	if err := tr.Check(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if itx := mem.GetInFlightByHash(hash); itx == nil || !itx.Credited {
+		t.Fatalf("got %v, want credited in-flight tx", itx)
+	}
+}
+
+// TestReportKeepsIncludedBlock verifies that reporting a RIP-7560 transaction as included does not overwrite
+// the block it was seen in.
+func TestReportKeepsIncludedBlock(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	hist := history.New(db, 0)
+
+	tx := testutils.MockValidInitRip7560Tx()
+	mem := newMempoolWithInFlight(t, tx)
+	hash := tx.ToTransaction().Hash()
+	err := hist.Add(&history.Bundle{
+		Txs:      []*history.BundleTx{{Hash: hash, Status: history.TxStatusServed}},
+		ServedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	receipts := map[common.Hash]*types.Receipt{
+		hash: {Status: types.ReceiptStatusSuccessful, BlockHash: common.Hash{0x01}, BlockNumber: big.NewInt(10)},
+	}
+	tr := New(mem, mockReceipts(receipts), 2)
+	tr.UseHistory(hist)
+	if err := tr.Check(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, err := tr.Report([]*TxResult{{Hash: hash, Outcome: TxOutcomeIncluded}}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	b, _ := hist.GetByTxHash(hash)
+	if b.Txs[0].Status != history.TxStatusIncluded || b.Txs[0].IncludedBlock != 10 {
+		t.Fatalf("got %+v, want included in block 10", b.Txs[0])
+	}
+}
+
+// TestReportContinuesAfterError verifies that an error applying the outcome of one RIP-7560 transaction is
+// listed in the summary and does not stop the rest of the report.
+func TestReportContinuesAfterError(t *testing.T) {
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	*tx2.Sender = testutils.ValidAddress2
+	mem := newMempoolWithInFlight(t, tx1, tx2)
+
+	db := testutils.DBMock()
+	hist := history.New(db, 0)
+	db.Close()
+
+	tr := New(mem, mockReceipts(map[common.Hash]*types.Receipt{}), 2)
+	tr.UseHistory(hist)
+	sum, err := tr.Report([]*TxResult{
+		{Hash: tx1.ToTransaction().Hash(), Outcome: TxOutcomeSkipped},
+		{Hash: tx2.ToTransaction().Hash(), Outcome: TxOutcomeSkipped},
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(sum.Errors) != 2 || len(sum.Applied) != 0 {
+		t.Fatalf("got %v, want 2 errors", sum)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 2 {
+		t.Fatalf("got mempool length %d, want 2", len(dump))
+	}
+}
@@ -0,0 +1,191 @@
+// Package inflight implements a background process that confirms bundled transactions against receipts and
+// adds them back to the mempool if they are not included or are removed by a reorg.
+package inflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
+)
+
+const (
+	// ReasonNotIncluded is given for transactions reinjected after not being included within the block limit.
+	ReasonNotIncluded = "not included"
+
+	// ReasonReorged is given for transactions reinjected after their block was removed by a reorg.
+	ReasonReorged = "reorged out"
+)
+
+// GetReceiptFunc returns the receipt for a transaction hash or nil if the transaction has not been mined.
+type GetReceiptFunc = func(hash common.Hash) (*types.Receipt, error)
+
+// GetReceiptWithEthClient returns a GetReceiptFunc that relies on an eth client.
+func GetReceiptWithEthClient(eth *ethclient.Client) GetReceiptFunc {
+	return func(hash common.Hash) (*types.Receipt, error) {
+		receipt, err := eth.TransactionReceipt(context.Background(), hash)
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, nil
+		}
+		return receipt, err
+	}
+}
+
+// Tracker follows in-flight transactions until they are final. It is safe to call Check and Report at the
+// same time.
+type Tracker struct {
+	mu        sync.Mutex
+	mempool   *mempool.Mempool
+	history   *history.History
+	rep       *entities.Reputation
+	gr        GetReceiptFunc
+	maxBlocks uint64
+	logger    logr.Logger
+}
+
+// New returns a Tracker that reinjects transactions not included within maxBlocks of being served. Included
+// transactions are also watched for maxBlocks after inclusion in case of a reorg.
+func New(
+	mempool *mempool.Mempool,
+	gr GetReceiptFunc,
+	maxBlocks uint64,
+) *Tracker {
+	return &Tracker{
+		mempool:   mempool,
+		gr:        gr,
+		maxBlocks: maxBlocks,
+		logger:    logger.NewZeroLogr().WithName("inflight"),
+	}
+}
+
+// UseLogger defines the logger object used by the Tracker instance based on the go-logr/logr interface.
+func (t *Tracker) UseLogger(logger logr.Logger) {
+	t.logger = logger.WithName("inflight")
+}
+
+// UseHistory defines the History instance that is updated with the outcome of every in-flight transaction.
+func (t *Tracker) UseHistory(h *history.History) {
+	t.history = h
+}
+
+// UseReputation defines the Reputation instance that is credited when an in-flight transaction is included
+// and penalized when the sequencer reports a failure.
+func (t *Tracker) UseReputation(rep *entities.Reputation) {
+	t.rep = rep
+}
+
+// credit increments the txsIncluded counters for the entities of an in-flight transaction once. The caller
+// must save the in-flight transaction afterwards.
+func (t *Tracker) credit(itx *mempool.InFlightTx) error {
+	if itx.Credited {
+		return nil
+	}
+	if t.rep != nil {
+		if err := t.rep.IncTxsIncludedFor(itx.Tx); err != nil {
+			return err
+		}
+	}
+	itx.Credited = true
+	return nil
+}
+
+// Check updates every in-flight transaction given the latest block number. It can be used as a
+// blocks.NewHeadFunc.
+func (t *Tracker) Check(bn uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	final := []common.Hash{}
+	ri := []string{}
+	rr := []string{}
+	for _, itx := range t.mempool.GetInFlight() {
+		receipt, err := t.gr(itx.Hash)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case receipt != nil:
+			if receipt.BlockHash != itx.IncludedBlockHash {
+				itx.IncludedBlock = receipt.BlockNumber.Uint64()
+				itx.IncludedBlockHash = receipt.BlockHash
+				if err := t.credit(itx); err != nil {
+					return err
+				}
+				if err := t.mempool.UpdateInFlight(itx); err != nil {
+					return err
+				}
+				if t.history != nil {
+					reverted := receipt.Status == types.ReceiptStatusFailed
+					err := t.history.SetIncluded(itx.Hash, itx.IncludedBlock, itx.IncludedBlockHash, reverted)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if bn >= itx.IncludedBlock+t.maxBlocks {
+				final = append(final, itx.Hash)
+			}
+
+		case itx.IncludedBlockHash != (common.Hash{}):
+			if err := t.mempool.ReinjectInFlight(ReasonReorged, itx); err != nil {
+				return err
+			}
+			if err := t.setReinjected(itx.Hash, ReasonReorged); err != nil {
+				return err
+			}
+			ri = append(ri, itx.Hash.String())
+			rr = append(rr, ReasonReorged)
+
+		case itx.ServedBlock == 0:
+			itx.ServedBlock = bn
+			if err := t.mempool.UpdateInFlight(itx); err != nil {
+				return err
+			}
+
+		case bn >= itx.ServedBlock+t.maxBlocks:
+			if err := t.mempool.ReinjectInFlight(ReasonNotIncluded, itx); err != nil {
+				return err
+			}
+			if err := t.setReinjected(itx.Hash, ReasonNotIncluded); err != nil {
+				return err
+			}
+			ri = append(ri, itx.Hash.String())
+			rr = append(rr, ReasonNotIncluded)
+		}
+	}
+
+	if len(ri) > 0 {
+		t.logger.Info("reinjected in-flight txs", "reinjected_aatx_hashes", ri, "reinjected_aatx_reasons", rr)
+	}
+	if len(final) == 0 {
+		return nil
+	}
+	if err := t.mempool.FinalizeInFlight(final...); err != nil {
+		return err
+	}
+	if t.history != nil {
+		for _, hash := range final {
+			if err := t.history.SetFinal(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) setReinjected(hash common.Hash, reason string) error {
+	if t.history == nil {
+		return nil
+	}
+	return t.history.SetReinjected(hash, reason)
+}
@@ -0,0 +1,147 @@
+package inflight
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/history"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+)
+
+func mockReceipts(receipts map[common.Hash]*types.Receipt) GetReceiptFunc {
+	return func(hash common.Hash) (*types.Receipt, error) {
+		return receipts[hash], nil
+	}
+}
+
+// TestReinjectNotIncluded verifies that a bundled RIP-7560 transaction is added back to the mempool if it is
+// not included within the block limit.
+func TestReinjectNotIncluded(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.BundleTxs(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	tr := New(mem, mockReceipts(map[common.Hash]*types.Receipt{}), 2)
+	for _, bn := range []uint64{10, 11} {
+		if err := tr.Check(bn); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		if dump, _ := mem.Dump(); len(dump) != 0 {
+			t.Fatalf("block %d: got mempool length %d, want 0", bn, len(dump))
+		}
+	}
+
+	if err := tr.Check(12); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 || !testutils.IsTxsEqual(dump[0], tx) {
+		t.Fatalf("got %v, want reinjected tx", dump)
+	}
+	if len(mem.GetInFlight()) != 0 {
+		t.Fatalf("got in-flight length %d, want 0", len(mem.GetInFlight()))
+	}
+}
+
+// TestReinjectReorged verifies that an included RIP-7560 transaction is added back to the mempool if its
+// receipt disappears and is no longer tracked once final. It is not credited again when it is re-included.
+func TestReinjectReorged(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	hash := tx.ToTransaction().Hash()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	arrivedAt := mem.GetMetadata(tx).ArrivedAt
+	if err := mem.BundleTxs(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	receipts := map[common.Hash]*types.Receipt{
+		hash: {Status: types.ReceiptStatusSuccessful, BlockHash: common.Hash{0x01}, BlockNumber: big.NewInt(10)},
+	}
+	tr := New(mem, mockReceipts(receipts), 2)
+	if err := tr.Check(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if itxs := mem.GetInFlight(); len(itxs) != 1 || itxs[0].IncludedBlock != 10 {
+		t.Fatalf("got %v, want tx included in block 10", itxs)
+	}
+
+	delete(receipts, hash)
+	if err := tr.Check(11); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dump, _ := mem.Dump(); len(dump) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(dump))
+	}
+	if meta := mem.GetMetadata(tx); !meta.Credited || !meta.ArrivedAt.After(arrivedAt) {
+		t.Fatalf("got metadata %+v, want credited and arrival time reset", meta)
+	}
+
+	if err := mem.BundleTxs(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if itxs := mem.GetInFlight(); len(itxs) != 1 || !itxs[0].Credited {
+		t.Fatalf("got %v, want credited in-flight tx", itxs)
+	}
+	receipts[hash] = &types.Receipt{BlockHash: common.Hash{0x02}, BlockNumber: big.NewInt(12)}
+	if err := tr.Check(14); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(mem.GetInFlight()) != 0 {
+		t.Fatalf("got in-flight length %d, want 0", len(mem.GetInFlight()))
+	}
+}
+
+// TestHistoryOutcome verifies that the Tracker saves the inclusion outcome of a bundled RIP-7560 transaction
+// to the bundle history.
+func TestHistoryOutcome(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	hist := history.New(db, 0)
+
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	hash := tx.ToTransaction().Hash()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.BundleTxs(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	err := hist.Add(&history.Bundle{
+		Txs:      []*history.BundleTx{{Hash: hash, Status: history.TxStatusServed}},
+		ServedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	receipts := map[common.Hash]*types.Receipt{
+		hash: {Status: types.ReceiptStatusSuccessful, BlockHash: common.Hash{0x01}, BlockNumber: big.NewInt(10)},
+	}
+	tr := New(mem, mockReceipts(receipts), 2)
+	tr.UseHistory(hist)
+	if err := tr.Check(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if b, _ := hist.GetByTxHash(hash); b.Txs[0].Status != history.TxStatusIncluded {
+		t.Fatalf("got %s, want included", b.Txs[0].Status)
+	}
+
+	if err := tr.Check(12); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if b, _ := hist.GetByTxHash(hash); b.Txs[0].Status != history.TxStatusFinal {
+		t.Fatalf("got %s, want final", b.Txs[0].Status)
+	}
+}
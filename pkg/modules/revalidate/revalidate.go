@@ -0,0 +1,248 @@
+// Package revalidate implements a background process that re-simulates transactions in the mempool whenever
+// a new block is seen and drops the ones that are no longer valid.
+package revalidate
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-logr/logr"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DropReasonPrefix is prepended to the simulation error when recording why a transaction was dropped.
+	DropReasonPrefix = "revalidation failed"
+
+	// ExpiryMargin is how long a transaction must remain valid for after revalidation in order to be kept.
+	ExpiryMargin = 30 * time.Second
+)
+
+// Result is the validity window returned by a transaction that passed revalidation.
+type Result struct {
+	ValidAfter uint64
+	ValidUntil uint64
+}
+
+// ValidateFunc re-runs validation for a transaction against the latest state. A non-nil error means the
+// transaction is no longer valid, unless it is an errors.TransportError in which case the result is unknown.
+// Errors attributed to an entity other than the sender are returned as a simulation.EntityError.
+type ValidateFunc = func(tx *transaction.TransactionArgs) (*Result, error)
+
+// TraceFunc traces validation for a transaction against the latest state and applies the ERC-7562 rules. Rule
+// violations and reverts are returned as a simulation.EntityError.
+type TraceFunc = func(tx *transaction.TransactionArgs) error
+
+// getPayerEntity returns the known entity that pays for tx.
+func getPayerEntity(tx *transaction.TransactionArgs) string {
+	if tx.GetPaymaster() != (common.Address{}) {
+		return simulation.EntityPaymaster
+	}
+	return simulation.EntityAccount
+}
+
+// ValidateWithRpcClient returns a ValidateFunc that calls eth_callRip7560Validation and the given trace
+// function, and checks that neither the sender nor the paymaster expire the transaction within the
+// ExpiryMargin. A failure that the trace does not attribute to an entity is charged to the payer if it can no
+// longer cover the maximum cost of the transaction, and to the sender otherwise. The trace function can be
+// nil, in which case the ERC-7562 rules are not checked.
+func ValidateWithRpcClient(rpc *rpc.Client, trace TraceFunc) ValidateFunc {
+	eth := ethclient.NewClient(rpc)
+	return func(tx *transaction.TransactionArgs) (*Result, error) {
+		sim, err := simulation.SimulateValidation(rpc, tx)
+		if errors.IsTransportError(err) {
+			return nil, err
+		}
+		if trace != nil {
+			var ee *simulation.EntityError
+			if terr := trace(tx); errors.IsTransportError(terr) || stderrors.As(terr, &ee) {
+				return nil, terr
+			} else if terr != nil && err == nil {
+				return nil, terr
+			}
+		}
+		if err != nil {
+			payer := simulation.GetEntityAddress(tx, getPayerEntity(tx))
+			bal, berr := eth.BalanceAt(context.Background(), payer, nil)
+			if berr != nil {
+				return nil, errors.WrapTransportError(berr)
+			}
+			if bal.Cmp(tx.GetMaxCost()) < 0 {
+				return nil, &simulation.EntityError{Entity: getPayerEntity(tx), Err: err}
+			}
+			return nil, err
+		}
+
+		validAfter, validUntil := simulation.GetValidityWindow(sim)
+		if validUntil != 0 && uint64(time.Now().Add(ExpiryMargin).Unix()) >= validUntil {
+			entity := simulation.EntityAccount
+			if sim.PmValidUntil == validUntil {
+				entity = simulation.EntityPaymaster
+			}
+			return nil, &simulation.EntityError{Entity: entity, Err: fmt.Errorf("%s expires too soon", entity)}
+		}
+		return &Result{ValidAfter: validAfter, ValidUntil: validUntil}, nil
+	}
+}
+
+// Revalidator re-simulates all pending transactions in the mempool on every new block.
+type Revalidator struct {
+	mempool        *mempool.Mempool
+	rep            *entities.Reputation
+	validate       ValidateFunc
+	maxConcurrency int
+	logger         logr.Logger
+}
+
+// New returns a Revalidator that runs at most maxConcurrency simulations at the same time.
+func New(
+	mempool *mempool.Mempool,
+	validate ValidateFunc,
+	maxConcurrency int,
+) *Revalidator {
+	return &Revalidator{
+		mempool:        mempool,
+		validate:       validate,
+		maxConcurrency: maxConcurrency,
+		logger:         logger.NewZeroLogr().WithName("revalidator"),
+	}
+}
+
+// UseLogger defines the logger object used by the Revalidator instance based on the go-logr/logr interface.
+func (r *Revalidator) UseLogger(logger logr.Logger) {
+	r.logger = logger.WithName("revalidator")
+}
+
+// UseReputation defines the Reputation instance used to penalize entities responsible for transactions that
+// fail revalidation.
+func (r *Revalidator) UseReputation(rep *entities.Reputation) {
+	r.rep = rep
+}
+
+// lane is the pending transactions of a single sender and nonce key in ascending nonce order.
+type lane []*transaction.TransactionArgs
+
+// getLanes groups transactions by sender and nonce key. Only the first transaction of each lane can be
+// validated against the latest state since later nonces depend on the execution of the earlier ones.
+func getLanes(txs []*transaction.TransactionArgs) []lane {
+	idx := make(map[string]int)
+	lanes := []lane{}
+	for _, tx := range txs {
+		lk := tx.GetSender().Hex() + tx.GetNonceKey().String()
+		i, ok := idx[lk]
+		if !ok {
+			i = len(lanes)
+			idx[lk] = i
+			lanes = append(lanes, lane{})
+		}
+		lanes[i] = append(lanes[i], tx)
+	}
+	for _, l := range lanes {
+		sort.SliceStable(l, func(i, j int) bool { return l[i].GetNonce() < l[j].GetNonce() })
+	}
+	return lanes
+}
+
+// Revalidate promotes queued transactions that have become executable since the last block and then runs
+// validation for the lowest pending nonce of every sender and nonce key in the mempool. If it fails, the
+// transaction is dropped, the responsible entity is penalized, and the rest of its lane is moved to the queued
+// sub-pool. Otherwise the revalidation count in the metadata of every transaction in the lane is incremented
+// and the validity window of the first one is refreshed. The metadata of all passing lanes is saved in one
+// Store update per call. Lanes that could not be validated due to an errors.TransportError are left unchanged.
+func (r *Revalidator) Revalidate() error {
+	if err := r.mempool.PromoteQueued(); err != nil {
+		return err
+	}
+	txs, err := r.mempool.Dump()
+	if err != nil {
+		return err
+	}
+	lanes := getLanes(txs)
+
+	results := make([]*Result, len(lanes))
+	errs := make([]error, len(lanes))
+	g := new(errgroup.Group)
+	if r.maxConcurrency > 0 {
+		g.SetLimit(r.maxConcurrency)
+	}
+	for i, l := range lanes {
+		i, head := i, l[0]
+		g.Go(func() error {
+			results[i], errs[i] = r.validate(head)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	valid := []*transaction.TransactionArgs{}
+	windows := make(map[*transaction.TransactionArgs]*Result)
+	dh := []string{}
+	dr := []string{}
+	skipped := 0
+	for i, l := range lanes {
+		if errs[i] == nil {
+			valid = append(valid, l...)
+			windows[l[0]] = results[i]
+			continue
+		}
+		if errors.IsTransportError(errs[i]) {
+			skipped++
+			continue
+		}
+
+		head := l[0]
+		reason := fmt.Sprintf("%s: %s", DropReasonPrefix, errs[i])
+		if err := r.mempool.DropTxs(reason, head); err != nil {
+			return err
+		}
+		if r.rep != nil {
+			if err := r.rep.Penalize(simulation.GetResponsibleEntity(head, errs[i])); err != nil {
+				return err
+			}
+		}
+		dh = append(dh, head.ToTransaction().Hash().String())
+		dr = append(dr, reason)
+	}
+
+	now := time.Now()
+	err = r.mempool.UpdateMetadata(func(tx *transaction.TransactionArgs, meta *mempool.TxMetadata) {
+		meta.RevalidationCount++
+		meta.LastValidatedAt = now
+		if w, ok := windows[tx]; ok && w != nil {
+			meta.ValidAfter, meta.ValidUntil = w.ValidAfter, w.ValidUntil
+		}
+	}, valid...)
+	if err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		r.logger.Info("revalidation skipped lanes after transport errors", "skipped_lanes", skipped)
+	}
+	if len(dh) > 0 {
+		r.logger.Info(
+			"revalidation dropped txs",
+			"dropped_aatx_hashes", dh,
+			"dropped_aatx_reasons", dr,
+		)
+	}
+	return nil
+}
+
+// OnNewHead revalidates the mempool for a new block. It is used as a blocks.NewHeadFunc so that the block is
+// retried if revalidation fails.
+func (r *Revalidator) OnNewHead(bn uint64) error {
+	return r.Revalidate()
+}
@@ -0,0 +1,175 @@
+package revalidate
+
+import (
+	stderrors "errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// TestRevalidateDropsInvalidTxs calls (*Revalidator).Revalidate and verifies that only RIP-7560 transactions
+// that fail validation are dropped from the mempool with a reason.
+func TestRevalidateDropsInvalidTxs(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	tx2.Sender = &common.Address{0x01}
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	bad := tx2.GetSender()
+	validate := func(tx *transaction.TransactionArgs) (*Result, error) {
+		if tx.GetSender() == bad {
+			return nil, stderrors.New("insufficient funds")
+		}
+		return &Result{}, nil
+	}
+	rev := New(mem, validate, 1)
+	if err := rev.Revalidate(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	dump, _ := mem.Dump()
+	if len(dump) != 1 || !testutils.IsTxsEqual(dump[0], tx1) {
+		t.Fatalf("got %v, want only valid tx", dump)
+	}
+	reason, _ := mem.GetDropReason(tx2.ToTransaction().Hash())
+	if reason != DropReasonPrefix+": insufficient funds" {
+		t.Fatalf("got reason %q, want revalidation failure", reason)
+	}
+}
+
+// TestRevalidateOnlyLaneHeads verifies that only the lowest nonce of each sender and nonce key is validated and
+// that later nonces are kept while it is valid.
+func TestRevalidateOnlyLaneHeads(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	mem.SetGetNonceFunc(func(sender common.Address, key *big.Int) (uint64, error) { return 0, nil })
+
+	tx1 := testutils.MockValidInitRip7560Tx()
+	tx2 := testutils.MockValidInitRip7560Tx()
+	nonce := hexutil.Uint64(1)
+	tx2.Nonce = &nonce
+	for _, tx := range []*transaction.TransactionArgs{tx1, tx2} {
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	validate := func(tx *transaction.TransactionArgs) (*Result, error) {
+		if tx.GetNonce() != 0 {
+			return nil, stderrors.New("invalid nonce")
+		}
+		return &Result{}, nil
+	}
+	rev := New(mem, validate, 1)
+	if err := rev.Revalidate(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if dump, _ := mem.Dump(); len(dump) != 2 {
+		t.Fatalf("got length %d, want 2", len(dump))
+	}
+	if meta := mem.GetMetadata(tx2); meta == nil || meta.RevalidationCount != 1 {
+		t.Fatalf("got metadata %v, want revalidation count 1", meta)
+	}
+}
+
+// TestRevalidateSkipsTransportErrors verifies that a transaction is not dropped if validation could not reach
+// the node.
+func TestRevalidateSkipsTransportErrors(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	validate := func(tx *transaction.TransactionArgs) (*Result, error) {
+		return nil, errors.WrapTransportError(stderrors.New("connection refused"))
+	}
+	rev := New(mem, validate, 1)
+	if err := rev.Revalidate(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if dump, _ := mem.Dump(); len(dump) != 1 {
+		t.Fatalf("got length %d, want 1", len(dump))
+	}
+	if meta := mem.GetMetadata(tx); meta == nil || meta.RevalidationCount != 0 {
+		t.Fatalf("got metadata %v, want revalidation count 0", meta)
+	}
+}
+
+type countingStore struct {
+	*mempool.MemoryStore
+	putTxs int
+}
+
+func (s *countingStore) Update(u *mempool.StoreUpdate) error {
+	if len(u.PutTxs) > 0 {
+		s.putTxs++
+	}
+	return s.MemoryStore.Update(u)
+}
+
+// TestRevalidateSavesMetadataOnce verifies that the metadata of every valid lane is saved in a single Store
+// update.
+func TestRevalidateSavesMetadataOnce(t *testing.T) {
+	store := &countingStore{MemoryStore: mempool.NewMemoryStore()}
+	mem, _ := mempool.NewWithStore(store)
+
+	senders := []common.Address{{0x01}, {0x02}, {0x03}}
+	for _, sender := range senders {
+		tx := testutils.MockValidInitRip7560Tx()
+		*tx.Sender = sender
+		if err := mem.AddTx(tx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	store.putTxs = 0
+	validate := func(tx *transaction.TransactionArgs) (*Result, error) { return &Result{}, nil }
+	rev := New(mem, validate, 1)
+	if err := rev.Revalidate(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if store.putTxs != 1 {
+		t.Fatalf("got %d store updates, want 1", store.putTxs)
+	}
+	txs, _ := mem.Dump()
+	for _, tx := range txs {
+		if meta := mem.GetMetadata(tx); meta == nil || meta.RevalidationCount != 1 {
+			t.Fatalf("got metadata %v, want revalidation count 1", meta)
+		}
+	}
+}
+
+// TestRevalidateRefreshesValidity verifies that the validity window of a lane head is refreshed from the
+// revalidation result.
+func TestRevalidateRefreshesValidity(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	validate := func(tx *transaction.TransactionArgs) (*Result, error) {
+		return &Result{ValidAfter: 10, ValidUntil: 20}, nil
+	}
+	rev := New(mem, validate, 1)
+	if err := rev.Revalidate(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if meta := mem.GetMetadata(tx); meta == nil || meta.ValidAfter != 10 || meta.ValidUntil != 20 {
+		t.Fatalf("got metadata %v, want validity window 10 to 20", meta)
+	}
+}
@@ -0,0 +1,84 @@
+package methods
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	// GetDepositInfoMethod reads the DepositInfo struct of an entity from the stake manager. The struct only
+	// has static fields so it is encoded the same as its fields in order.
+	GetDepositInfoMethod = abi.NewMethod(
+		"getDepositInfo",
+		"getDepositInfo",
+		abi.Function,
+		"view",
+		false,
+		false,
+		abi.Arguments{
+			{Name: "account", Type: address},
+		},
+		abi.Arguments{
+			{Name: "deposit", Type: uint256},
+			{Name: "staked", Type: boolean},
+			{Name: "stake", Type: uint112},
+			{Name: "unstakeDelaySec", Type: uint32T},
+			{Name: "withdrawTime", Type: uint48},
+		},
+	)
+	GetDepositInfoSelector = hexutil.Encode(GetDepositInfoMethod.ID)
+)
+
+type getDepositInfoOutput struct {
+	Deposit         *big.Int
+	Staked          bool
+	Stake           *big.Int
+	UnstakeDelaySec uint32
+	WithdrawTime    uint64
+}
+
+func DecodeGetDepositInfoOutput(data []byte) (*getDepositInfoOutput, error) {
+	args, err := GetDepositInfoMethod.Outputs.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("getDepositInfoOutput: %s", err)
+	}
+	if len(args) != 5 {
+		return nil, fmt.Errorf(
+			"getDepositInfoOutput: invalid args length: expected 5, got %d",
+			len(args),
+		)
+	}
+
+	deposit, ok := args[0].(*big.Int)
+	if !ok {
+		return nil, errors.New("getDepositInfoOutput: cannot assert type: deposit is not of type *big.Int")
+	}
+	staked, ok := args[1].(bool)
+	if !ok {
+		return nil, errors.New("getDepositInfoOutput: cannot assert type: staked is not of type bool")
+	}
+	stake, ok := args[2].(*big.Int)
+	if !ok {
+		return nil, errors.New("getDepositInfoOutput: cannot assert type: stake is not of type *big.Int")
+	}
+	unstakeDelaySec, ok := args[3].(uint32)
+	if !ok {
+		return nil, errors.New("getDepositInfoOutput: cannot assert type: unstakeDelaySec is not of type uint32")
+	}
+	withdrawTime, ok := args[4].(*big.Int)
+	if !ok {
+		return nil, errors.New("getDepositInfoOutput: cannot assert type: withdrawTime is not of type *big.Int")
+	}
+
+	return &getDepositInfoOutput{
+		Deposit:         deposit,
+		Staked:          staked,
+		Stake:           stake,
+		UnstakeDelaySec: unstakeDelaySec,
+		WithdrawTime:    withdrawTime.Uint64(),
+	}, nil
+}
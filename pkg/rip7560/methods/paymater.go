@@ -46,9 +46,9 @@ func DecodevalidatePaymasterTransactionOutputOutput(ret any) (*validatePaymaster
 	if err != nil {
 		return nil, fmt.Errorf("validatePaymasterTransactionOutput: %s", err)
 	}
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return nil, fmt.Errorf(
-			"validatePaymasterTransactionOutput: invalid args length: expected 2, got %d",
+			"validatePaymasterTransactionOutput: invalid args length: expected 1, got %d",
 			len(args),
 		)
 	}
@@ -9,4 +9,8 @@ var (
 	uint256, _ = abi.NewType("uint256", "", nil)
 	bytes, _   = abi.NewType("bytes", "", nil)
 	address, _ = abi.NewType("address", "", nil)
+	boolean, _ = abi.NewType("bool", "", nil)
+	uint32T, _ = abi.NewType("uint32", "", nil)
+	uint48, _  = abi.NewType("uint48", "", nil)
+	uint112, _ = abi.NewType("uint112", "", nil)
 )
@@ -0,0 +1,52 @@
+// Package nonce provides methods for reading the current RIP-7712 nonce of an account.
+package nonce
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stackup-wallet/stackup-bundler/internal/config"
+)
+
+// ErrInvalidNonceResult is returned when the NonceManager does not return a 32 byte nonce.
+var ErrInvalidNonceResult = errors.New("nonce: invalid result from NonceManager")
+
+// GetNonceFunc provides a general interface for retrieving the next expected nonce for a sender and RIP-7712
+// nonce key.
+type GetNonceFunc = func(sender common.Address, key *big.Int) (uint64, error)
+
+// GetNonceCallData returns the calldata for reading a nonce from the NonceManager. This is the sender address
+// followed by the 24 byte nonce key.
+func GetNonceCallData(sender common.Address, key *big.Int) []byte {
+	return append(sender.Bytes(), common.LeftPadBytes(key.Bytes(), 24)...)
+}
+
+// GetNonceWithEthClient returns a GetNonceFunc that calls the NonceManager using an eth client. The
+// NonceManager returns the key in the upper 192 bits and the sequence in the lower 64 bits. Nonce key 0 uses the
+// legacy nonce of the account as defined in RIP-7712 and is read from the account state instead.
+func GetNonceWithEthClient(eth *ethclient.Client) GetNonceFunc {
+	return func(sender common.Address, key *big.Int) (uint64, error) {
+		if key.Sign() == 0 {
+			return eth.NonceAt(context.Background(), sender, nil)
+		}
+
+		msg := ethereum.CallMsg{
+			To:   &config.NonceManagerAddress,
+			Data: GetNonceCallData(sender, key),
+		}
+		res, err := eth.CallContract(context.Background(), msg, nil)
+		if err != nil {
+			return 0, err
+		}
+		if len(res) != 32 {
+			return 0, ErrInvalidNonceResult
+		}
+
+		return binary.BigEndian.Uint64(res[24:]), nil
+	}
+}
@@ -0,0 +1,36 @@
+package nonce
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestGetNonceWithEthClient verifies that nonce key 0 reads the legacy account nonce and any other key reads
+// the NonceManager.
+func TestGetNonceWithEthClient(t *testing.T) {
+	srv := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getTransactionCount": "0x5",
+		"eth_call":                "0x" + common.Bytes2Hex(common.LeftPadBytes([]byte{0x07}, 32)),
+	})
+	defer srv.Close()
+	eth, err := ethclient.Dial(srv.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	gn := GetNonceWithEthClient(eth)
+
+	if n, err := gn(testutils.ValidAddress1, big.NewInt(0)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if n != 5 {
+		t.Fatalf("got %d, want 5", n)
+	}
+	if n, err := gn(testutils.ValidAddress1, big.NewInt(1)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if n != 7 {
+		t.Fatalf("got %d, want 7", n)
+	}
+}
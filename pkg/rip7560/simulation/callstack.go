@@ -1,12 +1,14 @@
 package simulation
 
 import (
-	"encoding/hex"
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/stackup-wallet/stackup-bundler/internal/utils"
-	"math/big"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 )
 
 type callEntry struct {
@@ -17,35 +19,91 @@ type callEntry struct {
 	Method string
 	Revert any
 	Return any
+
+	// Depth is the number of frames that enclose the call.
+	Depth int
+}
+
+// decodeRevertReason returns a readable reason from the output of a reverted frame. Output that is neither
+// an Error(string) nor a Panic(uint256) is returned as hex.
+func decodeRevertReason(data []byte) string {
+	if reason, err := errors.DecodeRevert(data); err == nil {
+		return reason
+	}
+	if code, err := errors.DecodePanic(data); err == nil {
+		return "panic code " + code
+	}
+	return hexutil.Encode(data)
 }
 
+// newCallStack pairs each call frame with the REVERT or RETURN that exits it. Calls are listed in the order
+// that they exit. Method is the hex encoded selector of the call and Return is the hex encoded output. CREATE
+// and CREATE2 entries have To set to the created address and Return set to the length of the deployed code.
+// Reverted entries have Revert set to the decoded reason.
 func newCallStack(calls []native.CallFrame) []*callEntry {
 	var out []*callEntry
 	stack := utils.NewStack[native.CallFrame]()
+	depth := 0
 	for _, call := range calls {
 		if call.Type == vm.REVERT || call.Type == vm.RETURN {
-			top, _ := stack.Pop()
-
-			if top.Type == vm.CREATE {
-				// TODO: implement
-			} else if top.Type == vm.CREATE2 {
-				// TODO: implement
-			} else if call.Type == vm.REVERT {
-				// TODO: implement
+			top, ok := stack.Pop()
+			if !ok {
+				continue
+			}
+			depth--
+
+			entry := &callEntry{
+				From:  top.From,
+				Value: top.Value,
+				Type:  top.Type,
+				Depth: depth,
+			}
+			if top.To != nil {
+				entry.To = *top.To
+			}
+			if entry.Value == nil {
+				entry.Value = big.NewInt(0)
+			}
+
+			if call.Type == vm.REVERT {
+				entry.Revert = decodeRevertReason(call.Output)
+			} else if top.Type == vm.CREATE || top.Type == vm.CREATE2 {
+				entry.Return = len(call.Output)
 			} else {
-				out = append(out, &callEntry{
-					From:   top.From,
-					To:     *top.To,
-					Value:  top.Value,
-					Type:   top.Type,
-					Method: hex.EncodeToString(top.Input),
-					Return: call.Output,
-				})
+				entry.Return = hexutil.Encode(call.Output)
+			}
+			if top.Type != vm.CREATE && top.Type != vm.CREATE2 && len(top.Input) >= 4 {
+				entry.Method = hexutil.Encode(top.Input[:4])
 			}
+			out = append(out, entry)
 		} else {
 			stack.Push(call)
+			depth++
 		}
 	}
 
 	return out
 }
+
+// getParent returns the index of the entry that encloses the one at index i, or -1 if there is none. Entries
+// are listed in the order that they exit, so this is the first entry after i with a lower depth.
+func getParent(callStack []*callEntry, i int) int {
+	for j := i + 1; j < len(callStack); j++ {
+		if callStack[j].Depth < callStack[i].Depth {
+			return j
+		}
+	}
+	return -1
+}
+
+// getDeepestRevert follows the reverted entry at index i down to the deepest frame that caused it. At each
+// level this is the last reverted frame it called, which is the one it exited with. The entry itself is
+// returned if none of the frames it called reverted.
+func getDeepestRevert(callStack []*callEntry, i int) *callEntry {
+	for j := i - 1; j >= 0 && callStack[j].Depth > callStack[i].Depth; j-- {
+		if c := callStack[j]; c.Depth == callStack[i].Depth+1 && c.Revert != nil {
+			return getDeepestRevert(callStack, j)
+		}
+	}
+	return callStack[i]
+}
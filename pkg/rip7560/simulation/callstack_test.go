@@ -0,0 +1,104 @@
+package simulation
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+func encodeError(t *testing.T, sig string, typ string, value any) []byte {
+	arg, _ := abi.NewType(typ, typ, nil)
+	data, err := abi.Arguments{{Type: arg}}.Pack(value)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return append(crypto.Keccak256([]byte(sig))[:4], data...)
+}
+
+// TestNewCallStack verifies that every frame is paired with its exit, including CREATE2 and reverted frames.
+func TestNewCallStack(t *testing.T) {
+	deployer := testutils.ValidAddress1
+	sender := testutils.ValidAddress2
+	paymaster := testutils.ValidAddress3
+	input := hexutil.MustDecode("0x12345678ff")
+
+	calls := []native.CallFrame{
+		{Type: vm.CALL, From: common.Address{}, To: &deployer, Input: input, Value: big.NewInt(1)},
+		{Type: vm.CREATE2, From: deployer, To: &sender},
+		{Type: vm.RETURN, Output: []byte{0x60, 0x00}},
+		{Type: vm.RETURN, Output: []byte{0x01}},
+		{Type: vm.STATICCALL, From: sender, To: &paymaster, Input: input},
+		{Type: vm.REVERT, Output: encodeError(t, "Error(string)", "string", "boom")},
+		{Type: vm.CALL, From: sender, To: &paymaster},
+		{Type: vm.REVERT, Output: encodeError(t, "Panic(uint256)", "uint256", big.NewInt(0x11))},
+		{Type: vm.CALL, From: sender, To: &paymaster},
+		{Type: vm.REVERT, Output: []byte{0xde, 0xad}},
+		{Type: vm.RETURN},
+	}
+	out := newCallStack(calls)
+	if len(out) != 5 {
+		t.Fatalf("got %d entries, want 5", len(out))
+	}
+
+	if c := out[0]; c.Type != vm.CREATE2 || c.From != deployer || c.To != sender || c.Return != 2 || c.Method != "" {
+		t.Fatalf("got %+v, want CREATE2 entry for sender", c)
+	}
+	if c := out[1]; c.To != deployer || c.Method != "0x12345678" || c.Return != "0x01" || c.Value.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("got %+v, want CALL entry for deployer", c)
+	}
+	if c := out[2]; c.Revert != "boom" || c.Return != nil || c.Value.Sign() != 0 {
+		t.Fatalf("got %+v, want revert reason boom", c)
+	}
+	if c := out[3]; c.Revert != "panic code 0x11" {
+		t.Fatalf("got %+v, want panic code 0x11", c)
+	}
+	if c := out[4]; c.Revert != "0xdead" {
+		t.Fatalf("got %+v, want raw revert data", c)
+	}
+}
+
+// TestGetDeepestRevert verifies that a reverted entity frame is traced down to the frame that caused it and not
+// to a revert that the entity caught.
+func TestGetDeepestRevert(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	sender := tx.GetSender()
+	caught := common.Address{0xa1}
+	outer := common.Address{0xa2}
+	inner := common.Address{0xa3}
+	ep := common.Address{0xff}
+
+	calls := []native.CallFrame{
+		{Type: vm.CALL, From: ep, To: &sender},
+		{Type: vm.CALL, From: sender, To: &caught},
+		{Type: vm.REVERT, Output: encodeError(t, "Error(string)", "string", "caught")},
+		{Type: vm.CALL, From: sender, To: &outer},
+		{Type: vm.CALL, From: outer, To: &inner},
+		{Type: vm.REVERT, Output: encodeError(t, "Error(string)", "string", "inner")},
+		{Type: vm.REVERT, Output: encodeError(t, "Error(string)", "string", "outer")},
+		{Type: vm.REVERT, Output: encodeError(t, "Error(string)", "string", "sender")},
+	}
+	out := newCallStack(calls)
+	if len(out) != 4 {
+		t.Fatalf("got %d entries, want 4", len(out))
+	}
+
+	root := len(out) - 1
+	if !isEntityFrame(tx, out, root) {
+		t.Fatalf("got %+v, want entity frame", out[root])
+	}
+	for i := 0; i < root; i++ {
+		if isEntityFrame(tx, out, i) {
+			t.Fatalf("got entity frame for %+v, want none", out[i])
+		}
+	}
+	if c := getDeepestRevert(out, root); c.To != inner || c.Revert != "inner" || c.Depth != 2 {
+		t.Fatalf("got %+v, want inner revert", c)
+	}
+}
@@ -0,0 +1,50 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+// ErrDeployerSenderMismatch is returned when the deployer of a transaction does not create code at the sender
+// address.
+var ErrDeployerSenderMismatch = errors.New("deployer did not deploy code at sender")
+
+// validateDeployment checks that a transaction with deployer data creates code at the sender within the call
+// subtree of the deployer during validation. The deployer is free to use any factory ABI and to create other
+// contracts along the way, directly or through other factories. A creation is only counted if neither it nor
+// any of the frames enclosing it reverted.
+func validateDeployment(tx *transaction.TransactionArgs, callStack []*callEntry) error {
+	if len(tx.GetDeployerData()) == 0 {
+		return nil
+	}
+
+	for i, call := range callStack {
+		if (call.Type != vm.CREATE && call.Type != vm.CREATE2) || call.To != tx.GetSender() {
+			continue
+		}
+		if isDeployedBy(tx.GetDeployer(), callStack, i) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no code created at %s by %s", ErrDeployerSenderMismatch, tx.GetSender(), tx.GetDeployer())
+}
+
+// isDeployedBy returns true if the entry at index i is within the call subtree of the deployer and neither it
+// nor any enclosing frame reverted.
+func isDeployedBy(deployer common.Address, callStack []*callEntry, i int) bool {
+	inSubtree := false
+	for j := i; j >= 0; j = getParent(callStack, j) {
+		if callStack[j].Revert != nil {
+			return false
+		}
+		if callStack[j].From == deployer || callStack[j].To == deployer {
+			inSubtree = true
+		}
+	}
+	return inSubtree
+}
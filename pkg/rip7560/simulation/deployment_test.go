@@ -0,0 +1,90 @@
+package simulation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestValidateDeployment verifies that a deployer must create code at the sender within its call subtree for any
+// factory ABI.
+func TestValidateDeployment(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	sender := tx.GetSender()
+	deployer := tx.GetDeployer()
+	other := common.HexToAddress("0xdead")
+
+	cases := []struct {
+		name    string
+		calls   []*callEntry
+		wantErr bool
+	}{
+		{
+			name:  "deployer creates sender with CREATE2",
+			calls: []*callEntry{{Type: vm.CREATE2, From: deployer, To: sender}},
+		},
+		{
+			name: "deployer creates sender through a proxy factory",
+			calls: []*callEntry{
+				{Type: vm.CREATE, From: other, To: sender, Depth: 1},
+				{Type: vm.CALL, From: deployer, To: other, Return: "0x"},
+			},
+		},
+		{
+			name: "deployer creates another contract along with sender",
+			calls: []*callEntry{
+				{Type: vm.CREATE2, From: deployer, To: other},
+				{Type: vm.CREATE2, From: deployer, To: sender},
+			},
+		},
+		{
+			name:    "deployer creates another address",
+			calls:   []*callEntry{{Type: vm.CREATE2, From: deployer, To: other}},
+			wantErr: true,
+		},
+		{
+			name:    "deployer does not create code",
+			calls:   []*callEntry{{Type: vm.CALL, From: deployer, To: other, Return: "0x"}},
+			wantErr: true,
+		},
+		{
+			name:    "sender created outside of the deployer",
+			calls:   []*callEntry{{Type: vm.CREATE2, From: other, To: sender}},
+			wantErr: true,
+		},
+		{
+			name:    "deployer create reverts",
+			calls:   []*callEntry{{Type: vm.CREATE2, From: deployer, To: sender, Revert: "0x"}},
+			wantErr: true,
+		},
+		{
+			name: "deployer reverts after creating sender",
+			calls: []*callEntry{
+				{Type: vm.CREATE2, From: deployer, To: sender, Depth: 1},
+				{Type: vm.CALL, From: other, To: deployer, Revert: "0x"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateDeployment(tx, c.calls)
+			if !c.wantErr && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if c.wantErr && !errors.Is(err, ErrDeployerSenderMismatch) {
+				t.Fatalf("got %v, want ErrDeployerSenderMismatch", err)
+			}
+		})
+	}
+}
+
+// TestValidateDeploymentWithoutDeployerData verifies that deployed accounts are not checked.
+func TestValidateDeploymentWithoutDeployerData(t *testing.T) {
+	if err := validateDeployment(deployedTx(), nil); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
@@ -0,0 +1,59 @@
+package simulation
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+const (
+	EntityAccount   = "account"
+	EntityDeployer  = "deployer"
+	EntityPaymaster = "paymaster"
+)
+
+// EntityError is a validation error that the trace attributes to one of the known entities of a transaction.
+type EntityError struct {
+	Entity string
+	Err    error
+}
+
+func (e *EntityError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EntityError) Unwrap() error {
+	return e.Err
+}
+
+func newEntityError(entity string, err error) error {
+	return &EntityError{Entity: entity, Err: err}
+}
+
+// GetEntityAddress returns the address of a known entity of tx by title. Unknown titles and entities that
+// are not set on tx return the sender.
+func GetEntityAddress(tx *transaction.TransactionArgs, entity string) common.Address {
+	var addr common.Address
+	switch entity {
+	case EntityDeployer:
+		addr = tx.GetDeployer()
+	case EntityPaymaster:
+		addr = tx.GetPaymaster()
+	}
+	if addr == (common.Address{}) {
+		return tx.GetSender()
+	}
+	return addr
+}
+
+// GetResponsibleEntity returns the entity at fault for a failed validation. Errors attributed to an entity
+// with an EntityError return that entity. Any other error, including one from a node call whose text could be
+// set by the transaction, is attributed to the sender.
+func GetResponsibleEntity(tx *transaction.TransactionArgs, err error) common.Address {
+	var ee *EntityError
+	if errors.As(err, &ee) {
+		return GetEntityAddress(tx, ee.Entity)
+	}
+	return tx.GetSender()
+}
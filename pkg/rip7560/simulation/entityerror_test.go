@@ -0,0 +1,26 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestGetResponsibleEntity verifies that a validation error is attributed to the entity set by simulation and
+// never to an entity that is only mentioned in the error text.
+func TestGetResponsibleEntity(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	pmErr := &EntityError{Entity: EntityPaymaster, Err: errors.New("paymaster OOG")}
+	if got := GetResponsibleEntity(tx, fmt.Errorf("wrapped: %w", pmErr)); got != tx.GetPaymaster() {
+		t.Fatalf("got %s, want paymaster %s", got, tx.GetPaymaster())
+	}
+	dpErr := &EntityError{Entity: EntityDeployer, Err: errors.New("deployer reverted")}
+	if got := GetResponsibleEntity(tx, dpErr); got != tx.GetDeployer() {
+		t.Fatalf("got %s, want deployer %s", got, tx.GetDeployer())
+	}
+	if got := GetResponsibleEntity(tx, errors.New("paymaster deposit too low")); got != tx.GetSender() {
+		t.Fatalf("got %s, want sender %s", got, tx.GetSender())
+	}
+}
@@ -3,18 +3,22 @@ package simulation
 import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/tracers/native"
-	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/methods"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
+// knownEntityTitles is the order in which known entities are checked so that errors are deterministic.
+var knownEntityTitles = []string{EntityDeployer, EntityAccount, EntityPaymaster}
+
 type knownEntity map[string]struct {
-	Address common.Address
-	Info    *native.Level
+	Address  common.Address
+	Info     *native.Level
+	IsStaked bool
 }
 
 func newKnownEntity(
 	tx *transaction.TransactionArgs,
 	res *native.Rip7560ValidationResult,
+	isStaked IsStakedFunc,
 ) (knownEntity, error) {
 	var si, fi, pi *native.Level
 	for _, c := range res.CallsFromEntryPoint {
@@ -24,37 +28,78 @@ func newKnownEntity(
 		case tx.GetPaymaster():
 			pi = c
 		case tx.GetDeployer():
-			if c.TopLevelMethodSig.String() == methods.CreateAccountSelector {
-				fi = c
-			}
+			fi = c
 		default:
 		}
 	}
 
+	staked := func(addr common.Address) (bool, error) {
+		if isStaked == nil || addr == (common.Address{}) {
+			return false, nil
+		}
+		return isStaked(addr)
+	}
+	ss, err := staked(tx.GetSender())
+	if err != nil {
+		return nil, err
+	}
+	fs, err := staked(tx.GetDeployer())
+	if err != nil {
+		return nil, err
+	}
+	ps, err := staked(tx.GetPaymaster())
+	if err != nil {
+		return nil, err
+	}
+
 	return knownEntity{
 		"account": {
-			Address: tx.GetSender(),
-			Info:    si,
+			Address:  tx.GetSender(),
+			Info:     si,
+			IsStaked: ss,
 		},
 		"deployer": {
-			Address: tx.GetDeployer(),
-			Info:    fi,
+			Address:  tx.GetDeployer(),
+			Info:     fi,
+			IsStaked: fs,
 		},
 		"paymaster": {
-			Address: tx.GetPaymaster(),
-			Info:    pi,
+			Address:  tx.GetPaymaster(),
+			Info:     pi,
+			IsStaked: ps,
 		},
 	}, nil
 }
 
 func addr2KnownEntity(tx *transaction.TransactionArgs, addr common.Address) string {
 	if addr == tx.GetDeployer() {
-		return "deployer"
+		return EntityDeployer
 	} else if addr == tx.GetSender() {
-		return "account"
+		return EntityAccount
 	} else if addr == tx.GetPaymaster() {
-		return "paymaster"
+		return EntityPaymaster
 	} else {
 		return addr.String()
 	}
 }
+
+// isEntityFrame returns true if the entry at index i is a call into the sender, deployer, or paymaster that is
+// not enclosed by a call into any of them. This is the root of the subtree run on behalf of that entity.
+func isEntityFrame(tx *transaction.TransactionArgs, callStack []*callEntry, i int) bool {
+	if !isKnownEntity(tx, callStack[i].To) {
+		return false
+	}
+	for j := getParent(callStack, i); j >= 0; j = getParent(callStack, j) {
+		if isKnownEntity(tx, callStack[j].To) {
+			return false
+		}
+	}
+	return true
+}
+
+func isKnownEntity(tx *transaction.TransactionArgs, addr common.Address) bool {
+	if addr == (common.Address{}) {
+		return false
+	}
+	return addr == tx.GetSender() || addr == tx.GetDeployer() || addr == tx.GetPaymaster()
+}
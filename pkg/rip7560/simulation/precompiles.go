@@ -0,0 +1,60 @@
+package simulation
+
+import (
+	"fmt"
+	"math/big"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+)
+
+var (
+	// maxPrecompile is the highest address reserved for precompiles.
+	maxPrecompile = big.NewInt(0xffff)
+
+	// List of precompiles that can be called during validation. These are the Ethereum precompiles from
+	// ecrecover to blake2f and the RIP-7212 secp256r1 verifier.
+	allowedPrecompiles = mapset.NewSet(
+		common.HexToAddress("0x01"),
+		common.HexToAddress("0x02"),
+		common.HexToAddress("0x03"),
+		common.HexToAddress("0x04"),
+		common.HexToAddress("0x05"),
+		common.HexToAddress("0x06"),
+		common.HexToAddress("0x07"),
+		common.HexToAddress("0x08"),
+		common.HexToAddress("0x09"),
+		common.HexToAddress("0x0100"),
+	)
+)
+
+func isForbiddenPrecompile(addr common.Address) bool {
+	n := new(big.Int).SetBytes(addr.Bytes())
+	return n.Sign() > 0 && n.Cmp(maxPrecompile) <= 0 && !allowedPrecompiles.Contains(addr)
+}
+
+// validatePrecompiles checks that an entity only calls allowed precompiles during validation. A forbidden
+// precompile is only allowed if an alternative mempool has an exception for it, in which case the ids of those
+// mempools are returned.
+func validatePrecompiles(
+	title string,
+	entity common.Address,
+	info *native.Level,
+	am *altmempools.Directory,
+) ([]string, error) {
+	ids := mapset.NewSet[string]()
+	for addr := range info.ContractSize {
+		if !isForbiddenPrecompile(addr) {
+			continue
+		}
+
+		exc := am.HasForbiddenPrecompileException(title, entity, entity, addr)
+		if len(exc) == 0 {
+			return nil, fmt.Errorf("%s calls forbidden precompile: %s", title, addr.Hex())
+		}
+		ids.Append(exc...)
+	}
+	return ids.ToSlice(), nil
+}
@@ -0,0 +1,60 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+)
+
+func calls(addrs ...common.Address) *native.Level {
+	cs := native.ContractSizeMap{}
+	for _, addr := range addrs {
+		cs[addr] = native.ContractSizeInfo{}
+	}
+	return &native.Level{ContractSize: cs}
+}
+
+// TestValidatePrecompiles verifies that calls to forbidden precompiles are rejected unless an alternative
+// mempool has an exception for the entity.
+func TestValidatePrecompiles(t *testing.T) {
+	sender := testutils.ValidAddress1
+	forbidden := common.HexToAddress("0x0a")
+	alt, err := altmempools.New(testutils.ChainID, map[string]any{
+		"precompile": map[string]any{
+			"description": "Allow the account to call precompile 0x0a",
+			"chainIds":    []any{hexutil.EncodeBig(testutils.ChainID)},
+			"allowlist": []any{
+				map[string]any{
+					"rule":       "forbiddenPrecompile",
+					"entity":     "account",
+					"contract":   sender.Hex(),
+					"precompile": forbidden.Hex(),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	allowed := calls(common.HexToAddress("0x01"), common.HexToAddress("0x0100"), testutils.ValidAddress2)
+	if ids, err := validatePrecompiles("account", sender, allowed, nil); err != nil || len(ids) != 0 {
+		t.Fatalf("got %v, %v, want [], nil", ids, err)
+	}
+
+	if _, err := validatePrecompiles("account", sender, calls(forbidden), nil); err == nil {
+		t.Fatal("got nil, want err for forbidden precompile")
+	}
+	if ids, err := validatePrecompiles("account", sender, calls(forbidden), alt); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ids) != 1 || ids[0] != "precompile" {
+		t.Fatalf("got %v, want [precompile]", ids)
+	}
+	if _, err := validatePrecompiles("paymaster", sender, calls(forbidden), alt); err == nil {
+		t.Fatal("got nil, want err for paymaster without exception")
+	}
+}
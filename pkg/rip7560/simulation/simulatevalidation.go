@@ -2,21 +2,38 @@ package simulation
 
 import (
 	"context"
+
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
 // SimulateValidation makes a static call to eth_callRip7560Validation and returns the
-// results without any state changes.
+// results without any state changes. Errors that are not a response from the node are returned as an
+// errors.TransportError.
 func SimulateValidation(
 	rpc *rpc.Client,
 	tx *transaction.TransactionArgs,
 ) (*core.ValidationPhaseResult, error) {
 	var res core.ValidationPhaseResult
 	if err := rpc.CallContext(context.Background(), &res, "eth_callRip7560Validation", tx, "latest"); err != nil {
-		return nil, err
+		return nil, errors.WrapTransportError(err)
 	}
 
 	return &res, nil
 }
+
+// GetValidityWindow returns the range of timestamps in which both the sender and paymaster consider the
+// transaction valid. A validUntil of 0 means there is no upper bound.
+func GetValidityWindow(res *core.ValidationPhaseResult) (validAfter uint64, validUntil uint64) {
+	validAfter = res.SenderValidAfter
+	if res.PmValidAfter > validAfter {
+		validAfter = res.PmValidAfter
+	}
+	validUntil = res.SenderValidUntil
+	if res.PmValidUntil != 0 && (validUntil == 0 || res.PmValidUntil < validUntil) {
+		validUntil = res.PmValidUntil
+	}
+	return validAfter, validUntil
+}
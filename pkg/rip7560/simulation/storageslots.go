@@ -1,9 +1,10 @@
 package simulation
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
-	"strings"
+	"sort"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/ethereum/go-ethereum/common"
@@ -11,6 +12,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 	"github.com/stackup-wallet/stackup-bundler/pkg/tracer"
 )
@@ -25,23 +27,29 @@ type storageSlots mapset.Set[string]
 
 type storageSlotsByEntity map[common.Address]storageSlots
 
+// newStorageSlotsByEntity returns the storage slots associated with each target address. A slot is associated
+// with an address if it is the keccak of a value starting with the address padded to 32 bytes, which is how
+// a mapping keyed by the address derives its slot.
 func newStorageSlotsByEntity(keccak []string, targetAddresses []common.Address) storageSlotsByEntity {
 	storageSlotsByEntity := make(storageSlotsByEntity)
+	for _, addr := range targetAddresses {
+		if addr == (common.Address{}) {
+			continue
+		}
+		storageSlotsByEntity[addr] = mapset.NewSet[string]()
+	}
 
 	for _, k := range keccak {
-		value := hexutil.Encode(crypto.Keccak256(common.Hex2Bytes(k[2:])))
-
-		for _, addr := range targetAddresses {
-			if addr == common.HexToAddress("0x") {
-				continue
-			}
-			if _, ok := storageSlotsByEntity[addr]; !ok {
-				storageSlotsByEntity[addr] = mapset.NewSet[string]()
-			}
+		input, err := hexutil.Decode(k)
+		if err != nil {
+			continue
+		}
+		value := hexutil.Encode(crypto.Keccak256(input))
 
-			addrPadded := hexutil.Encode(common.LeftPadBytes(addr.Bytes(), 32))
-			if strings.HasPrefix(k, addrPadded) {
-				storageSlotsByEntity[addr].Add(value)
+		for addr, slots := range storageSlotsByEntity {
+			addrPadded := common.LeftPadBytes(addr.Bytes(), 32)
+			if bytes.HasPrefix(input, addrPadded) {
+				slots.Add(value)
 			}
 		}
 	}
@@ -54,7 +62,8 @@ type storageSlotsValidator struct {
 	Tx *transaction.TransactionArgs
 
 	// Parameters of specific entities required for all validation
-	SenderSlots storageSlots
+	SenderSlots      storageSlots
+	DeployerIsStaked bool
 
 	// Parameters of the entity under validation
 	EntityName            string
@@ -62,12 +71,25 @@ type storageSlotsValidator struct {
 	EntityAccessMap       native.AccessMap
 	EntityContractSizeMap native.ContractSizeMap
 	EntitySlots           storageSlots
+	EntityIsStaked        bool
+
+	// AltMempools grant exceptions to the storage rules. Ids of the alternative mempools that were needed are
+	// returned by Process.
+	AltMempools *altmempools.Directory
 }
 
+// isAssociatedWith returns true if the slot is within associatedSlotOffset of any of the entity's slots. This
+// allows for structs stored in a mapping keyed by the entity.
 func isAssociatedWith(entitySlots storageSlots, slot string) bool {
-	slotBN, _ := big.NewInt(0).SetString(slot, 0)
+	slotBN, ok := big.NewInt(0).SetString(slot, 0)
+	if !ok {
+		return false
+	}
 	for _, entitySlot := range entitySlots.ToSlice() {
-		entitySlotBN, _ := big.NewInt(0).SetString(entitySlot, 0)
+		entitySlotBN, ok := big.NewInt(0).SetString(entitySlot, 0)
+		if !ok {
+			continue
+		}
 		maxAssocSlotBN := big.NewInt(0).Add(entitySlotBN, associatedSlotOffset)
 		if slotBN.Cmp(entitySlotBN) >= 0 && slotBN.Cmp(maxAssocSlotBN) <= 0 {
 			return true
@@ -77,7 +99,13 @@ func isAssociatedWith(entitySlots storageSlots, slot string) bool {
 	return false
 }
 
-func (v *storageSlotsValidator) Process() error {
+// Process checks the storage accessed by an entity during validation against the ERC-7562 storage rules.
+// The account's own storage can always be accessed. Storage associated with the account in other contracts
+// can be accessed once the account exists or if the deployer is staked. All other access (i.e. the entity's
+// own storage, storage associated with the entity, or reads of any other slot) requires the entity to be
+// staked. Writes to storage not associated with the account or entity are never allowed. Any of these rules
+// can be waived by an alternative mempool.
+func (v *storageSlotsValidator) Process() ([]string, error) {
 	senderSlots := v.SenderSlots
 	if senderSlots == nil {
 		senderSlots = mapset.NewSet[string]()
@@ -87,17 +115,32 @@ func (v *storageSlotsValidator) Process() error {
 		entitySlots = mapset.NewSet[string]()
 	}
 
+	altMempoolIds := []string{}
+	hasStorageException := func(addr common.Address, slot string) bool {
+		ids := v.AltMempools.HasInvalidStorageAccessException(v.EntityName, v.EntityAddr, addr, slot)
+		altMempoolIds = append(altMempoolIds, ids...)
+		return len(ids) > 0
+	}
+	hasStakeOrException := func() bool {
+		if v.EntityIsStaked {
+			return true
+		}
+		ids := v.AltMempools.HasNotStakedException(v.EntityName, v.EntityAddr)
+		altMempoolIds = append(altMempoolIds, ids...)
+		return len(ids) > 0
+	}
+
 	for addr, access := range v.EntityAccessMap {
 		if addr == v.Tx.GetSender() || addr == config.EntryPointAddress {
 			continue
 		}
 
-		var mustStakeSlot string
 		accessTypes := map[string]any{
 			accessModeRead:  access.Reads,
 			accessModeWrite: access.Writes,
 		}
-		for mode, val := range accessTypes {
+		for _, mode := range []string{accessModeWrite, accessModeRead} {
+			val := accessTypes[mode]
 			slots := []string{}
 			if readMap, ok := val.(tracer.HexMap); ok {
 				for slot := range readMap {
@@ -108,47 +151,55 @@ func (v *storageSlotsValidator) Process() error {
 					slots = append(slots, slot)
 				}
 			} else {
-				return fmt.Errorf("cannot decode %s access type: %+v", mode, val)
+				return nil, fmt.Errorf("cannot decode %s access type: %+v", mode, val)
 			}
+			sort.Strings(slots)
+
+			for _, slot := range slots {
+				var mustStakeReason string
+				switch {
+				case isAssociatedWith(senderSlots, slot):
+					if len(v.Tx.GetDeployerData()) > 0 && !v.DeployerIsStaked && !hasStorageException(addr, slot) {
+						return nil, fmt.Errorf(
+							"%s has forbidden %s to %s slot %s associated with an undeployed account and an unstaked deployer",
+							v.EntityName,
+							mode,
+							addr2KnownEntity(v.Tx, addr),
+							slot,
+						)
+					}
+					continue
+				case addr == v.EntityAddr:
+					mustStakeReason = "storage of the entity"
+				case isAssociatedWith(entitySlots, slot):
+					mustStakeReason = "storage associated with the entity"
+				case mode == accessModeRead:
+					mustStakeReason = "reading unassociated storage"
+				default:
+					if hasStorageException(addr, slot) {
+						continue
+					}
+					return nil, fmt.Errorf(
+						"%s has forbidden %s to %s slot %s",
+						v.EntityName,
+						mode,
+						addr2KnownEntity(v.Tx, addr),
+						slot,
+					)
+				}
 
-			// FIXME : remove or uncommnet
-			//for _, slot := range slots {
-			//	if isAssociatedWith(senderSlots, slot) {
-			//		if (len(v.Tx.GetDeployerData()) > 0) ||
-			//			(len(v.Tx.GetDeployerData()) > 0 && v.EntityAddr != v.Tx.GetSender()) {
-			//			mustStakeSlot = slot
-			//		} else {
-			//			continue
-			//		}
-			//	} else if amIds := v.AltMempools.HasInvalidStorageAccessException(
-			//		v.EntityName,
-			//		addr2KnownEntity(v.Tx, addr),
-			//		slot,
-			//	); (isAssociatedWith(entitySlots, slot) || mode == accessModeRead) && len(amIds) == 0 {
-			//		mustStakeSlot = slot
-			//	} else if len(amIds) > 0 {
-			//		altMempoolIds = append(altMempoolIds, amIds...)
-			//	} else {
-			//		return fmt.Errorf(
-			//			"%s has forbidden %s to %s slot %s",
-			//			v.EntityName,
-			//			mode,
-			//			addr2KnownEntity(v.Tx, addr),
-			//			slot,
-			//		)
-			//	}
-			//}
-		}
-
-		if mustStakeSlot != "" {
-			return fmt.Errorf(
-				"unstaked %s accessed %s slot %s",
-				v.EntityName,
-				addr2KnownEntity(v.Tx, addr),
-				mustStakeSlot,
-			)
+				if !hasStakeOrException() && !hasStorageException(addr, slot) {
+					return nil, fmt.Errorf(
+						"unstaked %s accessed %s slot %s: %s requires stake",
+						v.EntityName,
+						addr2KnownEntity(v.Tx, addr),
+						slot,
+						mustStakeReason,
+					)
+				}
+			}
 		}
 	}
 
-	return nil
+	return altMempoolIds, nil
 }
@@ -0,0 +1,272 @@
+package simulation
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+var token = common.HexToAddress("0x7e4e2")
+
+// mappingKey returns the keccak preimage for the slot of addr in a mapping stored at slot 0.
+func mappingKey(addr common.Address) string {
+	return hexutil.Encode(append(common.LeftPadBytes(addr.Bytes(), 32), make([]byte, 32)...))
+}
+
+// mappingSlot returns the slot of addr in a mapping stored at slot 0 plus an offset.
+func mappingSlot(addr common.Address, offset int64) string {
+	key, _ := hexutil.Decode(mappingKey(addr))
+	slot := new(big.Int).SetBytes(crypto.Keccak256(key))
+	return hexutil.EncodeBig(slot.Add(slot, big.NewInt(offset)))
+}
+
+func reads(slots ...string) native.AccessInfo {
+	info := native.AccessInfo{Reads: map[string]string{}, Writes: map[string]int{}}
+	for _, slot := range slots {
+		info.Reads[slot] = "0x01"
+	}
+	return info
+}
+
+func writes(slots ...string) native.AccessInfo {
+	info := native.AccessInfo{Reads: map[string]string{}, Writes: map[string]int{}}
+	for _, slot := range slots {
+		info.Writes[slot] = 1
+	}
+	return info
+}
+
+func deployedTx() *transaction.TransactionArgs {
+	tx := testutils.MockValidInitRip7560Tx()
+	tx.Deployer = nil
+	tx.DeployerData = nil
+	return tx
+}
+
+func TestNewStorageSlotsByEntity(t *testing.T) {
+	tx := testutils.MockValidInitRip7560Tx()
+	keccak := []string{mappingKey(tx.GetSender()), mappingKey(tx.GetPaymaster()), "0x1234", "not hex"}
+	slots := newStorageSlotsByEntity(keccak, []common.Address{tx.GetSender(), tx.GetPaymaster(), {}})
+
+	if _, ok := slots[common.Address{}]; ok {
+		t.Fatal("got slots for zero address, want none")
+	}
+	if s := slots[tx.GetSender()]; s.Cardinality() != 1 || !s.Contains(mappingSlot(tx.GetSender(), 0)) {
+		t.Fatalf("got %v, want sender mapping slot", s)
+	}
+	if s := slots[tx.GetPaymaster()]; s.Cardinality() != 1 || !s.Contains(mappingSlot(tx.GetPaymaster(), 0)) {
+		t.Fatalf("got %v, want paymaster mapping slot", s)
+	}
+}
+
+// TestStorageSlotsValidatorProcess runs the storage rules over access maps recorded from validation traces.
+func TestStorageSlotsValidatorProcess(t *testing.T) {
+	initTx := testutils.MockValidInitRip7560Tx()
+	sender := initTx.GetSender()
+	paymaster := initTx.GetPaymaster()
+	keccak := []string{mappingKey(sender), mappingKey(paymaster)}
+	alt, err := altmempools.New(testutils.ChainID, map[string]any{
+		"storage": map[string]any{
+			"description": "Allow paymaster writes to token slot 0x01",
+			"chainIds":    []any{hexutil.EncodeBig(testutils.ChainID)},
+			"allowlist": []any{
+				map[string]any{
+					"rule":     "invalidStorageAccess",
+					"entity":   "paymaster",
+					"contract": token.Hex(),
+					"slot":     "0x1",
+				},
+			},
+		},
+		"unstaked": map[string]any{
+			"description": "Treat the account as staked",
+			"chainIds":    []any{hexutil.EncodeBig(testutils.ChainID)},
+			"allowlist": []any{
+				map[string]any{"rule": "notStaked", "entity": sender.Hex()},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	cases := []struct {
+		name             string
+		tx               *transaction.TransactionArgs
+		entity           string
+		access           native.AccessMap
+		entityIsStaked   bool
+		deployerIsStaked bool
+		altMempools      *altmempools.Directory
+		wantErr          string
+		wantIds          []string
+	}{
+		{
+			name:   "account writes own storage",
+			tx:     initTx,
+			entity: "account",
+			access: native.AccessMap{sender: writes("0x00")},
+		},
+		{
+			name:   "paymaster reads EntryPoint storage",
+			tx:     initTx,
+			entity: "paymaster",
+			access: native.AccessMap{config.EntryPointAddress: reads("0x00")},
+		},
+		{
+			name:   "deployed account writes associated storage",
+			tx:     deployedTx(),
+			entity: "account",
+			access: native.AccessMap{token: writes(mappingSlot(sender, 0))},
+		},
+		{
+			name:   "deployed account writes associated struct within offset",
+			tx:     deployedTx(),
+			entity: "account",
+			access: native.AccessMap{token: writes(mappingSlot(sender, 128))},
+		},
+		{
+			name:    "deployed account writes beyond associated offset",
+			tx:      deployedTx(),
+			entity:  "account",
+			access:  native.AccessMap{token: writes(mappingSlot(sender, 129))},
+			wantErr: "account has forbidden write to",
+		},
+		{
+			name:    "undeployed account with unstaked deployer reads associated storage",
+			tx:      initTx,
+			entity:  "account",
+			access:  native.AccessMap{token: reads(mappingSlot(sender, 0))},
+			wantErr: "associated with an undeployed account and an unstaked deployer",
+		},
+		{
+			name:             "undeployed account with staked deployer reads associated storage",
+			tx:               initTx,
+			entity:           "account",
+			access:           native.AccessMap{token: reads(mappingSlot(sender, 0))},
+			deployerIsStaked: true,
+		},
+		{
+			name:    "unstaked paymaster writes own storage",
+			tx:      initTx,
+			entity:  "paymaster",
+			access:  native.AccessMap{paymaster: writes("0x00")},
+			wantErr: "unstaked paymaster accessed paymaster slot 0x00: storage of the entity requires stake",
+		},
+		{
+			name:           "staked paymaster writes own storage",
+			tx:             initTx,
+			entity:         "paymaster",
+			access:         native.AccessMap{paymaster: writes("0x00")},
+			entityIsStaked: true,
+		},
+		{
+			name:    "unstaked paymaster writes associated storage",
+			tx:      initTx,
+			entity:  "paymaster",
+			access:  native.AccessMap{token: writes(mappingSlot(paymaster, 0))},
+			wantErr: "storage associated with the entity requires stake",
+		},
+		{
+			name:           "staked paymaster writes associated storage",
+			tx:             initTx,
+			entity:         "paymaster",
+			access:         native.AccessMap{token: writes(mappingSlot(paymaster, 0))},
+			entityIsStaked: true,
+		},
+		{
+			name:    "unstaked account reads unassociated storage",
+			tx:      deployedTx(),
+			entity:  "account",
+			access:  native.AccessMap{token: reads("0x01")},
+			wantErr: "reading unassociated storage requires stake",
+		},
+		{
+			name:           "staked account reads unassociated storage",
+			tx:             deployedTx(),
+			entity:         "account",
+			access:         native.AccessMap{token: reads("0x01")},
+			entityIsStaked: true,
+		},
+		{
+			name:           "staked paymaster writes unassociated storage",
+			tx:             initTx,
+			entity:         "paymaster",
+			access:         native.AccessMap{token: writes("0x01")},
+			entityIsStaked: true,
+			wantErr:        "paymaster has forbidden write to " + token.String() + " slot 0x01",
+		},
+		{
+			name:           "alt mempool allows paymaster write to unassociated storage",
+			tx:             initTx,
+			entity:         "paymaster",
+			access:         native.AccessMap{token: writes("0x01")},
+			entityIsStaked: true,
+			altMempools:    alt,
+			wantIds:        []string{"storage"},
+		},
+		{
+			name:        "alt mempool does not allow paymaster write to another slot",
+			tx:          initTx,
+			entity:      "paymaster",
+			access:      native.AccessMap{token: writes("0x02")},
+			altMempools: alt,
+			wantErr:     "paymaster has forbidden write to",
+		},
+		{
+			name:        "alt mempool treats unstaked account as staked",
+			tx:          deployedTx(),
+			entity:      "account",
+			access:      native.AccessMap{token: reads("0x01")},
+			altMempools: alt,
+			wantIds:     []string{"unstaked"},
+		},
+		{
+			name:        "alt mempool ids are not needed for canonical access",
+			tx:          deployedTx(),
+			entity:      "account",
+			access:      native.AccessMap{token: writes(mappingSlot(sender, 0))},
+			altMempools: alt,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entityAddr := sender
+			if c.entity == "paymaster" {
+				entityAddr = paymaster
+			}
+			slots := newStorageSlotsByEntity(keccak, []common.Address{sender, entityAddr})
+			v := &storageSlotsValidator{
+				Tx:               c.tx,
+				SenderSlots:      slots[sender],
+				DeployerIsStaked: c.deployerIsStaked,
+				EntityName:       c.entity,
+				EntityAddr:       entityAddr,
+				EntityAccessMap:  c.access,
+				EntitySlots:      slots[entityAddr],
+				EntityIsStaked:   c.entityIsStaked,
+				AltMempools:      c.altMempools,
+			}
+
+			ids, err := v.Process()
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("got %v, want err containing %q", err, c.wantErr)
+			}
+			if c.wantErr == "" && strings.Join(ids, ",") != strings.Join(c.wantIds, ",") {
+				t.Fatalf("got ids %v, want %v", ids, c.wantIds)
+			}
+		})
+	}
+}
@@ -2,64 +2,111 @@ package simulation
 
 import (
 	"context"
-	"errors"
+	stderrors "errors"
 	"fmt"
 	"math/big"
+	"sort"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/methods"
 	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
 )
 
+// IsStakedFunc returns true if an entity has enough stake to be exempt from the unstaked entity rules.
+type IsStakedFunc = func(entity common.Address) (bool, error)
+
 type TraceInput struct {
 	Rpc     *rpc.Client
 	Tx      *transaction.TransactionArgs
 	ChainID *big.Int
+
+	// IsStaked is used to apply the staked entity exceptions during validation. All entities are treated as
+	// unstaked if it is nil.
+	IsStaked IsStakedFunc
+
+	// AltMempools are the alternative mempools that may waive validation rules. No rules are waived if it is
+	// nil.
+	AltMempools *altmempools.Directory
 }
 
 type TraceOutput struct {
 	TouchedContracts []common.Address
+
+	// StorageAccess is the storage read and written during validation by each entity address.
+	StorageAccess map[common.Address]native.AccessMap
+
+	// AltMempoolIds are the alternative mempools that waived a rule the transaction would otherwise break.
+	// It is empty if the transaction passes the canonical rules.
+	AltMempoolIds []string
 }
 
 // TraceSimulateValidation makes call to debug_traceRip7560Validation to geth and returns
-// information related to the validation phase of a RIP-7560 transaction.
+// information related to the validation phase of a RIP-7560 transaction. Errors from calls that did not
+// reach the node are returned as an errors.TransportError. Rule violations by a known entity are returned as
+// an EntityError.
 func TraceSimulateValidation(in *TraceInput) (*TraceOutput, error) {
 	var res native.Rip7560ValidationResult
 	req := in.Tx
 	if err := in.Rpc.CallContext(context.Background(), &res, "debug_traceRip7560Validation", &req, "latest"); err != nil {
-		return nil, err
+		return nil, errors.WrapTransportError(err)
 	}
 
-	knownEntity, err := newKnownEntity(in.Tx, &res)
+	knownEntity, err := newKnownEntity(in.Tx, &res, in.IsStaked)
 	if err != nil {
 		return nil, err
 	}
 
+	amIds := mapset.NewSet[string]()
+	hasStakeOrException := func(title string) bool {
+		entity := knownEntity[title]
+		if entity.IsStaked {
+			return true
+		}
+		ids := in.AltMempools.HasNotStakedException(title, entity.Address)
+		amIds.Append(ids...)
+		return len(ids) > 0
+	}
+
 	ic := mapset.NewSet[common.Address]()
-	for title, entity := range knownEntity {
+	sa := make(map[common.Address]native.AccessMap)
+	for _, title := range knownEntityTitles {
+		entity := knownEntity[title]
 		if entity.Info == nil {
 			continue
 		}
+		sa[entity.Address] = entity.Info.Access
 		if entity.Info.Oog {
-			return nil, fmt.Errorf("%s OOG", title)
+			return nil, newEntityError(title, fmt.Errorf("%s OOG", title))
 		}
 		if _, ok := entity.Info.ExtCodeAccessInfo[config.EntryPointAddress]; ok {
-			return nil, fmt.Errorf("%s has forbidden EXTCODE* access to the EntryPoint", title)
+			return nil, newEntityError(title, fmt.Errorf("%s has forbidden EXTCODE* access to the EntryPoint", title))
 		}
 		for opcode := range entity.Info.Opcodes {
 			if bannedOpCodes.Contains(opcode) {
-				return nil, fmt.Errorf("%s uses banned opcode: %s", title, opcode)
+				ids := in.AltMempools.HasForbiddenOpcodeException(title, entity.Address, entity.Address, opcode)
+				if len(ids) == 0 {
+					return nil, newEntityError(title, fmt.Errorf("%s uses banned opcode: %s", title, opcode))
+				}
+				amIds.Append(ids...)
 			}
 
-			if bannedUnstakedOpCodes.Contains(opcode) {
-				return nil, fmt.Errorf("unstaked %s uses banned opcode: %s", title, opcode)
+			if bannedUnstakedOpCodes.Contains(opcode) && !hasStakeOrException(title) {
+				return nil, newEntityError(title, fmt.Errorf("unstaked %s uses banned opcode: %s", title, opcode))
 			}
 		}
 
+		ids, err := validatePrecompiles(title, entity.Address, entity.Info, in.AltMempools)
+		if err != nil {
+			return nil, newEntityError(title, err)
+		}
+		amIds.Append(ids...)
+
 		ic.Add(entity.Address)
 		for addr := range entity.Info.ContractSize {
 			ic.Add(addr)
@@ -68,46 +115,69 @@ func TraceSimulateValidation(in *TraceInput) (*TraceOutput, error) {
 	if knownEntity["deployer"].Info != nil {
 		create2Count, ok := knownEntity["deployer"].Info.Opcodes[create2OpCode]
 		if ok && (create2Count > 1 || len(in.Tx.GetDeployerData()) == 0) {
-			return nil, fmt.Errorf("deployer with too many %s", create2OpCode)
+			return nil, newEntityError(EntityDeployer, fmt.Errorf("deployer with too many %s", create2OpCode))
 		}
 	}
 	if knownEntity["account"].Info != nil {
 		_, ok := knownEntity["account"].Info.Opcodes[create2OpCode]
 		if ok {
-			return nil, fmt.Errorf("account uses banned opcode: %s", create2OpCode)
+			return nil, newEntityError(EntityAccount, fmt.Errorf("account uses banned opcode: %s", create2OpCode))
 		}
 	}
 	if knownEntity["paymaster"].Info != nil {
 		_, ok := knownEntity["paymaster"].Info.Opcodes[create2OpCode]
 		if ok {
-			return nil, fmt.Errorf("paymaster uses banned opcode: %s", create2OpCode)
+			return nil, newEntityError(EntityPaymaster, fmt.Errorf("paymaster uses banned opcode: %s", create2OpCode))
 		}
 	}
 
-	// TODO : is this needed?
-	//targetAddresses := []common.Address{in.Tx.GetSender()}
-	//for _, entity := range knownEntity {
-	//	targetAddresses = append(targetAddresses, entity.Address)
-	//}
-	//slotsByEntity := newStorageSlotsByEntity(res.Keccak, targetAddresses)
-	//for title, entity := range knownEntity {
-	//	v := &storageSlotsValidator{
-	//		Tx:                    in.Tx,
-	//		SenderSlots:           slotsByEntity[in.Tx.GetSender()],
-	//		EntityName:            title,
-	//		EntityAddr:            entity.Address,
-	//		EntityAccessMap:       entity.Info.Access,
-	//		EntityContractSizeMap: entity.Info.ContractSize,
-	//		EntitySlots:           slotsByEntity[entity.Address],
-	//	}
-	//	if _, err := v.Process(); err != nil {
-	//		return nil, err
-	//	}
-	//}
+	targetAddresses := []common.Address{in.Tx.GetSender()}
+	for _, entity := range knownEntity {
+		targetAddresses = append(targetAddresses, entity.Address)
+	}
+	slotsByEntity := newStorageSlotsByEntity(res.Keccak, targetAddresses)
+	for _, title := range knownEntityTitles {
+		entity := knownEntity[title]
+		if entity.Info == nil {
+			continue
+		}
+		v := &storageSlotsValidator{
+			Tx:                    in.Tx,
+			SenderSlots:           slotsByEntity[in.Tx.GetSender()],
+			DeployerIsStaked:      knownEntity["deployer"].IsStaked,
+			EntityName:            title,
+			EntityAddr:            entity.Address,
+			EntityAccessMap:       entity.Info.Access,
+			EntityContractSizeMap: entity.Info.ContractSize,
+			EntitySlots:           slotsByEntity[entity.Address],
+			EntityIsStaked:        entity.IsStaked,
+			AltMempools:           in.AltMempools,
+		}
+		ids, err := v.Process()
+		if err != nil {
+			return nil, newEntityError(title, err)
+		}
+		amIds.Append(ids...)
+	}
 
 	callStack := newCallStack(res.Calls)
-	for _, call := range callStack {
-		if call.Method == methods.ValidatePaymasterTransactionSelector {
+	if err := validateDeployment(in.Tx, callStack); err != nil {
+		return nil, newEntityError(EntityDeployer, err)
+	}
+	for i, call := range callStack {
+		if call.Revert != nil && isEntityFrame(in.Tx, callStack, i) {
+			title := addr2KnownEntity(in.Tx, call.To)
+			origin := getDeepestRevert(callStack, i)
+			if origin == call {
+				return nil, newEntityError(title, fmt.Errorf("%s reverted: %s", title, call.Revert))
+			}
+			return nil, newEntityError(title, fmt.Errorf(
+				"%s reverted: %s in call to %s",
+				title,
+				origin.Revert,
+				addr2KnownEntity(in.Tx, origin.To),
+			))
+		} else if call.Method == methods.ValidatePaymasterTransactionSelector {
 			out, err := methods.DecodevalidatePaymasterTransactionOutputOutput(call.Return)
 			if err != nil {
 				return nil, fmt.Errorf(
@@ -117,19 +187,24 @@ func TraceSimulateValidation(in *TraceInput) (*TraceOutput, error) {
 				)
 			}
 
-			if len(out.Context) != 0 {
-				return nil, errors.New("unstaked paymaster must not return context")
+			if len(out.Context) != 0 && !hasStakeOrException("paymaster") {
+				return nil, newEntityError(EntityPaymaster, stderrors.New("unstaked paymaster must not return context"))
 			}
 		} else if call.Value.Cmp(common.Big0) == 1 {
-			return nil, fmt.Errorf(
+			title := addr2KnownEntity(in.Tx, call.From)
+			return nil, newEntityError(title, fmt.Errorf(
 				"%s has a forbidden value transfer to %s",
-				addr2KnownEntity(in.Tx, call.From),
+				title,
 				addr2KnownEntity(in.Tx, call.To),
-			)
+			))
 		}
 	}
 
+	ids := amIds.ToSlice()
+	sort.Strings(ids)
 	return &TraceOutput{
 		TouchedContracts: ic.ToSlice(),
+		StorageAccess:    sa,
+		AltMempoolIds:    ids,
 	}, nil
 }
@@ -0,0 +1,151 @@
+// Package stake provides methods for reading the stake of RIP-7560 entities and deciding if they are staked.
+package stake
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/methods"
+)
+
+// Info is the stake of an entity held by the stake manager.
+type Info struct {
+	Address         common.Address `json:"address"`
+	Stake           *big.Int       `json:"stake"`
+	UnstakeDelaySec uint64         `json:"unstakeDelaySec"`
+
+	// WithdrawTime is set once the entity has started to unlock its stake. A value of 0 means the stake is
+	// locked.
+	WithdrawTime uint64 `json:"withdrawTime"`
+}
+
+// GetStakeInfoFunc provides a general interface for retrieving the stake of an entity.
+type GetStakeInfoFunc = func(entity common.Address) (*Info, error)
+
+// GetStakeInfoNoop returns a GetStakeInfoFunc that treats every entity as having no stake. This is used when
+// no stake manager is configured.
+func GetStakeInfoNoop() GetStakeInfoFunc {
+	return func(entity common.Address) (*Info, error) {
+		return &Info{Address: entity, Stake: big.NewInt(0)}, nil
+	}
+}
+
+// GetStakeInfoWithEthClient returns a GetStakeInfoFunc that calls getDepositInfo on the stake manager using
+// an eth client. Errors from calls that did not reach the node are returned as an errors.TransportError.
+func GetStakeInfoWithEthClient(eth *ethclient.Client, stakeManager common.Address) GetStakeInfoFunc {
+	return func(entity common.Address) (*Info, error) {
+		data, err := methods.GetDepositInfoMethod.Inputs.Pack(entity)
+		if err != nil {
+			return nil, err
+		}
+		msg := ethereum.CallMsg{
+			To:   &stakeManager,
+			Data: append(methods.GetDepositInfoMethod.ID, data...),
+		}
+		res, err := eth.CallContract(context.Background(), msg, nil)
+		if err != nil {
+			return nil, errors.WrapTransportError(err)
+		}
+
+		out, err := methods.DecodeGetDepositInfoOutput(res)
+		if err != nil {
+			return nil, err
+		}
+		return &Info{
+			Address:         entity,
+			Stake:           out.Stake,
+			UnstakeDelaySec: uint64(out.UnstakeDelaySec),
+			WithdrawTime:    out.WithdrawTime,
+		}, nil
+	}
+}
+
+// Manager reads the stake of entities and decides if they meet the minimum stake and unstake delay. Stake
+// info is cached until SetBlockNumber is called with a new block. It is safe for concurrent use.
+type Manager struct {
+	mu              sync.Mutex
+	gsi             GetStakeInfoFunc
+	minStake        *big.Int
+	minUnstakeDelay uint64
+	block           uint64
+	cache           map[common.Address]*Info
+	logger          logr.Logger
+}
+
+// New returns a Manager that considers an entity staked if its stake is at least minStake with an unstake
+// delay of at least minUnstakeDelay seconds.
+func New(gsi GetStakeInfoFunc, minStake *big.Int, minUnstakeDelay uint64) *Manager {
+	return &Manager{
+		gsi:             gsi,
+		minStake:        minStake,
+		minUnstakeDelay: minUnstakeDelay,
+		cache:           make(map[common.Address]*Info),
+		logger:          logger.NewZeroLogr().WithName("stake"),
+	}
+}
+
+// UseLogger defines the logger object used by the Manager instance based on the go-logr/logr interface.
+func (m *Manager) UseLogger(logger logr.Logger) {
+	m.logger = logger.WithName("stake")
+}
+
+// SetBlockNumber clears the cached stake info if the block number has changed.
+func (m *Manager) SetBlockNumber(bn uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if bn != m.block {
+		m.block = bn
+		m.cache = make(map[common.Address]*Info)
+	}
+}
+
+// OnNewHead clears the cached stake info for a new block. It is used as a blocks.NewHeadFunc.
+func (m *Manager) OnNewHead(bn uint64) error {
+	m.SetBlockNumber(bn)
+	return nil
+}
+
+// GetStakeInfo returns the stake of an entity as of the latest block seen by the Manager.
+func (m *Manager) GetStakeInfo(entity common.Address) (*Info, error) {
+	m.mu.Lock()
+	bn := m.block
+	info, ok := m.cache[entity]
+	m.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	info, err := m.gsi(entity)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if bn == m.block {
+		m.cache[entity] = info
+	}
+	m.mu.Unlock()
+	return info, nil
+}
+
+// IsStaked returns true if the entity has a locked stake of at least the minimum value and unstake delay.
+func (m *Manager) IsStaked(entity common.Address) (bool, error) {
+	if entity == (common.Address{}) {
+		return false, nil
+	}
+
+	info, err := m.GetStakeInfo(entity)
+	if err != nil {
+		return false, err
+	}
+	return info.WithdrawTime == 0 &&
+		info.Stake != nil &&
+		info.Stake.Cmp(m.minStake) >= 0 &&
+		info.UnstakeDelaySec >= m.minUnstakeDelay, nil
+}
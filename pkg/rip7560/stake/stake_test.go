@@ -0,0 +1,103 @@
+package stake
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/methods"
+)
+
+func mockStakeInfo(infos map[common.Address]*Info, calls *int) GetStakeInfoFunc {
+	return func(entity common.Address) (*Info, error) {
+		*calls++
+		if info, ok := infos[entity]; ok {
+			return info, nil
+		}
+		return &Info{Address: entity, Stake: big.NewInt(0)}, nil
+	}
+}
+
+// TestIsStaked verifies that an entity is only staked with a locked stake that meets both the minimum value
+// and unstake delay.
+func TestIsStaked(t *testing.T) {
+	staked := testutils.ValidAddress1
+	lowStake := testutils.ValidAddress2
+	lowDelay := testutils.ValidAddress3
+	unlocking := testutils.ValidAddress4
+	infos := map[common.Address]*Info{
+		staked:    {Stake: big.NewInt(100), UnstakeDelaySec: 10},
+		lowStake:  {Stake: big.NewInt(99), UnstakeDelaySec: 10},
+		lowDelay:  {Stake: big.NewInt(100), UnstakeDelaySec: 9},
+		unlocking: {Stake: big.NewInt(100), UnstakeDelaySec: 10, WithdrawTime: 1},
+	}
+	calls := 0
+	m := New(mockStakeInfo(infos, &calls), big.NewInt(100), 10)
+
+	cases := map[common.Address]bool{
+		staked:           true,
+		lowStake:         false,
+		lowDelay:         false,
+		unlocking:        false,
+		common.Address{}: false,
+	}
+	for addr, want := range cases {
+		if got, err := m.IsStaked(addr); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		} else if got != want {
+			t.Fatalf("%s: got %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// TestGetStakeInfoCachedPerBlock verifies that stake info is only fetched once per block.
+func TestGetStakeInfoCachedPerBlock(t *testing.T) {
+	calls := 0
+	m := New(mockStakeInfo(map[common.Address]*Info{}, &calls), big.NewInt(1), 1)
+	if err := m.OnNewHead(1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.GetStakeInfo(testutils.ValidAddress1); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+
+	if err := m.OnNewHead(2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, err := m.GetStakeInfo(testutils.ValidAddress1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if calls != 2 {
+		t.Fatalf("got %d calls, want 2 after new block", calls)
+	}
+}
+
+// TestDecodeGetDepositInfoOutput verifies that the DepositInfo returned by the stake manager is decoded.
+func TestDecodeGetDepositInfoOutput(t *testing.T) {
+	data, err := methods.GetDepositInfoMethod.Outputs.Pack(
+		big.NewInt(1),
+		true,
+		big.NewInt(2),
+		uint32(3),
+		big.NewInt(4),
+	)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	out, err := methods.DecodeGetDepositInfoOutput(data)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if out.Deposit.Int64() != 1 || !out.Staked || out.Stake.Int64() != 2 || out.UnstakeDelaySec != 3 ||
+		out.WithdrawTime != 4 {
+		t.Fatalf("got %+v, want decoded deposit info", out)
+	}
+}
@@ -13,6 +13,13 @@ type GetRip7560BundleArgs struct {
 
 // geth/rip7560pool
 type GetRip7560BundleResult struct {
-	Bundle        []TransactionArgs
+	Bundle []TransactionArgs
+
+	// ValidForBlock is the last block number the bundle is expected to be valid for.
 	ValidForBlock *hexutil.Big
+
+	// ValidAfter and ValidUntil are the range of timestamps in which every transaction in the bundle is valid.
+	// A ValidUntil of 0 means there is no upper bound.
+	ValidAfter hexutil.Uint64
+	ValidUntil hexutil.Uint64
 }
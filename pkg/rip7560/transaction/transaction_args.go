@@ -1,7 +1,9 @@
 package transaction
 
 import (
+	"math"
 	"math/big"
+	"math/bits"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -204,6 +206,91 @@ func (args *TransactionArgs) GetDeployerData() []byte {
 	return nil
 }
 
+func (args *TransactionArgs) GetNonce() uint64 {
+	if args.Nonce != nil {
+		return uint64(*args.Nonce)
+	}
+	return 0
+}
+
+// GetNonceKey returns the RIP-7712 nonce key of the transaction. A transaction without a nonce key is
+// treated as using key 0.
+func (args *TransactionArgs) GetNonceKey() *big.Int {
+	if args.NonceKey != nil {
+		return new(big.Int).Set(args.NonceKey.ToInt())
+	}
+	return big.NewInt(0)
+}
+
+// HasSameNonce returns true if both transactions are from the same sender and use the same nonce key and
+// nonce value.
+func (args *TransactionArgs) HasSameNonce(other *TransactionArgs) bool {
+	return args.GetSender() == other.GetSender() &&
+		args.GetNonceKey().Cmp(other.GetNonceKey()) == 0 &&
+		args.GetNonce() == other.GetNonce()
+}
+
+// GetTotalGasLimit returns the maximum amount of gas the RIP-7560 transaction can use across validation,
+// paymaster validation, execution, and postOp. The sum saturates at math.MaxUint64 if it overflows.
+func (args *TransactionArgs) GetTotalGasLimit() uint64 {
+	total, _ := args.sumGasLimits()
+	return total
+}
+
+// HasTotalGasLimitOverflow returns true if the sum of all gas limits of the RIP-7560 transaction does not fit
+// in a uint64.
+func (args *TransactionArgs) HasTotalGasLimitOverflow() bool {
+	_, overflow := args.sumGasLimits()
+	return overflow
+}
+
+func (args *TransactionArgs) sumGasLimits() (uint64, bool) {
+	var total, carry uint64
+	for _, gas := range []uint64{
+		args.GetValidationGas(),
+		args.GetPaymasterGas(),
+		toUint64(args.Gas),
+		args.GetPostOpGas(),
+	} {
+		total, carry = bits.Add64(total, gas, 0)
+		if carry != 0 {
+			return math.MaxUint64, true
+		}
+	}
+	return total, false
+}
+
+// GetBuilderFee returns the fee paid to the bundler on top of gas. A transaction without a builder fee pays 0.
+func (args *TransactionArgs) GetBuilderFee() *big.Int {
+	if args.BuilderFee != nil {
+		return new(big.Int).Set(args.BuilderFee.ToInt())
+	}
+	return big.NewInt(0)
+}
+
+// GetMaxCost returns the most the RIP-7560 transaction can charge the sender or paymaster. This is the total
+// gas limit at the max fee per gas plus the builder fee.
+func (args *TransactionArgs) GetMaxCost() *big.Int {
+	cost := new(big.Int).SetUint64(args.GetTotalGasLimit())
+	if args.MaxFeePerGas != nil {
+		cost.Mul(cost, args.MaxFeePerGas.ToInt())
+	} else {
+		cost.SetUint64(0)
+	}
+	return cost.Add(cost, args.GetBuilderFee())
+}
+
+// GetEffectiveBid returns the amount per gas the RIP-7560 transaction pays the bundler given a basefee. This is
+// the dynamic gas price plus the builder fee spread over the total gas limit.
+func (args *TransactionArgs) GetEffectiveBid(basefee *big.Int) *big.Int {
+	bid := new(big.Int).Set(args.GetDynamicGasPrice(basefee))
+	fee := args.GetBuilderFee()
+	if gas := args.GetTotalGasLimit(); gas > 0 {
+		fee.Div(fee, new(big.Int).SetUint64(gas))
+	}
+	return bid.Add(bid, fee)
+}
+
 // GetDynamicGasPrice returns the effective gas price paid by the RIP-7560 transaction given a basefee.
 // If basefee is nil, it will assume a value of 0.
 func (args *TransactionArgs) GetDynamicGasPrice(basefee *big.Int) *big.Int {
@@ -248,7 +335,7 @@ func (args *TransactionArgs) toTransaction() *types.Transaction {
 		AccessList:    al,
 		// RIP-7560 parameters
 		Sender:                      args.Sender,
-		AuthorizationData:           *args.AuthorizationData,
+		AuthorizationData:           toByte(args.AuthorizationData),
 		Paymaster:                   args.Paymaster,
 		PaymasterData:               toByte(args.PaymasterData),
 		Deployer:                    args.Deployer,
@@ -259,9 +346,9 @@ func (args *TransactionArgs) toTransaction() *types.Transaction {
 		PostOpGas:                   toUint64(args.PostOpGas),
 	}
 	data = &rip7560Tx
-	hash := types.NewTx(data).Hash()
-	log.Info("RIP-7560 transaction created", "sender", rip7560Tx.Sender.Hex(), "hash", hash)
-	return types.NewTx(data)
+	tx := types.NewTx(data)
+	log.Trace("RIP-7560 transaction created", "sender", rip7560Tx.Sender.Hex(), "hash", tx.Hash())
+	return tx
 }
 
 // ToTransaction converts the arguments to a transaction.
@@ -0,0 +1,217 @@
+// Package searcher implements a background process that pushes bundles of RIP-7560 transactions to block
+// builders on every new block.
+package searcher
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/go-logr/logr"
+	"github.com/metachris/flashbotsrpc"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+)
+
+// ErrNoBuilders is returned if a Searcher is created without any builder endpoints.
+var ErrNoBuilders = errors.New("searcher: no builder urls")
+
+// GetBundleFunc assembles a new bundle from the mempool. The returned transactions are expected to have been
+// moved to in-flight.
+type GetBundleFunc = func(args transaction.GetRip7560BundleArgs) (*transaction.GetRip7560BundleResult, error)
+
+// BuilderStatus is the acceptance record of a single builder endpoint.
+type BuilderStatus struct {
+	Url            string `json:"url"`
+	Accepted       uint64 `json:"accepted"`
+	Rejected       uint64 `json:"rejected"`
+	LastBlock      uint64 `json:"lastBlock"`
+	LastBundleHash string `json:"lastBundleHash,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+}
+
+type builder struct {
+	rpc    *flashbotsrpc.FlashbotsRPC
+	status BuilderStatus
+}
+
+type pendingTx struct {
+	hash          common.Hash
+	raw           string
+	validForBlock uint64
+}
+
+// Searcher pushes bundles to a set of builders. Transactions that have been pushed are sent again targeting
+// the next block until a receipt is seen, they leave the in-flight set, or they are no longer valid.
+type Searcher struct {
+	mu       sync.Mutex
+	eoa      *signer.EOA
+	mempool  *mempool.Mempool
+	builders []*builder
+	gb       GetBundleFunc
+	args     transaction.GetRip7560BundleArgs
+	pending  []*pendingTx
+	logger   logr.Logger
+}
+
+// New returns a Searcher that assembles bundles within args and signs requests to each builder url with the
+// given EOA.
+func New(
+	eoa *signer.EOA,
+	mempool *mempool.Mempool,
+	urls []string,
+	gb GetBundleFunc,
+	args transaction.GetRip7560BundleArgs,
+) (*Searcher, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoBuilders
+	}
+
+	builders := []*builder{}
+	for _, url := range urls {
+		builders = append(builders, &builder{
+			rpc:    flashbotsrpc.New(url),
+			status: BuilderStatus{Url: url},
+		})
+	}
+	return &Searcher{
+		eoa:      eoa,
+		mempool:  mempool,
+		builders: builders,
+		gb:       gb,
+		args:     args,
+		pending:  []*pendingTx{},
+		logger:   logger.NewZeroLogr().WithName("searcher"),
+	}, nil
+}
+
+// UseLogger defines the logger object used by the Searcher instance based on the go-logr/logr interface.
+func (s *Searcher) UseLogger(logger logr.Logger) {
+	s.logger = logger.WithName("searcher")
+}
+
+// GetBuilders returns a copy of the acceptance record of each builder.
+func (s *Searcher) GetBuilders() []BuilderStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []BuilderStatus{}
+	for _, b := range s.builders {
+		statuses = append(statuses, b.status)
+	}
+	return statuses
+}
+
+// prunePending removes transactions that no longer need to be pushed for the target block. The caller must
+// hold mu.
+func (s *Searcher) prunePending(target uint64) {
+	pending := []*pendingTx{}
+	for _, ptx := range s.pending {
+		itx := s.mempool.GetInFlightByHash(ptx.hash)
+		if itx == nil || itx.IncludedBlock != 0 || target > ptx.validForBlock {
+			continue
+		}
+		pending = append(pending, ptx)
+	}
+	s.pending = pending
+}
+
+// addPending encodes and appends the transactions of a new bundle. The caller must hold mu.
+func (s *Searcher) addPending(res *transaction.GetRip7560BundleResult) error {
+	vfb := uint64(0)
+	if res.ValidForBlock != nil && res.ValidForBlock.ToInt().IsUint64() {
+		vfb = res.ValidForBlock.ToInt().Uint64()
+	}
+	for _, txArgs := range res.Bundle {
+		tx := txArgs.ToTransaction()
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		s.pending = append(s.pending, &pendingTx{
+			hash:          tx.Hash(),
+			raw:           hexutil.Encode(raw),
+			validForBlock: vfb,
+		})
+	}
+	return nil
+}
+
+// Submit pushes a bundle targeting the block after bn to every builder. The bundle holds any transactions
+// still waiting for inclusion from earlier submissions followed by a new bundle from the mempool. Each
+// builder's response is recorded in its BuilderStatus and a failed builder is tried again on the next block. It
+// can be used as a blocks.NewHeadFunc.
+func (s *Searcher) Submit(bn uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := bn + 1
+	s.prunePending(target)
+	res, err := s.gb(s.args)
+	if err != nil {
+		return err
+	}
+	if err := s.addPending(res); err != nil {
+		return err
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	req := flashbotsrpc.FlashbotsSendBundleRequest{
+		Txs:         []string{},
+		BlockNumber: hexutil.EncodeUint64(target),
+	}
+	hashes := []string{}
+	for _, ptx := range s.pending {
+		req.Txs = append(req.Txs, ptx.raw)
+		hashes = append(hashes, ptx.hash.String())
+	}
+
+	var wg sync.WaitGroup
+	for _, b := range s.builders {
+		wg.Add(1)
+		go func(b *builder) {
+			defer wg.Done()
+			resp, err := b.rpc.FlashbotsSendBundle(s.eoa.PrivateKey, req)
+			b.status.LastBlock = target
+			if err != nil {
+				b.status.Rejected++
+				b.status.LastError = err.Error()
+				return
+			}
+			b.status.Accepted++
+			b.status.LastBundleHash = resp.BundleHash
+			b.status.LastError = ""
+		}(b)
+	}
+	wg.Wait()
+
+	accepted := []string{}
+	rejected := []string{}
+	reasons := []string{}
+	for _, b := range s.builders {
+		if b.status.LastError == "" {
+			accepted = append(accepted, b.status.Url)
+		} else {
+			rejected = append(rejected, b.status.Url)
+			reasons = append(reasons, b.status.LastError)
+		}
+	}
+	l := s.logger.WithValues(
+		"target_block", target,
+		"bundle_aatx_hashes", hashes,
+		"accepted_builders", accepted,
+		"rejected_builders", rejected,
+		"rejected_reasons", reasons,
+	)
+	if len(accepted) == 0 {
+		l.Error(errors.New("bundle rejected by all builders"), "searcher error")
+	} else {
+		l.Info("searcher submit ok")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package searcher
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/rip7560/transaction"
+)
+
+func getBundleFromMempool(mem *mempool.Mempool) GetBundleFunc {
+	return func(args transaction.GetRip7560BundleArgs) (*transaction.GetRip7560BundleResult, error) {
+		res := &transaction.GetRip7560BundleResult{
+			Bundle:        []transaction.TransactionArgs{},
+			ValidForBlock: (*hexutil.Big)(big.NewInt(math.MaxInt64)),
+		}
+		txs, err := mem.Dump()
+		if err != nil {
+			return nil, err
+		}
+		if err := mem.BundleTxs(txs...); err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			res.Bundle = append(res.Bundle, *tx)
+		}
+		return res, nil
+	}
+}
+
+// TestSubmitTracksBuilders verifies that a bundle is pushed to every builder and the response of each is
+// recorded separately.
+func TestSubmitTracksBuilders(t *testing.T) {
+	good := testutils.BuilderRpcMock("0x01")
+	defer good.Close()
+	bad := testutils.BadBuilderRpcMock()
+	defer bad.Close()
+
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	if err := mem.AddTx(testutils.MockValidInitRip7560Tx()); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	s, err := New(
+		testutils.DummyEOA,
+		mem,
+		[]string{good.URL, bad.URL},
+		getBundleFromMempool(mem),
+		transaction.GetRip7560BundleArgs{},
+	)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if err := s.Submit(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	statuses := s.GetBuilders()
+	if statuses[0].Accepted != 1 || statuses[0].LastBundleHash != "0x01" || statuses[0].LastBlock != 11 {
+		t.Fatalf("got %+v, want accepted for block 11", statuses[0])
+	}
+	if statuses[1].Rejected != 1 || statuses[1].LastError == "" {
+		t.Fatalf("got %+v, want rejected", statuses[1])
+	}
+}
+
+// TestSubmitRetriesUntilIncluded verifies that in-flight transactions are pushed again on the next block
+// until a receipt has been seen.
+func TestSubmitRetriesUntilIncluded(t *testing.T) {
+	good := testutils.BuilderRpcMock("0x01")
+	defer good.Close()
+
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	tx := testutils.MockValidInitRip7560Tx()
+	if err := mem.AddTx(tx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	s, _ := New(
+		testutils.DummyEOA,
+		mem,
+		[]string{good.URL},
+		getBundleFromMempool(mem),
+		transaction.GetRip7560BundleArgs{},
+	)
+
+	if err := s.Submit(10); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := s.Submit(11); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if status := s.GetBuilders()[0]; status.Accepted != 2 || status.LastBlock != 12 {
+		t.Fatalf("got %+v, want resubmitted for block 12", status)
+	}
+
+	itx := mem.GetInFlightByHash(tx.ToTransaction().Hash())
+	itx.IncludedBlock = 12
+	itx.IncludedBlockHash = common.Hash{0x01}
+	if err := mem.UpdateInFlight(itx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := s.Submit(12); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if status := s.GetBuilders()[0]; status.Accepted != 2 {
+		t.Fatalf("got %+v, want no submission after inclusion", status)
+	}
+}
+
+// TestNewWithoutBuilders verifies that a Searcher cannot be created without builder urls.
+func TestNewWithoutBuilders(t *testing.T) {
+	mem, _ := mempool.NewWithStore(mempool.NewMemoryStore())
+	_, err := New(
+		testutils.DummyEOA,
+		mem,
+		[]string{},
+		getBundleFromMempool(mem),
+		transaction.GetRip7560BundleArgs{},
+	)
+	if err != ErrNoBuilders {
+		t.Fatalf("got %v, want ErrNoBuilders", err)
+	}
+}
@@ -0,0 +1,61 @@
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Merge applies the changes in a second OverrideSet on top of the first one and returns the result. Fields set
+// in changes replace those in os and storage slots are merged one by one, so that a chain of changes can be
+// applied in order. Neither input is modified.
+func Merge(os OverrideSet, changes OverrideSet) OverrideSet {
+	merged := OverrideSet{}
+	for addr, oa := range os {
+		merged[addr] = oa
+	}
+	for addr, ch := range changes {
+		oa := merged[addr]
+		if ch.Nonce != nil {
+			oa.Nonce = ch.Nonce
+		}
+		if ch.Code != nil {
+			oa.Code = ch.Code
+		}
+		if ch.Balance != nil {
+			oa.Balance = ch.Balance
+		}
+		if ch.State != nil {
+			state := copySlots(ch.State)
+			oa.State, oa.StateDiff = &state, nil
+		}
+		if ch.StateDiff != nil {
+			if oa.State != nil {
+				state := mergeSlots(oa.State, ch.StateDiff)
+				oa.State = &state
+			} else {
+				diff := mergeSlots(oa.StateDiff, ch.StateDiff)
+				oa.StateDiff = &diff
+			}
+		}
+		merged[addr] = oa
+	}
+
+	return merged
+}
+
+func copySlots(slots *map[common.Hash]common.Hash) map[common.Hash]common.Hash {
+	cpy := make(map[common.Hash]common.Hash)
+	if slots != nil {
+		for k, v := range *slots {
+			cpy[k] = v
+		}
+	}
+	return cpy
+}
+
+func mergeSlots(slots *map[common.Hash]common.Hash, changes *map[common.Hash]common.Hash) map[common.Hash]common.Hash {
+	merged := copySlots(slots)
+	for k, v := range *changes {
+		merged[k] = v
+	}
+	return merged
+}
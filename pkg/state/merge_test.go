@@ -0,0 +1,35 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// TestMergeStateDiff verifies that storage slots from later changes are applied on top of earlier ones and that
+// fields not set in the changes are kept.
+func TestMergeStateDiff(t *testing.T) {
+	acc := common.HexToAddress("0x01")
+	slot1, slot2 := common.HexToHash("0x01"), common.HexToHash("0x02")
+	bal := hexutil.Big(*common.Big1)
+	diff := map[common.Hash]common.Hash{slot1: common.HexToHash("0xaa"), slot2: common.HexToHash("0xbb")}
+	os := OverrideSet{acc: OverrideAccount{Balance: &bal, StateDiff: &diff}}
+
+	change := map[common.Hash]common.Hash{slot2: common.HexToHash("0xcc")}
+	merged := Merge(os, OverrideSet{acc: OverrideAccount{StateDiff: &change}})
+
+	oa := merged[acc]
+	if oa.Balance == nil || oa.Balance.ToInt().Cmp(common.Big1) != 0 {
+		t.Fatalf("got balance %v, want 1", oa.Balance)
+	}
+	if got := (*oa.StateDiff)[slot1]; got != common.HexToHash("0xaa") {
+		t.Fatalf("got slot1 %s, want 0xaa", got)
+	}
+	if got := (*oa.StateDiff)[slot2]; got != common.HexToHash("0xcc") {
+		t.Fatalf("got slot2 %s, want 0xcc", got)
+	}
+	if got := (*os[acc].StateDiff)[slot2]; got != common.HexToHash("0xbb") {
+		t.Fatalf("got original slot2 %s, want 0xbb", got)
+	}
+}